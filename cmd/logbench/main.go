@@ -0,0 +1,207 @@
+// Command logbench compares the JSON encoder of
+// github.com/intellectia/go-log built with the fastjson tag,
+// logger.NewFastJSONEncoder, with zap's own on typical entries:
+//
+//	go run github.com/intellectia/go-log/cmd/logbench
+//
+// For each case it prints the time, bytes and allocations per entry of
+// both encoders and the speedup. Entries with fields the fast encoder
+// leaves to zap's, such as objects, show the cost of the fallback.
+//
+// A second table measures whole Logger calls writing to a sink that
+// discards them: constant fields passed with every entry against the same
+// fields encoded once through Config.Fields, and error stack traces
+// captured into a new buffer each time, as before they were pooled,
+// against the Logger's pooled capture. The Logger captures a few frames
+// deeper, which takes longer, so compare the allocations of those two.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/intellectia/go-log/pkg/logger"
+)
+
+// benchCase is an entry encoded by an encoder with context fields.
+type benchCase struct {
+	name    string
+	context []zap.Field
+	fields  []zap.Field
+	message string
+}
+
+var cases = []benchCase{
+	{
+		name:    "message only",
+		message: "request served",
+	},
+	{
+		name:    "five fields",
+		message: "request served",
+		fields: []zap.Field{
+			zap.String("method", "GET"),
+			zap.String("path", "/api/v1/users/42"),
+			zap.Int("status", 200),
+			zap.Int("bytes", 5120),
+			zap.Duration("duration", 1200*time.Microsecond),
+		},
+	},
+	{
+		name:    "service context",
+		message: "cache miss",
+		context: []zap.Field{
+			zap.String("service", "checkout"),
+			zap.String("version", "1.42.0"),
+			zap.String("hostname", "checkout-7d9f8c-x2x4q"),
+			zap.String("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"),
+			zap.String("span_id", "00f067aa0ba902b7"),
+		},
+		fields: []zap.Field{zap.String("key", "cart:42"), zap.Bool("stale", false)},
+	},
+	{
+		name:    "long string",
+		message: "payload received",
+		fields:  []zap.Field{zap.String("body", strings.Repeat("lorem ipsum dolor sit amet, ", 40))},
+	},
+	{
+		name:    "escaped string",
+		message: "query failed",
+		fields:  []zap.Field{zap.String("query", "SELECT \"name\"\n\tFROM users\n\tWHERE id = 'é42'")},
+	},
+	{
+		name:    "fallback",
+		message: "request failed",
+		fields: []zap.Field{
+			zap.Error(errors.New("connection reset by peer")),
+			zap.Strings("hosts", []string{"db-1", "db-2"}),
+		},
+	},
+}
+
+func main() {
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "case\tzap ns/op\tfast ns/op\tspeedup\tzap B/op\tfast B/op\tzap allocs\tfast allocs\t")
+	for _, c := range cases {
+		zapResult := run(zapcore.NewJSONEncoder(cfg), c)
+		fastResult := run(logger.NewFastJSONEncoder(cfg), c)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2fx\t%d\t%d\t%d\t%d\t\n",
+			c.name,
+			zapResult.NsPerOp(), fastResult.NsPerOp(),
+			float64(zapResult.NsPerOp())/float64(fastResult.NsPerOp()),
+			zapResult.AllocedBytesPerOp(), fastResult.AllocedBytesPerOp(),
+			zapResult.AllocsPerOp(), fastResult.AllocsPerOp(),
+		)
+	}
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "logger case\tns/op\tB/op\tallocs\t")
+	for _, c := range loggerCases {
+		result := runLogger(c)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", c.name, result.NsPerOp(), result.AllocedBytesPerOp(), result.AllocsPerOp())
+	}
+	w.Flush()
+}
+
+// run measures encoding the entry of c with enc.
+func run(enc zapcore.Encoder, c benchCase) testing.BenchmarkResult {
+	enc = enc.Clone()
+	for _, f := range c.context {
+		f.AddTo(enc)
+	}
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: c.message}
+	return testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, err := enc.EncodeEntry(ent, c.fields)
+			if err != nil {
+				b.Fatal(err)
+			}
+			buf.Free()
+		}
+	})
+}
+
+// serviceFields are constant fields of every entry of a service.
+var serviceFields = []zap.Field{
+	zap.String("service", "checkout"),
+	zap.String("version", "1.42.0"),
+	zap.String("hostname", "checkout-7d9f8c-x2x4q"),
+	zap.String("region", "eu-west-1"),
+	zap.String("env", "production"),
+}
+
+// loggerCase is a call made on a Logger built with config.
+type loggerCase struct {
+	name   string
+	config logger.Config
+	log    func(l *logger.Logger)
+}
+
+var errBench = errors.New("connection reset by peer")
+
+var loggerCases = []loggerCase{
+	{
+		name: "fields per entry",
+		log:  func(l *logger.Logger) { l.Info("cache miss", serviceFields...) },
+	},
+	{
+		name:   "constant fields",
+		config: logger.Config{Fields: serviceFields},
+		log:    func(l *logger.Logger) { l.Info("cache miss") },
+	},
+	{
+		name:   "stack, new buffer",
+		config: logger.Config{StackTraces: logger.StackTracesNever},
+		log: func(l *logger.Logger) {
+			buf := make([]byte, 1024)
+			n := runtime.Stack(buf, false)
+			l.Error("request failed", errBench, zap.String("stacktrace", string(buf[:n])))
+		},
+	},
+	{
+		name:   "stack, pooled buffer",
+		config: logger.Config{StackTraces: logger.StackTracesAlways},
+		log:    func(l *logger.Logger) { l.Error("request failed", errBench) },
+	},
+}
+
+// discard is a sink dropping the entries it is given.
+type discard struct{}
+
+func (discard) Name() string                { return "discard" }
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+func (discard) Sync() error                 { return nil }
+func (discard) Close() error                { return nil }
+
+// runLogger measures the call of c on a Logger writing only to discard.
+func runLogger(c loggerCase) testing.BenchmarkResult {
+	config := c.config
+	config.DisableFiles = true
+	config.DisableResourceDetection = true
+	l, err := logger.New(logger.WithConfig(config), logger.WithConsole(false), logger.WithSink(discard{}))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logbench: %v\n", err)
+		os.Exit(1)
+	}
+	return testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.log(l)
+		}
+	})
+}
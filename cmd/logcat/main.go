@@ -0,0 +1,125 @@
+// Command logcat prints the entries of log files written by
+// github.com/intellectia/go-log as JSON lines, whether they are in the
+// JSON or the binary file format, or a mix of both:
+//
+//	go run github.com/intellectia/go-log/cmd/logcat info.log info-*.log.gz
+//
+// Without arguments it reads the standard input, so backups compressed
+// with other formats can be piped in, e.g. "zstd -dc info-*.log.zst |
+// logcat". Files ending in .gz are decompressed. Damaged records are
+// reported on stderr and skipped, and the exit status is 1 when there were
+// any.
+//
+// With -loss, entries numbered by Config.EntryIDs are checked for gaps,
+// and the entries missing from the files are reported on stderr; the exit
+// status is 1 when any are. Pass the files of all levels, since each file
+// of a split output has gaps for the levels of the others.
+//
+// With -where, only the entries matching a logread.Query expression are
+// printed, e.g. -where 'level>=error AND user_id=42'.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/intellectia/go-log/pkg/logger"
+	"github.com/intellectia/go-log/pkg/logread"
+)
+
+func main() {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	loss := flag.Bool("loss", false, "report entries missing by their sequence numbers")
+	where := flag.String("where", "", "print only the entries matching a query, e.g. 'level>=error AND user_id=42'")
+	flag.Parse()
+	var keep logread.Filter
+	if *where != "" {
+		var err error
+		if keep, err = logread.Query(*where); err != nil {
+			fmt.Fprintf(os.Stderr, "logcat: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	failed := false
+	var seen logger.SequenceTracker
+	for _, path := range paths {
+		if err := cat(out, path, keep, &seen); err != nil {
+			fmt.Fprintf(os.Stderr, "logcat: %s: %v\n", path, err)
+			failed = true
+		}
+	}
+	if *loss {
+		report := seen.Report()
+		fmt.Fprintf(os.Stderr, "logcat: %v\n", report)
+		if report.Missing > 0 {
+			failed = true
+		}
+	}
+	if failed {
+		out.Flush()
+		os.Exit(1)
+	}
+}
+
+// cat copies the entries of the file at path, "-" for the standard input,
+// to out, recording their sequence numbers in seen. With keep, entries it
+// does not keep, and lines that are not entries, are left out.
+func cat(out *bufio.Writer, path string, keep logread.Filter, seen *logger.SequenceTracker) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var damaged int
+	entries := logger.NewEntryReader(r)
+	for {
+		entry, err := entries.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, logger.ErrBadRecord) {
+			damaged++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		seen.ObserveEntry(entry)
+		if keep != nil {
+			if e, err := logread.Decode(entry, logread.DefaultKeys); err != nil || !keep(e) {
+				continue
+			}
+		}
+		out.Write(entry)
+		out.WriteByte('\n')
+	}
+	if damaged > 0 {
+		return fmt.Errorf("%d damaged records skipped", damaged)
+	}
+	return nil
+}
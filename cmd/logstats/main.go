@@ -0,0 +1,365 @@
+// Command logstats summarizes log files written by
+// github.com/intellectia/go-log, for triage without a log platform:
+//
+//	go run github.com/intellectia/go-log/cmd/logstats logs/info*.log logs/error*.log.gz
+//
+// It prints the entries per level for each hour, the most frequent errors
+// and the slowest operations. Errors are grouped by fingerprint: their
+// logger, message and error with numbers, hexadecimal IDs and UUIDs
+// masked, so "user 42 not found" and "user 7 not found" count together.
+// Operations are the entries with a duration field, such as those of
+// HTTPMiddleware, Run and Scope; -durations tells how it is encoded, as
+// Config.DurationFormat. Without arguments it reads the standard input.
+//
+// With -where, only the entries matching a logread.Query expression are
+// counted, e.g. -where 'logger=api AND time>24h'.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/intellectia/go-log/pkg/logger"
+	"github.com/intellectia/go-log/pkg/logread"
+)
+
+// maxDamaged is the number of errors in a row after which reading stops,
+// in case they come from the files rather than from damaged entries.
+const maxDamaged = 100
+
+func main() {
+	where := flag.String("where", "", "count only the entries matching a query, e.g. 'logger=api AND time>24h'")
+	durationKey := flag.String("duration", "duration", "the field holding the duration of an operation")
+	durations := flag.String("durations", logger.DurationFormatSeconds, "how durations are encoded: seconds, millis, nanos or string")
+	top := flag.Int("top", 10, "the number of errors and slow operations listed")
+	flag.Parse()
+
+	var r *logread.Reader
+	if paths := flag.Args(); len(paths) > 0 {
+		var err error
+		if r, err = logread.Open(paths...); err != nil {
+			fmt.Fprintf(os.Stderr, "logstats: %v\n", err)
+			os.Exit(1)
+		}
+		defer r.Close()
+	} else {
+		r = logread.NewReader(os.Stdin)
+	}
+	if *where != "" {
+		keep, err := logread.Query(*where)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "logstats: %v\n", err)
+			os.Exit(2)
+		}
+		r.Where(keep)
+	}
+
+	s := newStats(*durationKey, *durations, *top)
+	failed := false
+	for damaged := 0; ; {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			s.damaged++
+			if damaged++; damaged >= maxDamaged {
+				fmt.Fprintf(os.Stderr, "logstats: %v\n", err)
+				failed = true
+				break
+			}
+			continue
+		}
+		damaged = 0
+		s.add(e)
+	}
+	s.print(os.Stdout)
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// stats accumulates the summary of the entries read.
+type stats struct {
+	durationKey, durationFormat string
+	top                         int
+
+	entries, damaged int
+	first, last      time.Time
+	levels           map[zapcore.Level]bool
+	hours            map[time.Time]map[zapcore.Level]int
+
+	errors map[string]*errorGroup
+	slow   []operation // the slowest, up to top
+}
+
+// errorGroup counts the errors with a fingerprint.
+type errorGroup struct {
+	count       int
+	first, last time.Time
+	sample      string
+}
+
+// operation is an entry with a duration.
+type operation struct {
+	duration time.Duration
+	entry    *logread.Entry
+}
+
+func newStats(durationKey, durationFormat string, top int) *stats {
+	return &stats{
+		durationKey:    durationKey,
+		durationFormat: durationFormat,
+		top:            top,
+		levels:         make(map[zapcore.Level]bool),
+		hours:          make(map[time.Time]map[zapcore.Level]int),
+		errors:         make(map[string]*errorGroup),
+	}
+}
+
+func (s *stats) add(e *logread.Entry) {
+	s.entries++
+	if !e.Time.IsZero() {
+		if s.first.IsZero() || e.Time.Before(s.first) {
+			s.first = e.Time
+		}
+		if e.Time.After(s.last) {
+			s.last = e.Time
+		}
+	}
+	s.levels[e.Level] = true
+	hour := e.Time.Local().Truncate(time.Hour)
+	if s.hours[hour] == nil {
+		s.hours[hour] = make(map[zapcore.Level]int)
+	}
+	s.hours[hour][e.Level]++
+
+	if e.Level != zapcore.InvalidLevel && e.Level >= zapcore.ErrorLevel {
+		s.addError(e)
+	}
+	if d, ok := s.duration(e); ok {
+		s.addOperation(operation{d, e})
+	}
+}
+
+func (s *stats) addError(e *logread.Entry) {
+	var text string
+	e.Field("error", &text)
+	sample := e.Message
+	if text != "" {
+		sample += ": " + text
+	}
+	if e.Logger != "" {
+		sample = e.Logger + ": " + sample
+	}
+	fp := fingerprint(sample)
+	g := s.errors[fp]
+	if g == nil {
+		g = &errorGroup{first: e.Time, last: e.Time, sample: sample}
+		s.errors[fp] = g
+	}
+	g.count++
+	if e.Time.Before(g.first) {
+		g.first = e.Time
+	}
+	if e.Time.After(g.last) {
+		g.last = e.Time
+	}
+}
+
+// duration returns the duration field of e, decoded as durationFormat
+// says.
+func (s *stats) duration(e *logread.Entry) (time.Duration, bool) {
+	raw, ok := e.Fields[s.durationKey]
+	if !ok {
+		return 0, false
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		d, err := time.ParseDuration(text)
+		return d, err == nil
+	}
+	var n float64
+	if json.Unmarshal(raw, &n) != nil {
+		return 0, false
+	}
+	switch strings.ToLower(s.durationFormat) {
+	case logger.DurationFormatMillis:
+		return time.Duration(n * float64(time.Millisecond)), true
+	case logger.DurationFormatNanos:
+		return time.Duration(n), true
+	}
+	return time.Duration(n * float64(time.Second)), true
+}
+
+// addOperation keeps op if it is among the top slowest.
+func (s *stats) addOperation(op operation) {
+	if s.top <= 0 || len(s.slow) == s.top && op.duration <= s.slow[len(s.slow)-1].duration {
+		return
+	}
+	i := sort.Search(len(s.slow), func(i int) bool { return s.slow[i].duration < op.duration })
+	if len(s.slow) < s.top {
+		s.slow = append(s.slow, operation{})
+	}
+	copy(s.slow[i+1:], s.slow[i:])
+	s.slow[i] = op
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	hexPattern    = regexp.MustCompile(`\b(0x[0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`)
+	numberPattern = regexp.MustCompile(`\d+`)
+)
+
+// fingerprint masks the parts of an error that vary between occurrences
+// of the same error.
+func fingerprint(s string) string {
+	s = uuidPattern.ReplaceAllString(s, "<uuid>")
+	s = hexPattern.ReplaceAllStringFunc(s, func(h string) string {
+		// Long runs of a-f alone are more likely words than IDs.
+		if strings.HasPrefix(h, "0x") || strings.ContainsAny(h, "0123456789") {
+			return "<hex>"
+		}
+		return h
+	})
+	return numberPattern.ReplaceAllString(s, "N")
+}
+
+func (s *stats) print(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "%d entries", s.entries)
+	if s.damaged > 0 {
+		fmt.Fprintf(w, ", %d damaged records skipped", s.damaged)
+	}
+	if !s.first.IsZero() {
+		span := s.last.Sub(s.first)
+		fmt.Fprintf(w, ", %s to %s (%s)", clock(s.first), clock(s.last), span.Round(time.Second))
+		if minutes := span.Minutes(); minutes >= 1 {
+			errs := 0
+			for _, g := range s.errors {
+				errs += g.count
+			}
+			fmt.Fprintf(w, ", %.1f entries/min, %.2f errors/min", float64(s.entries)/minutes, float64(errs)/minutes)
+		}
+	}
+	fmt.Fprintln(w)
+	if s.entries == 0 {
+		return
+	}
+
+	levels := make([]zapcore.Level, 0, len(s.levels))
+	for lvl := range s.levels {
+		levels = append(levels, lvl)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levelRank(levels[i]) < levelRank(levels[j]) })
+	hours := make([]time.Time, 0, len(s.hours))
+	for h := range s.hours {
+		hours = append(hours, h)
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i].Before(hours[j]) })
+
+	fmt.Fprint(w, "\nHOUR\tTOTAL")
+	for _, lvl := range levels {
+		fmt.Fprintf(w, "\t%s", levelName(lvl))
+	}
+	fmt.Fprintln(w)
+	for _, h := range hours {
+		counts := s.hours[h]
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		name := "-"
+		if !h.IsZero() {
+			name = h.Format("2006-01-02 15:00")
+		}
+		fmt.Fprintf(w, "%s\t%d", name, total)
+		for _, lvl := range levels {
+			fmt.Fprintf(w, "\t%d", counts[lvl])
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(s.errors) > 0 {
+		groups := make([]*errorGroup, 0, len(s.errors))
+		for _, g := range s.errors {
+			groups = append(groups, g)
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			if groups[i].count != groups[j].count {
+				return groups[i].count > groups[j].count
+			}
+			return groups[i].sample < groups[j].sample
+		})
+		if len(groups) > s.top {
+			groups = groups[:s.top]
+		}
+		fmt.Fprintf(w, "\nERRORS\tFIRST\tLAST\tERROR (%d distinct)\n", len(s.errors))
+		for _, g := range groups {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", g.count, clock(g.first), clock(g.last), oneLine(g.sample))
+		}
+	}
+
+	if len(s.slow) > 0 {
+		fmt.Fprintf(w, "\n%s\tTIME\tOPERATION\n", strings.ToUpper(s.durationKey))
+		for _, op := range s.slow {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", op.duration.Round(time.Microsecond), clock(op.entry.Time), describe(op.entry))
+		}
+	}
+}
+
+// describe names the operation of an entry: its message, and the request
+// or job fields of the logger's middlewares when it has them.
+func describe(e *logread.Entry) string {
+	parts := []string{e.Message}
+	for _, key := range []string{"method", "path", "status", "job", "job_type", "queue", "scope"} {
+		if raw, ok := e.Fields[key]; ok {
+			var s string
+			if json.Unmarshal(raw, &s) != nil {
+				s = string(raw)
+			}
+			parts = append(parts, key+"="+s)
+		}
+	}
+	return oneLine(strings.Join(parts, " "))
+}
+
+func clock(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func levelName(lvl zapcore.Level) string {
+	if lvl == zapcore.InvalidLevel {
+		return "OTHER"
+	}
+	return lvl.CapitalString()
+}
+
+// levelRank orders the levels by severity, entries without a known level
+// last.
+func levelRank(lvl zapcore.Level) int {
+	if lvl == zapcore.InvalidLevel {
+		return int(zapcore.FatalLevel) + 1
+	}
+	return int(lvl)
+}
@@ -0,0 +1,392 @@
+// Command logvet reports logging that does not follow the structured
+// logging conventions of github.com/intellectia/go-log:
+//
+//	printf  calls to Infof, Errorf, Debugf, Warnf and Fatalf on the logger
+//	        package, a *logger.Logger or a *zap.SugaredLogger, which should
+//	        log a constant message with fields instead
+//	keys    field keys that repeat within one call or are not snake_case;
+//	        dots may separate snake_case segments, as in "http.status_code"
+//	secrets fields whose value is a variable named like a password, token
+//	        or secret, which should be logged with logger.Mask
+//
+// Usage:
+//
+//	go run github.com/intellectia/go-log/cmd/logvet ./...
+//
+// It works from syntax alone, so it has no dependencies beyond the
+// standard library: package functions are matched through the imports of
+// the logger and zap packages, and loggers by the types they are declared
+// with or the calls they are assigned from, within each file. Field keys
+// are checked when they are literals.
+// Findings are printed as file:line:col and the exit status is 1 when
+// there are any.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	loggerImport = "github.com/intellectia/go-log/pkg/logger"
+	zapImport    = "go.uber.org/zap"
+)
+
+var printfFuncs = map[string]bool{
+	"Infof":  true,
+	"Errorf": true,
+	"Debugf": true,
+	"Warnf":  true,
+	"Fatalf": true,
+}
+
+// finding is one reported problem.
+type finding struct {
+	pos token.Position
+	msg string
+}
+
+// imports are the names a file refers to the logger and zap packages by,
+// empty for packages it does not import.
+type imports struct {
+	logger, zap string
+}
+
+// check inspects one file that imports the logger or zap package.
+type check func(fset *token.FileSet, f *ast.File, imp imports) []finding
+
+var checks = map[string]check{
+	"printf":  checkPrintf,
+	"keys":    checkKeys,
+	"secrets": checkSecrets,
+}
+
+func main() {
+	disabled := flag.String("disable", "", "comma-separated checks to skip")
+	flag.Parse()
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	skip := make(map[string]bool)
+	for _, name := range strings.Split(*disabled, ",") {
+		skip[strings.TrimSpace(name)] = true
+	}
+
+	var findings []finding
+	fset := token.NewFileSet()
+	for _, file := range goFiles(patterns) {
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		imp := imports{logger: importName(f, loggerImport), zap: importName(f, zapImport)}
+		if imp.logger == "" && imp.zap == "" {
+			continue
+		}
+		for checkName, c := range checks {
+			if !skip[checkName] {
+				findings = append(findings, c(fset, f, imp)...)
+			}
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i].pos, findings[j].pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	for _, f := range findings {
+		fmt.Printf("%s: %s\n", f.pos, f.msg)
+	}
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
+
+// goFiles expands directory patterns, where a trailing /... includes
+// subdirectories, to the Go files in them. Test files are included.
+func goFiles(patterns []string) []string {
+	var files []string
+	for _, pattern := range patterns {
+		dir, recursive := strings.TrimSuffix(pattern, "/..."), strings.HasSuffix(pattern, "/...")
+		if pattern == "..." {
+			dir, recursive = ".", true
+		}
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != dir && (!recursive || d.Name() == "vendor" || d.Name() == "testdata" || strings.HasPrefix(d.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, ".go") {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// importName returns the name f refers to the package at path by, or ""
+// if it does not import it.
+func importName(f *ast.File, path string) string {
+	for _, imp := range f.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p != path {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return path[strings.LastIndex(path, "/")+1:]
+	}
+	return ""
+}
+
+func checkPrintf(fset *token.FileSet, f *ast.File, imp imports) []finding {
+	if imp.logger == "" {
+		return nil
+	}
+	loggers := loggerNames(f, imp)
+	var findings []finding
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !printfFuncs[sel.Sel.Name] || !isLogger(sel.X, imp, loggers) {
+			return true
+		}
+		findings = append(findings, finding{
+			pos: fset.Position(call.Pos()),
+			msg: fmt.Sprintf("printf: %s formats the message; log a constant message with fields", sel.Sel.Name),
+		})
+		return true
+	})
+	return findings
+}
+
+// isLoggerType reports whether t is logger.Logger or zap.SugaredLogger,
+// or a pointer to one.
+func isLoggerType(t ast.Expr, imp imports) bool {
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	sel, ok := t.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && (pkg.Name == imp.logger && sel.Sel.Name == "Logger" || pkg.Name == imp.zap && sel.Sel.Name == "SugaredLogger")
+}
+
+// isLogger reports whether x is the logger package or, as far as the
+// syntax tells, a *logger.Logger or *zap.SugaredLogger: a variable or
+// field in loggers, a logger package function result such as logger.L(),
+// zap.S(), a Sugar() result, or a method result of one of those.
+func isLogger(x ast.Expr, imp imports, loggers map[string]bool) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return x.Name == imp.logger || loggers[x.Name]
+	case *ast.SelectorExpr:
+		return loggers[x.Sel.Name]
+	case *ast.ParenExpr:
+		return isLogger(x.X, imp, loggers)
+	case *ast.CallExpr:
+		sel, ok := x.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return false
+		}
+		if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == imp.zap && sel.Sel.Name == "S" {
+			return true
+		}
+		return sel.Sel.Name == "Sugar" || isLogger(sel.X, imp, loggers)
+	}
+	return false
+}
+
+// loggerNames returns the names of the variables, parameters and struct
+// fields of f declared as loggers or assigned one. Scopes are ignored.
+func loggerNames(f *ast.File, imp imports) map[string]bool {
+	names := make(map[string]bool)
+	add := func(e ast.Expr) {
+		switch e := e.(type) {
+		case *ast.Ident:
+			names[e.Name] = true
+		case *ast.SelectorExpr:
+			names[e.Sel.Name] = true
+		}
+	}
+	// A second pass finds the names assigned from those of the first.
+	for pass := 0; pass < 2; pass++ {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Field:
+				if isLoggerType(n.Type, imp) {
+					for _, name := range n.Names {
+						add(name)
+					}
+				}
+			case *ast.ValueSpec:
+				for i, name := range n.Names {
+					if n.Type != nil && isLoggerType(n.Type, imp) || i < len(n.Values) && isLogger(n.Values[i], imp, names) {
+						add(name)
+					}
+				}
+			case *ast.AssignStmt:
+				if len(n.Lhs) == len(n.Rhs) {
+					for i, rhs := range n.Rhs {
+						if isLogger(rhs, imp, names) {
+							add(n.Lhs[i])
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	delete(names, "_")
+	return names
+}
+
+// zapFields are the zap functions constructing a field from a key.
+var zapFields = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(`Any Array Binary Bool Boolp Bools
+		ByteString ByteStrings Complex128 Complex128p Complex128s Complex64
+		Complex64p Complex64s Dict Duration Durationp Durations Errors
+		Float32 Float32p Float32s Float64 Float64p Float64s Int Int16
+		Int16p Int16s Int32 Int32p Int32s Int64 Int64p Int64s Int8 Int8p
+		Int8s Intp Ints NamedError Namespace Object ObjectValues Objects
+		Reflect Stack StackSkip String Stringer Stringers Stringp Strings
+		Time Timep Times Uint Uint16 Uint16p Uint16s Uint32 Uint32p Uint32s
+		Uint64 Uint64p Uint64s Uint8 Uint8p Uint8s Uintp Uintptr Uintptrp
+		Uintptrs Uints`) {
+		zapFields[name] = true
+	}
+}
+
+// loggerFields are the logger functions constructing a field from a key.
+var loggerFields = map[string]bool{
+	"Mask":      true,
+	"Bytes":     true,
+	"Namespace": true,
+}
+
+// fieldKey returns the literal key of a call constructing a field through
+// the zap or logger package, such as zap.String("user_id", id). Logging
+// calls such as logger.Info("user logged in") are not field constructors,
+// and logger.Code has the fixed key event_code.
+func fieldKey(call *ast.CallExpr, imp imports) (string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok || pkg.Name == "" {
+		return "", false
+	}
+	switch {
+	case pkg.Name == imp.logger && sel.Sel.Name == "Code":
+		return "event_code", true
+	case pkg.Name == imp.zap && zapFields[sel.Sel.Name]:
+	case pkg.Name == imp.logger && loggerFields[sel.Sel.Name]:
+	default:
+		return "", false
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	key, err := strconv.Unquote(lit.Value)
+	return key, err == nil
+}
+
+var snakeCase = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*(\.[a-z0-9]+(_[a-z0-9]+)*)*$`)
+
+func checkKeys(fset *token.FileSet, f *ast.File, imp imports) []finding {
+	var findings []finding
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if key, ok := fieldKey(call, imp); ok && !snakeCase.MatchString(key) {
+			findings = append(findings, finding{
+				pos: fset.Position(call.Args[0].Pos()),
+				msg: fmt.Sprintf("keys: field key %q is not snake_case", key),
+			})
+		}
+		seen := make(map[string]bool)
+		for _, arg := range call.Args {
+			field, ok := arg.(*ast.CallExpr)
+			if !ok {
+				continue
+			}
+			key, ok := fieldKey(field, imp)
+			if !ok {
+				continue
+			}
+			if seen[key] {
+				findings = append(findings, finding{
+					pos: fset.Position(field.Pos()),
+					msg: fmt.Sprintf("keys: field key %q repeats in this call", key),
+				})
+			}
+			seen[key] = true
+		}
+		return true
+	})
+	return findings
+}
+
+var secretName = regexp.MustCompile(`(?i)passw(or)?d|token|secret`)
+
+func checkSecrets(fset *token.FileSet, f *ast.File, imp imports) []finding {
+	var findings []finding
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if _, ok := fieldKey(call, imp); !ok || len(call.Args) < 2 {
+			return true
+		}
+		if sel := call.Fun.(*ast.SelectorExpr); sel.Sel.Name == "Mask" {
+			return true
+		}
+		var name string
+		switch v := call.Args[1].(type) {
+		case *ast.Ident:
+			name = v.Name
+		case *ast.SelectorExpr:
+			name = v.Sel.Name
+		}
+		if name != "" && secretName.MatchString(name) {
+			findings = append(findings, finding{
+				pos: fset.Position(call.Args[1].Pos()),
+				msg: fmt.Sprintf("secrets: %s is logged in clear; use logger.Mask", name),
+			})
+		}
+		return true
+	})
+	return findings
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// key is a key press: a character, or one of the special keys below.
+type key rune
+
+const (
+	keyCtrlC     key = 3
+	keyEnter     key = '\r'
+	keyEscape    key = 27
+	keyBackspace key = 127
+
+	// Past the characters a terminal sends.
+	keyUp key = utf8.MaxRune + 1 + iota
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+)
+
+// escapeWait is how long a lone escape waits for the rest of a sequence.
+const escapeWait = 25 * time.Millisecond
+
+// escapes are the sequences of the special keys, as xterm and the macOS
+// terminal send them.
+var escapes = map[string]key{
+	"[A":  keyUp,
+	"OA":  keyUp,
+	"[B":  keyDown,
+	"OB":  keyDown,
+	"[5~": keyPageUp,
+	"[6~": keyPageDown,
+	"[H":  keyHome,
+	"OH":  keyHome,
+	"[1~": keyHome,
+	"[F":  keyEnd,
+	"OF":  keyEnd,
+	"[4~": keyEnd,
+}
+
+// readKeys sends the keys read from r to keys, and closes keys when r
+// ends.
+func readKeys(r io.Reader, keys chan<- key) {
+	defer close(keys)
+	runes := make(chan rune, 16)
+	go func() {
+		defer close(runes)
+		br := bufio.NewReader(r)
+		for {
+			c, _, err := br.ReadRune()
+			if err != nil {
+				return
+			}
+			runes <- c
+		}
+	}()
+	for c := range runes {
+		switch c {
+		case '\n':
+			keys <- keyEnter
+		case 8:
+			keys <- keyBackspace
+		case rune(keyEscape):
+			k, rest := escape(runes)
+			keys <- k
+			for _, c := range rest {
+				keys <- key(c)
+			}
+		default:
+			keys <- key(c)
+		}
+	}
+}
+
+// escape reads the rest of an escape sequence. For a lone escape or an
+// unknown sequence it returns keyEscape and the runes read after it.
+func escape(runes <-chan rune) (key, []rune) {
+	var seq []rune
+	for {
+		select {
+		case c, ok := <-runes:
+			if !ok {
+				return keyEscape, seq
+			}
+			seq = append(seq, c)
+			if seq[0] != '[' && seq[0] != 'O' {
+				return keyEscape, seq
+			}
+			// Sequences end with a letter or '~' past their first rune.
+			if len(seq) > 1 && (c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '~') {
+				if k, ok := escapes[string(seq)]; ok {
+					return k, nil
+				}
+				return keyEscape, nil
+			}
+			if len(seq) > 8 {
+				return keyEscape, nil
+			}
+		case <-time.After(escapeWait):
+			return keyEscape, seq
+		}
+	}
+}
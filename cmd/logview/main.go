@@ -0,0 +1,124 @@
+// Command logview is a terminal viewer for the log files written by
+// github.com/intellectia/go-log, in the JSON or binary file format:
+//
+//	go run github.com/intellectia/go-log/cmd/logview logs/info.log logs/error.log
+//
+// It shows the entries in the order read, follows the files as they grow
+// and across rotations, and filters the entries live. Keys:
+//
+//	j, k, arrows      move between entries; PgUp, PgDn, g and G jump
+//	enter             show the selected entry with its fields and stack
+//	f                 follow new entries, or stop
+//	/                 show only entries containing a text
+//	l                 cycle the lowest level shown
+//	=                 show only entries matching a query, such as
+//	                  level>=warn AND user_id=42 (see logread.Query)
+//	c                 clear the filters
+//	q                 quit
+//
+// The flags -level, -search and -where set the filters at startup, and
+// -f=false reads the files once without following them. Files ending in
+// .gz are read once. It needs a terminal on Linux or macOS.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/intellectia/go-log/pkg/logger"
+	"github.com/intellectia/go-log/pkg/logread"
+)
+
+// where collects repeated -where flags.
+type where []string
+
+func (w *where) String() string { return strings.Join(*w, " AND ") }
+
+func (w *where) Set(v string) error {
+	*w = append(*w, v)
+	return nil
+}
+
+func main() {
+	follow := flag.Bool("f", true, "follow the files as they grow")
+	level := flag.String("level", "", "show entries of this level and above")
+	search := flag.String("search", "", "show entries containing this text")
+	var queries where
+	flag.Var(&queries, "where", "show entries matching a query, e.g. 'level>=error AND user_id=42'; repeatable")
+	flag.Parse()
+	paths := flag.Args()
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: logview [-f=false] [-level LEVEL] [-search TEXT] [-where QUERY] FILE...")
+		os.Exit(2)
+	}
+
+	v := newViewer()
+	v.follow = *follow
+	v.search = *search
+	if *level != "" {
+		lvl, err := logger.ParseLevel(*level)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "logview: %v\n", err)
+			os.Exit(2)
+		}
+		v.minLevel = &lvl
+	}
+	for _, q := range queries {
+		if err := v.addQuery(q); err != nil {
+			fmt.Fprintf(os.Stderr, "logview: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	term, err := openTerminal()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logview: %v\n", err)
+		os.Exit(1)
+	}
+	defer term.restore()
+
+	entries := make(chan *logread.Entry, 1024)
+	problems := make(chan string, 16)
+	stop := make(chan struct{})
+	defer close(stop)
+	for _, path := range paths {
+		go read(path, *follow, entries, problems, stop)
+	}
+	keys := make(chan key, 16)
+	go readKeys(term.in, keys)
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+
+	v.resize(term.size())
+	redraw := time.NewTicker(50 * time.Millisecond)
+	defer redraw.Stop()
+	dirty := true
+	for {
+		select {
+		case e := <-entries:
+			v.add(e)
+			dirty = true
+		case p := <-problems:
+			v.status = p
+			dirty = true
+		case k, ok := <-keys:
+			if !ok || !v.key(k) {
+				return
+			}
+			dirty = true
+		case <-resized:
+			v.resize(term.size())
+			dirty = true
+		case <-redraw.C:
+			if dirty {
+				term.out.Write(v.render())
+				dirty = false
+			}
+		}
+	}
+}
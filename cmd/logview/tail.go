@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/intellectia/go-log/pkg/logread"
+)
+
+// pollInterval is how often a followed file is checked for new entries.
+const pollInterval = 250 * time.Millisecond
+
+// maxDamaged is the number of errors in a row after which a file is given
+// up, in case they come from reading rather than from damaged entries.
+const maxDamaged = 100
+
+// read sends the entries of the file at path to out, following it as it
+// grows when follow is set, and reports problems.
+func read(path string, follow bool, out chan<- *logread.Entry, problems chan<- string, stop <-chan struct{}) {
+	var r *logread.Reader
+	if follow && !strings.HasSuffix(path, ".gz") {
+		f, err := os.Open(path)
+		if err != nil {
+			problems <- err.Error()
+			return
+		}
+		t := &tailFile{path: path, f: f, stop: stop}
+		defer t.close()
+		r = logread.NewReader(t)
+	} else {
+		var err error
+		if r, err = logread.Open(path); err != nil {
+			problems <- err.Error()
+			return
+		}
+		defer r.Close()
+	}
+
+	damaged := 0
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			if damaged++; damaged >= maxDamaged {
+				problems <- fmt.Sprintf("%s: %v", path, err)
+				return
+			}
+			select {
+			case problems <- fmt.Sprintf("%s: skipped an entry: %v", path, err):
+			default:
+			}
+			continue
+		}
+		damaged = 0
+		select {
+		case out <- e:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tailFile reads a log file that keeps growing: at its end, Read waits for
+// more instead of returning io.EOF, and continues with the new file when
+// the file was rotated away from its path, or from the start when it was
+// truncated. It ends when stop is closed.
+type tailFile struct {
+	path string
+	f    *os.File
+	stop <-chan struct{}
+}
+
+func (t *tailFile) Read(p []byte) (int, error) {
+	for {
+		n, err := t.f.Read(p)
+		if n > 0 || err != nil && !errors.Is(err, io.EOF) {
+			return n, err
+		}
+		if err := t.reopen(); err != nil {
+			return 0, err
+		}
+		select {
+		case <-t.stop:
+			return 0, io.EOF
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// reopen switches to the file now at path, if it changed.
+func (t *tailFile) reopen() error {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		// Rotated away and not created again yet.
+		return nil
+	}
+	current, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+	if os.SameFile(info, current) {
+		if offset, err := t.f.Seek(0, io.SeekCurrent); err == nil && info.Size() < offset {
+			_, err = t.f.Seek(0, io.SeekStart)
+			return err
+		}
+		return nil
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil
+	}
+	t.f.Close()
+	t.f = f
+	return nil
+}
+
+func (t *tailFile) close() error {
+	return t.f.Close()
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+type terminal struct {
+	in, out *os.File
+}
+
+func openTerminal() (*terminal, error) {
+	return nil, errors.New("terminals are only supported on Linux and macOS")
+}
+
+func (t *terminal) size() (width, height int) { return 0, 0 }
+
+func (t *terminal) restore() {}
+
+func notifyResize(c chan<- os.Signal) {}
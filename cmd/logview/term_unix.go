@@ -0,0 +1,69 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// terminal is the controlling terminal, in raw mode on the alternate
+// screen until restore.
+type terminal struct {
+	in, out *os.File
+	saved   syscall.Termios
+}
+
+func openTerminal() (*terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := &terminal{in: tty, out: tty}
+	if err := ioctl(tty, ioctlGetTermios, unsafe.Pointer(&t.saved)); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	raw := t.saved
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(tty, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	tty.WriteString("\x1b[?1049h\x1b[?25l")
+	return t, nil
+}
+
+// size returns the width and height of the terminal, zero if unknown.
+func (t *terminal) size() (width, height int) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if ioctl(t.out, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)) != nil {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// restore leaves the alternate screen and the raw mode.
+func (t *terminal) restore() {
+	t.out.WriteString("\x1b[?25h\x1b[?1049l")
+	ioctl(t.in, ioctlSetTermios, unsafe.Pointer(&t.saved))
+	t.in.Close()
+}
+
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/intellectia/go-log/pkg/logread"
+)
+
+// maxEntries is the number of entries kept; older ones are forgotten.
+const maxEntries = 100000
+
+// stackKeys are the fields rendered line by line in the detail view.
+var stackKeys = []string{"stacktrace", "stack", "panic_stack"}
+
+// query is a filter typed as a logread.Query expression.
+type query struct {
+	expr string
+	keep logread.Filter
+}
+
+// viewer holds the entries read and what the terminal shows of them.
+type viewer struct {
+	entries []*logread.Entry
+	shown   []int // indexes of the entries passing the filters
+
+	minLevel *zapcore.Level
+	search   string
+	queries  []query
+
+	follow bool
+	cursor int // index in shown of the selected entry
+	top    int // index in shown of the first line on screen
+	detail bool
+	scroll int // first line of the detail view on screen
+
+	prompt string // set while a filter is being typed
+	input  []rune
+	status string
+
+	width, height int
+}
+
+func newViewer() *viewer {
+	return &viewer{width: 80, height: 24}
+}
+
+func (v *viewer) resize(width, height int) {
+	if width > 0 && height > 0 {
+		v.width, v.height = width, height
+	}
+	v.place()
+}
+
+// add appends an entry, forgetting the oldest ones past maxEntries.
+func (v *viewer) add(e *logread.Entry) {
+	if len(v.entries) >= maxEntries {
+		drop := maxEntries / 10
+		v.entries = append(v.entries[:0], v.entries[drop:]...)
+		v.filter()
+	}
+	v.entries = append(v.entries, e)
+	if v.keep(e) {
+		v.shown = append(v.shown, len(v.entries)-1)
+		if v.follow {
+			v.cursor = len(v.shown) - 1
+		}
+		v.place()
+	}
+}
+
+func (v *viewer) keep(e *logread.Entry) bool {
+	if v.minLevel != nil && !logread.MinLevel(*v.minLevel)(e) {
+		return false
+	}
+	if v.search != "" && !bytes.Contains(e.Raw, []byte(v.search)) && !strings.Contains(e.Message, v.search) {
+		return false
+	}
+	for _, q := range v.queries {
+		if !q.keep(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// filter recomputes the shown entries, keeping the selected one selected
+// when it still passes.
+func (v *viewer) filter() {
+	selected := -1
+	if v.cursor < len(v.shown) {
+		selected = v.shown[v.cursor]
+	}
+	v.shown = v.shown[:0]
+	v.cursor = 0
+	for i, e := range v.entries {
+		if v.keep(e) {
+			if i <= selected {
+				v.cursor = len(v.shown)
+			}
+			v.shown = append(v.shown, i)
+		}
+	}
+	if v.follow || selected < 0 {
+		v.cursor = len(v.shown) - 1
+	}
+	v.place()
+}
+
+// addQuery adds a filter written as a logread.Query expression.
+func (v *viewer) addQuery(expr string) error {
+	keep, err := logread.Query(expr)
+	if err != nil {
+		return err
+	}
+	v.queries = append(v.queries, query{expr: expr, keep: keep})
+	return nil
+}
+
+// cycleLevel raises the lowest level shown, back to all levels after
+// fatal.
+func (v *viewer) cycleLevel() {
+	switch {
+	case v.minLevel == nil:
+		lvl := zapcore.DebugLevel
+		v.minLevel = &lvl
+	case *v.minLevel >= zapcore.FatalLevel:
+		v.minLevel = nil
+	default:
+		lvl := *v.minLevel + 1
+		v.minLevel = &lvl
+	}
+}
+
+// listHeight is the number of entry lines on screen.
+func (v *viewer) listHeight() int {
+	if v.height < 2 {
+		return 1
+	}
+	return v.height - 1
+}
+
+// place keeps the cursor within the entries and on screen.
+func (v *viewer) place() {
+	if v.cursor >= len(v.shown) {
+		v.cursor = len(v.shown) - 1
+	}
+	if v.cursor < 0 {
+		v.cursor = 0
+	}
+	h := v.listHeight()
+	if v.cursor < v.top {
+		v.top = v.cursor
+	}
+	if v.cursor >= v.top+h {
+		v.top = v.cursor - h + 1
+	}
+	if v.top > len(v.shown)-h {
+		v.top = len(v.shown) - h
+	}
+	if v.top < 0 {
+		v.top = 0
+	}
+}
+
+// move moves the cursor by n entries, or the detail view by n lines.
+// Moving up stops following.
+func (v *viewer) move(n int) {
+	if v.detail {
+		v.scroll += n
+		if v.scroll < 0 {
+			v.scroll = 0
+		}
+		return
+	}
+	if n < 0 {
+		v.follow = false
+	}
+	v.cursor += n
+	v.place()
+}
+
+// key handles a key press and reports whether to go on.
+func (v *viewer) key(k key) bool {
+	v.status = ""
+	if v.prompt != "" {
+		v.edit(k)
+		return true
+	}
+	page := v.listHeight() - 1
+	if page < 1 {
+		page = 1
+	}
+	switch k {
+	case keyCtrlC, 'q':
+		return false
+	case 'j', keyDown:
+		v.move(1)
+	case 'k', keyUp:
+		v.move(-1)
+	case keyPageDown, ' ':
+		v.move(page)
+	case keyPageUp, 'b':
+		v.move(-page)
+	case 'g', keyHome:
+		v.follow = false
+		v.cursor, v.scroll = 0, 0
+		v.place()
+	case 'G', keyEnd:
+		v.cursor = len(v.shown) - 1
+		v.place()
+	case 'f':
+		v.follow = !v.follow
+		if v.follow {
+			v.cursor = len(v.shown) - 1
+			v.place()
+		}
+	case keyEnter:
+		v.detail = !v.detail && len(v.shown) > 0
+		v.scroll = 0
+	case keyEscape:
+		v.detail = false
+	case '/':
+		v.prompt, v.input = "search: ", []rune(v.search)
+	case '=':
+		v.prompt, v.input = "query: ", nil
+	case 'l':
+		v.cycleLevel()
+		v.filter()
+	case 'c':
+		v.minLevel, v.search, v.queries = nil, "", nil
+		v.filter()
+	}
+	return true
+}
+
+// edit handles a key press while a filter is typed.
+func (v *viewer) edit(k key) {
+	switch {
+	case k == keyEscape || k == keyCtrlC:
+		v.prompt = ""
+	case k == keyEnter:
+		text := string(v.input)
+		if strings.HasPrefix(v.prompt, "search") {
+			v.search = text
+		} else if text != "" {
+			if err := v.addQuery(text); err != nil {
+				v.status = err.Error()
+			}
+		}
+		v.prompt = ""
+		v.filter()
+	case k == keyBackspace:
+		if len(v.input) > 0 {
+			v.input = v.input[:len(v.input)-1]
+		}
+	case k >= ' ' && k < keyUp:
+		v.input = append(v.input, rune(k))
+	}
+}
+
+// render returns the escape sequences drawing the screen.
+func (v *viewer) render() []byte {
+	var b bytes.Buffer
+	b.WriteString("\x1b[H\x1b[2J")
+	if v.detail && v.cursor < len(v.shown) {
+		v.renderDetail(&b, v.entries[v.shown[v.cursor]])
+	} else {
+		v.renderList(&b)
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H", v.height)
+	if v.prompt != "" {
+		b.WriteString(fit(v.prompt+string(v.input), v.width))
+		b.WriteString("\x1b[?25h")
+	} else {
+		b.WriteString("\x1b[7m" + pad(fit(v.statusLine(), v.width), v.width) + "\x1b[0m")
+		b.WriteString("\x1b[?25l")
+	}
+	return b.Bytes()
+}
+
+func (v *viewer) renderList(b *bytes.Buffer) {
+	for row := 0; row < v.listHeight(); row++ {
+		i := v.top + row
+		if i >= len(v.shown) {
+			break
+		}
+		fmt.Fprintf(b, "\x1b[%d;1H", row+1)
+		e := v.entries[v.shown[i]]
+		line := fit(summary(e), v.width-6)
+		if i == v.cursor {
+			b.WriteString("\x1b[7m" + levelName(e) + " " + pad(line, v.width-6) + "\x1b[0m")
+			continue
+		}
+		b.WriteString(levelColor(e.Level) + levelName(e) + "\x1b[0m " + line)
+	}
+}
+
+func (v *viewer) renderDetail(b *bytes.Buffer, e *logread.Entry) {
+	var lines []string
+	lines = append(lines, e.Time.Format("2006-01-02 15:04:05.000000 -0700")+"  "+strings.TrimSpace(levelName(e)))
+	if e.Logger != "" {
+		lines = append(lines, "logger: "+e.Logger)
+	}
+	if e.Caller != "" {
+		lines = append(lines, "caller: "+e.Caller)
+	}
+	lines = append(lines, "", e.Message, "")
+	var stacks []string
+	for _, k := range sortedKeys(e) {
+		if isStack(k) {
+			stacks = append(stacks, k)
+			continue
+		}
+		var out bytes.Buffer
+		if json.Indent(&out, e.Fields[k], "  ", "  ") != nil {
+			out.Reset()
+			out.Write(e.Fields[k])
+		}
+		lines = append(lines, strings.Split(k+": "+out.String(), "\n")...)
+	}
+	for _, k := range stacks {
+		var stack string
+		if e.Field(k, &stack) != nil {
+			stack = string(e.Fields[k])
+		}
+		lines = append(lines, "", k+":")
+		for _, l := range strings.Split(strings.TrimRight(stack, "\n"), "\n") {
+			// Frames are a function line then an indented file:line.
+			if strings.HasPrefix(l, "\t") {
+				lines = append(lines, "\x1b[2m    "+strings.TrimPrefix(l, "\t")+"\x1b[0m")
+			} else {
+				lines = append(lines, "  "+l)
+			}
+		}
+	}
+	if last := len(lines) - v.listHeight(); v.scroll > last {
+		v.scroll = last
+		if v.scroll < 0 {
+			v.scroll = 0
+		}
+	}
+	for row := 0; row < v.listHeight() && v.scroll+row < len(lines); row++ {
+		fmt.Fprintf(b, "\x1b[%d;1H", row+1)
+		b.WriteString(fit(lines[v.scroll+row], v.width))
+	}
+}
+
+func (v *viewer) statusLine() string {
+	if v.status != "" {
+		return v.status
+	}
+	parts := []string{fmt.Sprintf("%d/%d", len(v.shown), len(v.entries))}
+	if v.follow {
+		parts = append(parts, "following")
+	}
+	if v.minLevel != nil {
+		parts = append(parts, "level>="+v.minLevel.String())
+	}
+	if v.search != "" {
+		parts = append(parts, "search="+v.search)
+	}
+	for _, q := range v.queries {
+		parts = append(parts, "("+q.expr+")")
+	}
+	parts = append(parts, "| q quit, enter details, / search, l level, = query, c clear, f follow")
+	return strings.Join(parts, "  ")
+}
+
+// summary is the line of an entry in the list, after its level.
+func summary(e *logread.Entry) string {
+	var b strings.Builder
+	b.WriteString(e.Time.Format("15:04:05.000"))
+	if e.Logger != "" {
+		b.WriteString(" " + e.Logger + ":")
+	}
+	b.WriteString(" " + e.Message)
+	for _, k := range sortedKeys(e) {
+		if isStack(k) {
+			continue
+		}
+		b.WriteString(" " + k + "=" + string(e.Fields[k]))
+	}
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 127 {
+			return ' '
+		}
+		return r
+	}, b.String())
+}
+
+func sortedKeys(e *logread.Entry) []string {
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isStack(key string) bool {
+	for _, k := range stackKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// levelName is the level of an entry, padded to five characters.
+func levelName(e *logread.Entry) string {
+	name := "?"
+	if e.Level != zapcore.InvalidLevel {
+		name = e.Level.CapitalString()
+	}
+	return fmt.Sprintf("%-5.5s", name)
+}
+
+func levelColor(lvl zapcore.Level) string {
+	switch {
+	case lvl == zapcore.InvalidLevel:
+		return ""
+	case lvl < zapcore.InfoLevel:
+		return "\x1b[35m"
+	case lvl < zapcore.WarnLevel:
+		return "\x1b[34m"
+	case lvl < zapcore.ErrorLevel:
+		return "\x1b[33m"
+	}
+	return "\x1b[31m"
+}
+
+// fit cuts s to width characters, escape sequences aside.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	n := 0
+	inEscape := false
+	for i, r := range s {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			inEscape = r < '@' || r > '~' || r == '['
+		default:
+			if n == width {
+				return s[:i] + "\x1b[0m"
+			}
+			n++
+		}
+	}
+	return s
+}
+
+// pad fills s with spaces up to width characters.
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
@@ -10,7 +10,8 @@ require (
 )
 
 require (
+	github.com/klauspost/compress v1.17.4
 	github.com/natefinch/lumberjack v2.0.0+incompatible
-	go.uber.org/multierr v1.10.0 // indirect
+	go.uber.org/multierr v1.10.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
 )
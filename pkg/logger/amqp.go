@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// AMQPPublisher is a channel to an AMQP broker such as RabbitMQ, usually a
+// thin adapter over an amqp091-go channel put in confirm mode.
+type AMQPPublisher interface {
+	// Publish sends body to exchange with routing key key.
+	Publish(ctx context.Context, exchange, key string, body []byte) error
+	// WaitConfirms returns once the broker has confirmed every message
+	// published so far, or fails if any was nacked.
+	WaitConfirms(ctx context.Context) error
+	Close() error
+}
+
+// AMQPConfig configures a sink that publishes every entry to an exchange.
+type AMQPConfig struct {
+	Exchange   string
+	RoutingKey string
+
+	// Connect opens a new publisher. It is called for the first batch and
+	// again after any failure, so a dropped connection is re-established
+	// and the batch resent.
+	Connect func(ctx context.Context) (AMQPPublisher, error)
+
+	Delivery DeliveryConfig
+}
+
+// NewAMQPSink returns a sink publishing to cfg.Exchange with publisher
+// confirms.
+func NewAMQPSink(name string, cfg AMQPConfig) (*Delivery, error) {
+	if cfg.Connect == nil {
+		return nil, errors.New("amqp sink: no connect function")
+	}
+	return NewDelivery(name, &amqpTransport{cfg: cfg}, cfg.Delivery)
+}
+
+type amqpTransport struct {
+	cfg AMQPConfig
+	pub AMQPPublisher
+}
+
+func (t *amqpTransport) Send(ctx context.Context, batch [][]byte) error {
+	if t.pub == nil {
+		pub, err := t.cfg.Connect(ctx)
+		if err != nil {
+			return fmt.Errorf("connect: %w", err)
+		}
+		t.pub = pub
+	}
+	err := t.publish(ctx, batch)
+	if err != nil {
+		t.pub.Close()
+		t.pub = nil
+	}
+	return err
+}
+
+func (t *amqpTransport) publish(ctx context.Context, batch [][]byte) error {
+	for _, entry := range batch {
+		if err := t.pub.Publish(ctx, t.cfg.Exchange, t.cfg.RoutingKey, entry); err != nil {
+			return err
+		}
+	}
+	return t.pub.WaitConfirms(ctx)
+}
+
+func (t *amqpTransport) Close() error {
+	if t.pub == nil {
+		return nil
+	}
+	err := t.pub.Close()
+	t.pub = nil
+	return err
+}
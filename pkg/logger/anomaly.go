@@ -0,0 +1,284 @@
+package logger
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Defaults of ErrorAnomalies.
+const (
+	defaultAnomalyWindow          = time.Minute
+	defaultAnomalyFactor          = 3
+	defaultAnomalyMinErrors       = 10
+	defaultAnomalyBaselineWindows = 30
+)
+
+// anomalyWarmupWindows is the number of windows after the logger is built
+// during which no anomaly is reported, while the baselines settle.
+const anomalyWarmupWindows = 3
+
+// maxAnomalyLoggers bounds the logger names tracked; errors of names past
+// it are not.
+const maxAnomalyLoggers = 1000
+
+// ErrorAnomalies detects spikes in the rate of errors of each logger name
+// (see Logger.Named), for lightweight alerting without external
+// infrastructure. When the errors of a logger within a Window reach
+// Factor times its baseline, and at least MinErrors, one "error rate
+// anomaly" entry is logged at error level under the logger's name, and the
+// functions given to OnErrorAnomaly are called. The next anomaly of that
+// logger is reported after a window ends below the threshold again.
+type ErrorAnomalies struct {
+	Enabled bool
+
+	// Window is the period errors are counted over, a minute by default.
+	Window time.Duration
+
+	// Factor is how many times its baseline the errors of a window must
+	// reach, 3 by default.
+	Factor float64
+
+	// MinErrors is the fewest errors in a window reported, 10 by default,
+	// so a rare error logged twice in a row is not an anomaly.
+	MinErrors int
+
+	// BaselineWindows is about the number of windows the baseline, a
+	// moving average of the errors per window, remembers; 30 by default.
+	BaselineWindows int
+}
+
+func (a ErrorAnomalies) validate() error {
+	if a.Window < 0 {
+		return errors.New("negative window")
+	}
+	if a.Factor != 0 && a.Factor <= 1 {
+		return errors.New("factor must be above 1")
+	}
+	if a.MinErrors < 0 {
+		return errors.New("negative min errors")
+	}
+	if a.BaselineWindows < 0 {
+		return errors.New("negative baseline windows")
+	}
+	return nil
+}
+
+// withDefaults returns a with its zero values defaulted.
+func (a ErrorAnomalies) withDefaults() ErrorAnomalies {
+	if a.Window <= 0 {
+		a.Window = defaultAnomalyWindow
+	}
+	if a.Factor == 0 {
+		a.Factor = defaultAnomalyFactor
+	}
+	if a.MinErrors == 0 {
+		a.MinErrors = defaultAnomalyMinErrors
+	}
+	if a.BaselineWindows == 0 {
+		a.BaselineWindows = defaultAnomalyBaselineWindows
+	}
+	return a
+}
+
+// ErrorAnomaly reports a spike in the errors of a logger.
+type ErrorAnomaly struct {
+	Logger   string // empty for the unnamed logger
+	Time     time.Time
+	Window   time.Duration
+	Errors   int     // errors in the window so far
+	Baseline float64 // errors per window usually
+}
+
+// anomalyDetector tracks the error rates of the logger names, shared by an
+// anomalyCore and its children.
+type anomalyDetector struct {
+	config ErrorAnomalies
+	start  int64 // window the logger was built in
+
+	mu      sync.Mutex
+	loggers map[string]*errorRate
+
+	hooksMu sync.Mutex
+	hooks   []*func(ErrorAnomaly)
+}
+
+func newAnomalyDetector(config ErrorAnomalies) *anomalyDetector {
+	config = config.withDefaults()
+	return &anomalyDetector{
+		config:  config,
+		start:   time.Now().UnixNano() / int64(config.Window),
+		loggers: make(map[string]*errorRate),
+	}
+}
+
+// errorRate is the error count and baseline of one logger name.
+type errorRate struct {
+	window   int64 // current window
+	count    int   // errors in it
+	windows  int64 // windows averaged into baseline
+	baseline float64
+	spiking  bool // an anomaly was reported and has not ended
+}
+
+// observe counts an error of the logger name at t, returning the anomaly
+// it makes, if any.
+func (d *anomalyDetector) observe(name string, t time.Time) *ErrorAnomaly {
+	window := t.UnixNano() / int64(d.config.Window)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := d.loggers[name]
+	if r == nil {
+		if len(d.loggers) >= maxAnomalyLoggers {
+			return nil
+		}
+		// Silent until now: the windows since the start had no errors.
+		r = &errorRate{window: window, windows: window - d.start}
+		if r.windows < 0 {
+			r.windows = 0
+		}
+		d.loggers[name] = r
+	}
+	if window > r.window {
+		d.close(r, window-r.window)
+		r.window, r.count = window, 0
+	}
+	r.count++
+	if r.spiking || window-d.start < anomalyWarmupWindows || float64(r.count) < d.threshold(r) {
+		return nil
+	}
+	r.spiking = true
+	return &ErrorAnomaly{
+		Logger:   name,
+		Time:     t,
+		Window:   d.config.Window,
+		Errors:   r.count,
+		Baseline: r.baseline,
+	}
+}
+
+// threshold is the errors per window that make an anomaly.
+func (d *anomalyDetector) threshold(r *errorRate) float64 {
+	t := d.config.Factor * r.baseline
+	if floor := float64(d.config.MinErrors); t < floor {
+		return floor
+	}
+	return t
+}
+
+// close averages the current window of r into its baseline, followed by
+// n-1 windows without errors, and ends a spike unless the current window
+// was still over the threshold.
+func (d *anomalyDetector) close(r *errorRate, n int64) {
+	if n > 1 || float64(r.count) < d.threshold(r) {
+		r.spiking = false
+	}
+	// Past a few times the memory of the average, the baseline is gone.
+	if limit := int64(4 * d.config.BaselineWindows); n > limit {
+		r.windows += n - limit
+		n = limit
+	}
+	count := float64(r.count)
+	for i := int64(0); i < n; i++ {
+		r.windows++
+		// A plain average until the baseline has enough windows, so
+		// it does not start from zero, then an exponential one.
+		weight := 2 / float64(d.config.BaselineWindows+1)
+		if r.windows < int64(d.config.BaselineWindows) {
+			weight = 1 / float64(r.windows)
+		}
+		r.baseline += weight * (count - r.baseline)
+		count = 0
+	}
+}
+
+// onAnomaly registers fn, returning a function that removes it.
+func (d *anomalyDetector) onAnomaly(fn func(ErrorAnomaly)) (stop func()) {
+	hook := &fn
+	d.hooksMu.Lock()
+	d.hooks = append(d.hooks, hook)
+	d.hooksMu.Unlock()
+	return func() {
+		d.hooksMu.Lock()
+		defer d.hooksMu.Unlock()
+		for i, h := range d.hooks {
+			if h == hook {
+				d.hooks = append(d.hooks[:i:i], d.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (d *anomalyDetector) notify(a ErrorAnomaly) {
+	d.hooksMu.Lock()
+	hooks := d.hooks
+	d.hooksMu.Unlock()
+	for _, h := range hooks {
+		(*h)(a)
+	}
+}
+
+// anomalyCore counts the errors written through it in an anomalyDetector
+// and logs the anomalies it detects.
+type anomalyCore struct {
+	zapcore.Core
+	detector *anomalyDetector
+}
+
+func (c anomalyCore) With(fields []zapcore.Field) zapcore.Core {
+	return anomalyCore{Core: c.Core.With(fields), detector: c.detector}
+}
+
+func (c anomalyCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < zapcore.ErrorLevel {
+		return c.Core.Check(ent, ce)
+	}
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c anomalyCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	if a := c.detector.observe(ent.LoggerName, ent.Time); a != nil {
+		c.writeAnomaly(*a)
+		c.detector.notify(*a)
+	}
+	return nil
+}
+
+// writeAnomaly logs a, bypassing the detector.
+func (c anomalyCore) writeAnomaly(a ErrorAnomaly) {
+	ent := zapcore.Entry{
+		Level:      zapcore.ErrorLevel,
+		Time:       a.Time,
+		LoggerName: a.Logger,
+		Message:    "error rate anomaly",
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(
+			zap.Int("errors", a.Errors),
+			zap.Duration("window", a.Window),
+			zap.Float64("baseline", a.Baseline),
+		)
+	}
+}
+
+// OnErrorAnomaly calls fn with every anomaly found by Config.ErrorAnomalies,
+// after it is logged, to page someone or dump diagnostics. fn is called
+// from the goroutine that logged the error and should return quickly.
+// Without ErrorAnomalies it is never called. It returns a function that
+// stops the calls.
+func (l *Logger) OnErrorAnomaly(fn func(ErrorAnomaly)) (stop func()) {
+	if l.anomalies == nil {
+		return func() {}
+	}
+	return l.anomalies.onAnomaly(fn)
+}
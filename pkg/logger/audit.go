@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AuditLevel is for security-relevant events that must never be dropped.
+// Audit entries bypass sampling, Level, level overrides and SetLevel, and
+// go to Config.AuditLogPath and Config.AuditSinks; without those they go
+// to the regular outputs that accept errors.
+const AuditLevel = zapcore.FatalLevel + 2
+
+func init() {
+	RegisterLevel("audit", AuditLevel, Severity{Syslog: 5, OTLP: 10, GCP: "NOTICE"})
+}
+
+// Audit logs a security-relevant event at AuditLevel.
+func (l *Logger) Audit(msg string, tags ...zap.Field) {
+	l.zap.Log(AuditLevel, msg, tags...)
+}
+
+func Audit(msg string, tags ...zap.Field) {
+	logInstance.Load().Audit(msg, tags...)
+}
+
+// newAuditOutputs returns the cores and outputs of the audit log and
+// sinks, nil without them.
+func newAuditOutputs(config *Config, settings settings, fileFormat, sinkFormat outputFormat, encoderConfig zapcore.EncoderConfig) ([]zapcore.Core, []output) {
+	var (
+		cores   []zapcore.Core
+		outputs []output
+	)
+	if config.AuditLogPath != "" {
+		core, out := newFileOutput("audit log", config.AuditLogPath, config.AuditLogFsync, settings,
+			fileFormat.named("audit log").wrap(newJSONEncoder(fileFormat.encoderConfig(encoderConfig))), allLevels)
+		cores = append(cores, core)
+		outputs = append(outputs, out)
+	}
+	for _, sink := range config.AuditSinks {
+		cores = append(cores, newSinkCore(sink, sinkFormat.encoderConfig(encoderConfig), sinkFormat.named("audit sink "+sink.Name())))
+		outputs = append(outputs, output{name: "audit sink " + sink.Name(), sync: sink.Sync, close: sink.Close, sink: sink})
+	}
+	return cores, outputs
+}
+
+// auditCore sends AuditLevel entries straight to the audit outputs and the
+// rest through the regular core with its sampling and level filters.
+type auditCore struct {
+	zapcore.Core
+	audit zapcore.Core
+}
+
+func (c auditCore) Enabled(lvl zapcore.Level) bool {
+	return lvl == AuditLevel || c.Core.Enabled(lvl)
+}
+
+func (c auditCore) With(fields []zapcore.Field) zapcore.Core {
+	return auditCore{Core: c.Core.With(fields), audit: c.audit.With(fields)}
+}
+
+func (c auditCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level == AuditLevel {
+		return c.audit.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func (c auditCore) Sync() error {
+	return multierr.Combine(c.Core.Sync(), c.audit.Sync())
+}
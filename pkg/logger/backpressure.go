@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Backpressure reports that the queue of a sink filled up past the
+// threshold given to OnBackpressure, or drained again.
+type Backpressure struct {
+	Sink      string
+	Fill      float64 // fraction of the queue in use
+	Saturated bool    // false once the queue drained below half the threshold
+}
+
+// pressureSource is implemented by sinks with a bounded queue.
+type pressureSource interface {
+	queueFill() float64
+	watchPressure(w *pressureWatch) (stop func())
+}
+
+// pressureWatch calls fn as a queue crosses threshold.
+type pressureWatch struct {
+	threshold float64
+	fn        func(Backpressure)
+	saturated atomic.Bool
+}
+
+// check calls fn if fill crossed the threshold since the last check: up
+// past it, or down below half of it, so a queue hovering around the
+// threshold does not flap.
+func (w *pressureWatch) check(sink string, fill float64) {
+	switch {
+	case fill >= w.threshold:
+		if w.saturated.CompareAndSwap(false, true) {
+			w.fn(Backpressure{Sink: sink, Fill: fill, Saturated: true})
+		}
+	case fill < w.threshold/2:
+		if w.saturated.CompareAndSwap(true, false) {
+			w.fn(Backpressure{Sink: sink, Fill: fill})
+		}
+	}
+}
+
+// pressureWatches are the watches of a queue.
+type pressureWatches struct {
+	mu      sync.Mutex
+	watches []*pressureWatch
+}
+
+func (p *pressureWatches) add(w *pressureWatch) (stop func()) {
+	p.mu.Lock()
+	p.watches = append(p.watches, w)
+	p.mu.Unlock()
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, x := range p.watches {
+			if x == w {
+				p.watches = append(p.watches[:i:i], p.watches[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (p *pressureWatches) check(sink string, fill func() float64) {
+	p.mu.Lock()
+	watches := p.watches
+	p.mu.Unlock()
+	if len(watches) == 0 {
+		return
+	}
+	f := fill()
+	for _, w := range watches {
+		w.check(sink, f)
+	}
+}
+
+// OnBackpressure calls fn when the queue of a sink fills past threshold,
+// a fraction of its capacity such as 0.8, and again once it drained below
+// half the threshold, so the application can shed optional logging or
+// slow down producers before entries are dropped. fn is called from the
+// goroutine that logged or delivered the entries and should return
+// quickly. Sinks with a WAL, which has no fixed capacity in entries, are
+// not watched. It returns a function that stops the calls.
+func (l *Logger) OnBackpressure(threshold float64, fn func(Backpressure)) (stop func()) {
+	var stops []func()
+	for _, o := range l.outputs {
+		if s, ok := o.sink.(pressureSource); ok {
+			stops = append(stops, s.watchPressure(&pressureWatch{threshold: threshold, fn: fn}))
+		}
+	}
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
+
+// QueueFill returns the fraction in use of the fullest sink queue, from 0
+// to 1, for a cheap check before optional logging:
+//
+//	if log.QueueFill() < 0.5 {
+//		log.Debug("cache miss", zap.String("key", key))
+//	}
+func (l *Logger) QueueFill() float64 {
+	var max float64
+	for _, o := range l.outputs {
+		if s, ok := o.sink.(pressureSource); ok {
+			if f := s.queueFill(); f > max {
+				max = f
+			}
+		}
+	}
+	return max
+}
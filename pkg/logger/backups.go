@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// backupMill looks after the rotated backups of a log file: it compresses
+// them, removes those beyond the rotation limits lumberjack cannot see once
+// compressed, and keeps the checksum manifest. lumberjack has no rotation
+// hook, so the mill follows the bytes written to predict rotations.
+type backupMill struct {
+	path     string // the log file
+	maxBytes int64
+	keep     rotation
+	perm     FilePermissions
+
+	compressor *Compressor       // nil leaves backups as they are
+	manifest   *checksumManifest // nil without checksums
+
+	// pruneBackups applies the rotation limits to every backup, for files
+	// rotated without lumberjack.
+	pruneBackups bool
+
+	mu   sync.Mutex
+	size int64 // of the current file, -1 when unknown
+
+	runMu sync.Mutex
+}
+
+func newBackupMill(path string, rot rotation, perm FilePermissions) *backupMill {
+	megabytes := rot.megabytes
+	if megabytes <= 0 {
+		megabytes = 100 // lumberjack's default
+	}
+	return &backupMill{path: path, maxBytes: int64(megabytes) << 20, keep: rot, perm: perm, size: -1}
+}
+
+// start processes the backups already there, in the background.
+func (m *backupMill) start() {
+	go m.run()
+}
+
+// wrote records a write of n bytes, processing the backups in the
+// background when it made lumberjack rotate.
+func (m *backupMill) wrote(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.size < 0 {
+		m.size = 0
+		if info, err := os.Stat(m.path); err == nil {
+			m.size = info.Size()
+		}
+		return
+	}
+	if m.size+int64(n) > m.maxBytes {
+		m.size = int64(n)
+		go m.run()
+		return
+	}
+	m.size += int64(n)
+}
+
+// reopened forgets the size of the file, which may have been replaced.
+func (m *backupMill) reopened() {
+	m.mu.Lock()
+	m.size = -1
+	m.mu.Unlock()
+	go m.run()
+}
+
+// run compresses and prunes the backups, then lists them in the manifest.
+func (m *backupMill) run() {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	if m.compressor != nil {
+		if err := m.compress(); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: %s: compressing backups: %v\n", m.path, err)
+		}
+	} else if m.pruneBackups {
+		names, err := backupNames(m.path, "")
+		if err == nil {
+			err = m.prune(names)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "logger: %s: removing backups: %v\n", m.path, err)
+		}
+	}
+	if m.manifest != nil {
+		m.manifest.update()
+	}
+}
+
+// compress compresses the backups not compressed yet, then removes the
+// backups beyond the rotation limits, which lumberjack only applies to
+// backups it recognizes: plain and gzipped ones.
+func (m *backupMill) compress() error {
+	names, err := backupNames(m.path, m.compressor.Ext)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(m.path)
+	var errs []error
+	for i, name := range names {
+		if strings.HasSuffix(name, m.compressor.Ext) {
+			continue
+		}
+		// lumberjack may remove the backup meanwhile.
+		if err := m.compressFile(filepath.Join(dir, name)); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		names[i] = name + m.compressor.Ext
+	}
+	if m.compressor.Ext != gzipCompressor.Ext || m.pruneBackups {
+		errs = append(errs, m.prune(names))
+	}
+	return multierr.Combine(errs...)
+}
+
+// compressFile replaces the file at path by a compressed copy, with its
+// mode and the owner of the log files.
+func (m *backupMill) compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	info, err := src.Stat()
+	if err != nil {
+		src.Close()
+		return err
+	}
+	dst := path + m.compressor.Ext
+	tmp := dst + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	err = m.copyCompressed(f, src)
+	// Windows cannot remove the backup while it is open.
+	src.Close()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = m.perm.chown(tmp)
+	}
+	if err == nil {
+		err = retryShared(func() error { return os.Rename(tmp, dst) })
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return retryShared(func() error { return os.Remove(path) })
+}
+
+func (m *backupMill) copyCompressed(dst io.Writer, src io.Reader) error {
+	zw, err := m.compressor.NewWriter(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// prune removes the oldest backups beyond keep.backups and those older
+// than keep.days, as lumberjack would.
+func (m *backupMill) prune(names []string) error {
+	var remove []string
+	if m.keep.backups > 0 && len(names) > m.keep.backups {
+		remove = append(remove, names[:len(names)-m.keep.backups]...)
+		names = names[len(names)-m.keep.backups:]
+	}
+	if m.keep.days > 0 {
+		cutoff := time.Now().Add(-time.Duration(m.keep.days) * 24 * time.Hour)
+		for _, name := range names {
+			if t, ok := backupTime(m.path, name, m.compressedExt()); ok && t.Before(cutoff) {
+				remove = append(remove, name)
+			}
+		}
+	}
+	var errs []error
+	for _, name := range remove {
+		err := retryShared(func() error { return os.Remove(filepath.Join(filepath.Dir(m.path), name)) })
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+func (m *backupMill) compressedExt() string {
+	if m.compressor == nil {
+		return ""
+	}
+	return m.compressor.Ext
+}
+
+// backupNames returns the names of the rotated backups of the log file at
+// path, oldest first, as lumberjack names them: "name-<time>.ext", possibly
+// gzipped or compressed with the extension compressedExt.
+func backupNames(path, compressedExt string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if _, ok := backupTime(path, e.Name(), compressedExt); ok {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// backupTime returns when name, a backup of the log file at path, was
+// rotated out.
+func backupTime(path, name, compressedExt string) (time.Time, bool) {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	if !strings.HasPrefix(name, prefix) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), gzipCompressor.Ext)
+	if compressedExt != "" {
+		stamp = strings.TrimSuffix(stamp, compressedExt)
+	}
+	if !strings.HasSuffix(stamp, ext) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext))
+	return t, err == nil
+}
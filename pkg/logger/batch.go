@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type batchEntry struct {
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// Batch accumulates entries and writes them together, so jobs emitting
+// many entries in a tight loop encode each output's entries into one
+// buffer and take its lock once. Entries keep the time they were added
+// at. A Batch is not safe for concurrent use.
+type Batch struct {
+	l       *Logger
+	entries []batchEntry
+}
+
+// Batch returns an empty batch writing through l.
+func (l *Logger) Batch() *Batch {
+	return &Batch{l: l}
+}
+
+func (b *Batch) Debug(msg string, fields ...zap.Field) {
+	b.add(zapcore.DebugLevel, msg, fields)
+}
+
+func (b *Batch) Info(msg string, fields ...zap.Field) {
+	b.add(zapcore.InfoLevel, msg, fields)
+}
+
+func (b *Batch) Warn(msg string, fields ...zap.Field) {
+	b.add(zapcore.WarnLevel, msg, fields)
+}
+
+// Error adds an entry with the same error fields and classification as
+// Logger.Error.
+func (b *Batch) Error(msg string, err error, fields ...zap.Field) {
+	if err == nil {
+		b.add(zapcore.ErrorLevel, msg, b.l.stackFields(zapcore.ErrorLevel, b.l.nilErrorFields(fields)))
+		return
+	}
+	lvl, fields := b.l.errorEntry(err, fields)
+	b.add(lvl, msg, fields)
+}
+
+func (b *Batch) add(lvl zapcore.Level, msg string, fields []zap.Field) {
+	if !b.l.zap.Core().Enabled(lvl) {
+		return
+	}
+	b.entries = append(b.entries, batchEntry{
+		ent: zapcore.Entry{
+			Level:      lvl,
+			Time:       b.l.now(),
+			LoggerName: b.l.zap.Name(),
+			Message:    msg,
+		},
+		fields: fields,
+	})
+}
+
+// now reads the clock of the logger, the system clock unless set by
+// WithClock.
+func (l *Logger) now() time.Time {
+	if l.clock != nil {
+		return l.clock.Now()
+	}
+	return time.Now()
+}
+
+// Len returns the number of entries waiting to be written.
+func (b *Batch) Len() int {
+	return len(b.entries)
+}
+
+// Write writes the accumulated entries and empties the batch so it can be
+// reused.
+func (b *Batch) Write() error {
+	if len(b.entries) == 0 {
+		return nil
+	}
+	err := writeBatch(b.l.zap.Core(), b.entries)
+	for i := range b.entries {
+		b.entries[i] = batchEntry{}
+	}
+	b.entries = b.entries[:0]
+	return err
+}
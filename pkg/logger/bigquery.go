@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BigQueryRow is one entry mapped onto table columns.
+type BigQueryRow map[string]interface{}
+
+// BigQueryConfig configures a sink streaming entries into a BigQuery table.
+// Append writes the rows with the client of the caller's choice, typically
+// by converting them to the table's protobuf descriptor and appending them
+// to a Storage Write API managed stream, so the logger does not depend on
+// the Google Cloud libraries.
+type BigQueryConfig struct {
+	Append func(ctx context.Context, rows []BigQueryRow) error
+
+	// Rows always carry the timestamp, level and message columns. Fields
+	// named in Columns become the mapped column; all other fields are
+	// stored as a JSON object in FieldsColumn (default "fields").
+	TimestampColumn string // default "timestamp"
+	LevelColumn     string // default "level"
+	MessageColumn   string // default "message"
+	FieldsColumn    string
+	Columns         map[string]string
+
+	// A quota error pauses appends for QuotaBackoff (default 1m) instead of
+	// retrying at the usual pace. IsQuotaError recognizes them, by default
+	// by the "quota", "rateLimitExceeded" and "RESOURCE_EXHAUSTED" markers.
+	QuotaBackoff time.Duration
+	IsQuotaError func(error) bool
+
+	Delivery DeliveryConfig
+}
+
+// NewBigQuerySink returns a sink appending each batch with one call to
+// cfg.Append.
+func NewBigQuerySink(name string, cfg BigQueryConfig) (*Delivery, error) {
+	if cfg.Append == nil {
+		return nil, errors.New("bigquery sink: no append function")
+	}
+	if cfg.TimestampColumn == "" {
+		cfg.TimestampColumn = "timestamp"
+	}
+	if cfg.LevelColumn == "" {
+		cfg.LevelColumn = "level"
+	}
+	if cfg.MessageColumn == "" {
+		cfg.MessageColumn = "message"
+	}
+	if cfg.FieldsColumn == "" {
+		cfg.FieldsColumn = "fields"
+	}
+	if cfg.QuotaBackoff <= 0 {
+		cfg.QuotaBackoff = time.Minute
+	}
+	if cfg.IsQuotaError == nil {
+		cfg.IsQuotaError = isQuotaError
+	}
+	return NewDelivery(name, &bigQueryTransport{cfg: cfg}, cfg.Delivery)
+}
+
+func isQuotaError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "quota") ||
+		strings.Contains(msg, "rateLimitExceeded") ||
+		strings.Contains(msg, "RESOURCE_EXHAUSTED")
+}
+
+var errQuotaPause = errors.New("paused after quota error")
+
+type bigQueryTransport struct {
+	cfg        BigQueryConfig
+	pauseUntil time.Time
+}
+
+func (t *bigQueryTransport) Send(ctx context.Context, batch [][]byte) error {
+	if time.Now().Before(t.pauseUntil) {
+		return errQuotaPause
+	}
+	rows := make([]BigQueryRow, 0, len(batch))
+	for _, entry := range batch {
+		e, err := decodeEntry(entry, entryKeysFrom(ctx))
+		if err != nil {
+			continue
+		}
+		rows = append(rows, t.row(e))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	err := t.cfg.Append(ctx, rows)
+	if err != nil && t.cfg.IsQuotaError(err) {
+		t.pauseUntil = time.Now().Add(t.cfg.QuotaBackoff)
+		return fmt.Errorf("quota exceeded, pausing for %s: %w", t.cfg.QuotaBackoff, err)
+	}
+	return err
+}
+
+func (t *bigQueryTransport) row(e decodedEntry) BigQueryRow {
+	row := BigQueryRow{
+		t.cfg.TimestampColumn: e.Time,
+		t.cfg.LevelColumn:     e.Level,
+		t.cfg.MessageColumn:   e.Message,
+	}
+	for key, column := range t.cfg.Columns {
+		raw, ok := e.Fields[key]
+		if !ok {
+			continue
+		}
+		var v interface{}
+		if json.Unmarshal(raw, &v) == nil {
+			row[column] = v
+		}
+		delete(e.Fields, key)
+	}
+	row[t.cfg.FieldsColumn] = string(e.fieldsJSON())
+	return row
+}
+
+func (t *bigQueryTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,518 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// File formats for Config.FileFormat.
+const (
+	FileFormatJSON   = "json"   // a JSON object per line, default
+	FileFormatBinary = "binary" // length-prefixed MessagePack records
+)
+
+// binaryRecordMark starts every binary record. It is a byte MessagePack
+// never uses and JSON text never starts with, so readers tell records from
+// JSON lines and can find the next record after a damaged one.
+const binaryRecordMark = 0xc1
+
+// ErrBadRecord is returned by EntryReader for a damaged binary record.
+var ErrBadRecord = errors.New("malformed binary log record")
+
+// binaryEncoder writes entries as binary records: binaryRecordMark, the
+// length of the rest as a uvarint, then the JSON entry of the wrapped
+// encoder as a MessagePack map with the same keys in the same order.
+// Leaving out the quotes, separators and digits of JSON saves about a
+// quarter of the size of typical entries, and keeps every feature of the
+// JSON encoder, which EntryReader gives back as is.
+type binaryEncoder struct {
+	zapcore.Encoder
+}
+
+func (e binaryEncoder) Clone() zapcore.Encoder {
+	return binaryEncoder{e.Encoder.Clone()}
+}
+
+func (e binaryEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	record, err := appendMsgpackRecord(make([]byte, 0, buf.Len()), buf.Bytes())
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	buf.Reset()
+	buf.Write(record)
+	return buf, nil
+}
+
+// appendMsgpackRecord appends the binary record of the JSON entry.
+func appendMsgpackRecord(dst, entry []byte) ([]byte, error) {
+	dst = append(dst, binaryRecordMark)
+	// Reserve the longest uvarint for the length, moving the body back
+	// once it is known.
+	lenAt := len(dst)
+	dst = append(dst, make([]byte, binary.MaxVarintLen32)...)
+	p := jsonTranscoder{in: bytes.TrimSpace(entry), out: dst}
+	if err := p.value(); err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.in) {
+		return nil, ErrBadRecord
+	}
+	dst = p.out
+	body := len(dst) - lenAt - binary.MaxVarintLen32
+	n := binary.PutUvarint(dst[lenAt:], uint64(body))
+	copy(dst[lenAt+n:], dst[lenAt+binary.MaxVarintLen32:])
+	return dst[:lenAt+n+body], nil
+}
+
+// jsonTranscoder converts the JSON written by the encoders to
+// MessagePack, appending to out.
+type jsonTranscoder struct {
+	in  []byte
+	pos int
+	out []byte
+}
+
+func (t *jsonTranscoder) value() error {
+	if t.pos >= len(t.in) {
+		return ErrBadRecord
+	}
+	switch c := t.in[t.pos]; {
+	case c == '{':
+		return t.container('}', 0x80, 0xde)
+	case c == '[':
+		return t.container(']', 0x90, 0xdc)
+	case c == '"':
+		s, err := t.string()
+		if err != nil {
+			return err
+		}
+		t.out = appendMsgpackString(t.out, s)
+	case c == 't' && bytes.HasPrefix(t.in[t.pos:], []byte("true")):
+		t.pos += 4
+		t.out = append(t.out, 0xc3)
+	case c == 'f' && bytes.HasPrefix(t.in[t.pos:], []byte("false")):
+		t.pos += 5
+		t.out = append(t.out, 0xc2)
+	case c == 'n' && bytes.HasPrefix(t.in[t.pos:], []byte("null")):
+		t.pos += 4
+		t.out = append(t.out, 0xc0)
+	default:
+		return t.number()
+	}
+	return nil
+}
+
+// container transcodes an object or array, whose MessagePack header, fix
+// or 16 or 32 bit, depends on the number of elements: space for the
+// longest is reserved and the elements moved back once they are counted.
+func (t *jsonTranscoder) container(end byte, fix, header16 byte) error {
+	t.pos++
+	at := len(t.out)
+	t.out = append(t.out, 0, 0, 0, 0, 0)
+	count := 0
+	for t.skipSpace(); t.pos < len(t.in) && t.in[t.pos] != end; count++ {
+		if count > 0 {
+			if t.in[t.pos] != ',' {
+				return ErrBadRecord
+			}
+			t.pos++
+			t.skipSpace()
+		}
+		if end == '}' {
+			s, err := t.string()
+			if err != nil {
+				return err
+			}
+			t.out = appendMsgpackString(t.out, s)
+			t.skipSpace()
+			if t.pos >= len(t.in) || t.in[t.pos] != ':' {
+				return ErrBadRecord
+			}
+			t.pos++
+			t.skipSpace()
+		}
+		if err := t.value(); err != nil {
+			return err
+		}
+		t.skipSpace()
+	}
+	if t.pos >= len(t.in) {
+		return ErrBadRecord
+	}
+	t.pos++
+
+	var header []byte
+	switch {
+	case count < 16:
+		header = []byte{fix | byte(count)}
+	case count <= math.MaxUint16:
+		header = []byte{header16, byte(count >> 8), byte(count)}
+	default:
+		header = []byte{header16 + 1, byte(count >> 24), byte(count >> 16), byte(count >> 8), byte(count)}
+	}
+	copy(t.out[at:], header)
+	copy(t.out[at+len(header):], t.out[at+5:])
+	t.out = t.out[:len(t.out)-5+len(header)]
+	return nil
+}
+
+func (t *jsonTranscoder) skipSpace() {
+	for t.pos < len(t.in) {
+		switch t.in[t.pos] {
+		case ' ', '\t', '\n', '\r':
+			t.pos++
+		default:
+			return
+		}
+	}
+}
+
+// string returns the JSON string at pos, unescaped.
+func (t *jsonTranscoder) string() ([]byte, error) {
+	if t.pos >= len(t.in) || t.in[t.pos] != '"' {
+		return nil, ErrBadRecord
+	}
+	escaped := false
+	for i := t.pos + 1; i < len(t.in); i++ {
+		switch t.in[i] {
+		case '\\':
+			escaped = true
+			i++
+		case '"':
+			raw := t.in[t.pos : i+1]
+			t.pos = i + 1
+			if !escaped {
+				return raw[1 : len(raw)-1], nil
+			}
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, err
+			}
+			return []byte(s), nil
+		}
+	}
+	return nil, ErrBadRecord
+}
+
+// number writes integers as the smallest MessagePack integer holding them
+// and other numbers as 64-bit floats.
+func (t *jsonTranscoder) number() error {
+	start := t.pos
+	float := false
+	for t.pos < len(t.in) {
+		c := t.in[t.pos]
+		if c == '.' || c == 'e' || c == 'E' {
+			float = true
+		} else if (c < '0' || c > '9') && c != '-' && c != '+' {
+			break
+		}
+		t.pos++
+	}
+	s := string(t.in[start:t.pos])
+	if !float {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			t.out = appendMsgpackInt(t.out, n)
+			return nil
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			t.out = append(t.out, 0xcf)
+			t.out = binary.BigEndian.AppendUint64(t.out, n)
+			return nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return ErrBadRecord
+	}
+	t.out = append(t.out, 0xcb)
+	t.out = binary.BigEndian.AppendUint64(t.out, math.Float64bits(f))
+	return nil
+}
+
+func appendMsgpackString(b, s []byte) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xdb)
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= math.MaxInt8:
+		return append(b, byte(n))
+	case n < 0 && n >= -32:
+		return append(b, byte(n))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		return append(b, 0xd0, byte(n))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(n))
+}
+
+// EntryReader reads the entries of a log file in either file format, even
+// mixed, as when FileFormat changed between runs. Binary records are
+// turned back into the JSON they were encoded from.
+type EntryReader struct {
+	r    *bufio.Reader
+	line []byte
+}
+
+// NewEntryReader returns a reader of the entries in r.
+func NewEntryReader(r io.Reader) *EntryReader {
+	return &EntryReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next entry as a JSON object without a trailing newline,
+// valid until the next call. It returns io.EOF after the last entry and an
+// error for a damaged record, after which reading goes on with the next
+// line or record.
+func (r *EntryReader) Next() ([]byte, error) {
+	for {
+		c, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == binaryRecordMark {
+			return r.binaryRecord()
+		}
+		r.r.UnreadByte()
+		r.line, err = r.readLine(r.line[:0])
+		if line := bytes.TrimSpace(r.line); len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLine appends the line up to a newline or record mark.
+func (r *EntryReader) readLine(b []byte) ([]byte, error) {
+	for {
+		c, err := r.r.ReadByte()
+		if err != nil {
+			return b, err
+		}
+		switch c {
+		case '\n':
+			return b, nil
+		case binaryRecordMark:
+			r.r.UnreadByte()
+			return b, nil
+		}
+		b = append(b, c)
+	}
+}
+
+func (r *EntryReader) binaryRecord() ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, r.damaged(err)
+	}
+	if n > math.MaxInt32 {
+		return nil, ErrBadRecord
+	}
+	record := make([]byte, n)
+	if _, err := io.ReadFull(r.r, record); err != nil {
+		return nil, r.damaged(err)
+	}
+	d := msgpackDecoder{in: record, out: r.line[:0]}
+	if err := d.value(); err != nil || d.pos != len(record) {
+		return nil, ErrBadRecord
+	}
+	r.line = d.out
+	return r.line, nil
+}
+
+// damaged reports a record cut short.
+func (r *EntryReader) damaged(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", ErrBadRecord, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// msgpackDecoder converts a MessagePack value to JSON, appending to out.
+type msgpackDecoder struct {
+	in  []byte
+	pos int
+	out []byte
+}
+
+func (d *msgpackDecoder) value() error {
+	c, ok := d.next(1)
+	if !ok {
+		return ErrBadRecord
+	}
+	switch b := c[0]; {
+	case b <= 0x7f:
+		d.out = strconv.AppendInt(d.out, int64(b), 10)
+	case b >= 0xe0:
+		d.out = strconv.AppendInt(d.out, int64(int8(b)), 10)
+	case b&0xf0 == 0x80:
+		return d.container(int(b&0x0f), true)
+	case b&0xf0 == 0x90:
+		return d.container(int(b&0x0f), false)
+	case b&0xe0 == 0xa0:
+		return d.string(int(b & 0x1f))
+	case b == 0xc0:
+		d.out = append(d.out, "null"...)
+	case b == 0xc2:
+		d.out = append(d.out, "false"...)
+	case b == 0xc3:
+		d.out = append(d.out, "true"...)
+	case b == 0xc4, b == 0xc5, b == 0xc6:
+		n, ok := d.length(1 << (b - 0xc4))
+		if !ok {
+			return ErrBadRecord
+		}
+		bin, ok := d.next(n)
+		if !ok {
+			return ErrBadRecord
+		}
+		d.out = append(d.out, '"')
+		d.out = append(d.out, base64.StdEncoding.EncodeToString(bin)...)
+		d.out = append(d.out, '"')
+	case b == 0xca, b == 0xcb:
+		size := 4 << (b - 0xca)
+		v, ok := d.next(size)
+		if !ok {
+			return ErrBadRecord
+		}
+		f := float64(math.Float32frombits(binary.BigEndian.Uint32(v[:4])))
+		if size == 8 {
+			f = math.Float64frombits(binary.BigEndian.Uint64(v))
+		}
+		a := jsonAppender{b: d.out}
+		a.appendFloat(f, 64)
+		d.out = a.b
+	case b >= 0xcc && b <= 0xcf:
+		n, ok := d.uint(1 << (b - 0xcc))
+		if !ok {
+			return ErrBadRecord
+		}
+		d.out = strconv.AppendUint(d.out, n, 10)
+	case b >= 0xd0 && b <= 0xd3:
+		size := 1 << (b - 0xd0)
+		n, ok := d.uint(size)
+		if !ok {
+			return ErrBadRecord
+		}
+		shift := 64 - 8*size
+		d.out = strconv.AppendInt(d.out, int64(n<<shift)>>shift, 10)
+	case b >= 0xd9 && b <= 0xdb:
+		n, ok := d.length(1 << (b - 0xd9))
+		if !ok {
+			return ErrBadRecord
+		}
+		return d.string(n)
+	case b == 0xdc, b == 0xdd:
+		n, ok := d.length(2 << (b - 0xdc))
+		if !ok {
+			return ErrBadRecord
+		}
+		return d.container(n, false)
+	case b == 0xde, b == 0xdf:
+		n, ok := d.length(2 << (b - 0xde))
+		if !ok {
+			return ErrBadRecord
+		}
+		return d.container(n, true)
+	default:
+		return ErrBadRecord
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) container(n int, object bool) error {
+	open, end := byte('['), byte(']')
+	if object {
+		open, end = '{', '}'
+	}
+	d.out = append(d.out, open)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		if object {
+			if err := d.value(); err != nil {
+				return err
+			}
+			d.out = append(d.out, ':')
+		}
+		if err := d.value(); err != nil {
+			return err
+		}
+	}
+	d.out = append(d.out, end)
+	return nil
+}
+
+func (d *msgpackDecoder) string(n int) error {
+	s, ok := d.next(n)
+	if !ok {
+		return ErrBadRecord
+	}
+	d.out = append(d.out, '"')
+	d.out = appendJSONString(d.out, string(s))
+	d.out = append(d.out, '"')
+	return nil
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, bool) {
+	if n < 0 || len(d.in)-d.pos < n {
+		return nil, false
+	}
+	b := d.in[d.pos : d.pos+n]
+	d.pos += n
+	return b, true
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (d *msgpackDecoder) uint(size int) (uint64, bool) {
+	b, ok := d.next(size)
+	if !ok {
+		return 0, false
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, true
+}
+
+// length reads a length of size bytes.
+func (d *msgpackDecoder) length(size int) (int, bool) {
+	n, ok := d.uint(size)
+	if !ok || n > uint64(len(d.in)) {
+		return 0, false
+	}
+	return int(n), true
+}
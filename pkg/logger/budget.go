@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultOverBudgetSampling is the share of entries kept while a budget is
+// exceeded when VolumeBudget.OverBudgetSampling is not set.
+const defaultOverBudgetSampling = 100
+
+// VolumeBudget caps the bytes logged per second, to protect disks and log
+// bills from a runaway loop. While a budget is exceeded entries below
+// error are sampled, and a "log volume budget exceeded" warning is logged
+// once per second. Errors and above are always logged; their bytes count
+// towards BytesPerSecond. Sizes are those of the JSON file encoding.
+type VolumeBudget struct {
+	// BytesPerSecond is the budget of all levels together, 0 for none.
+	BytesPerSecond int64
+
+	// LevelBytesPerSecond are budgets of single levels below error, e.g.
+	// {"debug": 1 << 20}.
+	LevelBytesPerSecond map[string]int64
+
+	// OverBudgetSampling keeps one in this many entries over budget,
+	// 100 by default; 1 keeps them all and only logs the warnings.
+	OverBudgetSampling int
+}
+
+func (b VolumeBudget) enabled() bool {
+	return b.BytesPerSecond > 0 || len(b.LevelBytesPerSecond) > 0
+}
+
+func (b VolumeBudget) validate() error {
+	if b.BytesPerSecond < 0 {
+		return errors.New("negative bytes per second")
+	}
+	if b.OverBudgetSampling < 0 {
+		return errors.New("negative over budget sampling")
+	}
+	for name, limit := range b.LevelBytesPerSecond {
+		lvl, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		if lvl >= zapcore.ErrorLevel {
+			return fmt.Errorf("level %q is never dropped", name)
+		}
+		if limit <= 0 {
+			return fmt.Errorf("level %q: bytes per second must be positive", name)
+		}
+	}
+	return nil
+}
+
+// budgetCore enforces a VolumeBudget. The size of an entry is only known
+// once it is encoded, so the decision is made in Write.
+type budgetCore struct {
+	zapcore.Core
+	enc    zapcore.Encoder
+	global int64
+	levels map[zapcore.Level]int64
+	every  uint64
+	state  *budgetState
+}
+
+func newBudgetCore(core zapcore.Core, budget VolumeBudget, encoderConfig zapcore.EncoderConfig) *budgetCore {
+	levels := make(map[zapcore.Level]int64, len(budget.LevelBytesPerSecond))
+	for name, limit := range budget.LevelBytesPerSecond {
+		if lvl, err := ParseLevel(name); err == nil && limit > 0 {
+			levels[lvl] = limit
+		}
+	}
+	every := budget.OverBudgetSampling
+	if every <= 0 {
+		every = defaultOverBudgetSampling
+	}
+	return &budgetCore{
+		Core:   core,
+		enc:    zapcore.NewJSONEncoder(encoderConfig),
+		global: budget.BytesPerSecond,
+		levels: levels,
+		every:  uint64(every),
+		state:  &budgetState{bytes: make(map[zapcore.Level]int64)},
+	}
+}
+
+func (c *budgetCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *budgetCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *budgetCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var size int64
+	if buf, err := c.enc.EncodeEntry(ent, fields); err == nil {
+		size = int64(buf.Len())
+		buf.Free()
+	}
+	keep, notice := c.state.admit(c, ent, size)
+	if notice != nil {
+		c.writeNotice(ent, notice)
+	}
+	if !keep {
+		return nil
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// writeNotice logs that the budget in n was exceeded, bypassing it.
+func (c *budgetCore) writeNotice(cause zapcore.Entry, n *budgetNotice) {
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       cause.Time,
+		LoggerName: cause.LoggerName,
+		Message:    "log volume budget exceeded",
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(
+			zap.String("budget", n.budget),
+			zap.Int64("bytes_per_second", n.limit),
+			zap.Int("keep_one_in", int(c.every)),
+			zap.Uint64("dropped_previous_second", n.droppedBefore),
+		)
+	}
+}
+
+type budgetNotice struct {
+	budget        string // "global" or a level
+	limit         int64
+	droppedBefore uint64
+}
+
+// budgetState counts the bytes logged in the current second, shared by a
+// budgetCore and its children.
+type budgetState struct {
+	mu       sync.Mutex
+	second   int64
+	total    int64
+	bytes    map[zapcore.Level]int64
+	over     uint64 // entries over budget this second
+	dropped  uint64
+	previous uint64 // dropped in the second before
+	noticed  bool
+}
+
+// admit reports whether an entry of size bytes is logged, and the notice
+// to log when it is the first over budget this second.
+func (s *budgetState) admit(c *budgetCore, ent zapcore.Entry, size int64) (bool, *budgetNotice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sec := ent.Time.Unix(); sec != s.second {
+		s.previous = 0
+		if sec == s.second+1 {
+			s.previous = s.dropped
+		}
+		s.second, s.total, s.over, s.dropped, s.noticed = sec, 0, 0, 0, false
+		s.bytes = make(map[zapcore.Level]int64)
+	}
+
+	var exceeded *budgetNotice
+	if ent.Level < zapcore.ErrorLevel {
+		if limit, ok := c.levels[ent.Level]; ok && s.bytes[ent.Level]+size > limit {
+			exceeded = &budgetNotice{budget: ent.Level.String(), limit: limit}
+		} else if c.global > 0 && s.total+size > c.global {
+			exceeded = &budgetNotice{budget: "global", limit: c.global}
+		}
+	}
+	var notice *budgetNotice
+	if exceeded != nil {
+		if !s.noticed {
+			s.noticed = true
+			exceeded.droppedBefore = s.previous
+			notice = exceeded
+		}
+		s.over++
+		if s.over%c.every != 0 {
+			s.dropped++
+			return false, notice
+		}
+	}
+	s.total += size
+	s.bytes[ent.Level] += size
+	return true, notice
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+)
+
+// BuildFields returns the main module version and the VCS revision the
+// binary was built from, with vcs_modified set when the working tree had
+// uncommitted changes. It returns nil when the binary has no build info.
+func BuildFields() []zap.Field {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	fields := []zap.Field{zap.String("module_version", info.Main.Version)}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			fields = append(fields, zap.String("vcs_revision", s.Value))
+		case "vcs.modified":
+			fields = append(fields, zap.Bool("vcs_modified", s.Value == "true"))
+		}
+	}
+	return fields
+}
+
+// logBuildBanner logs one entry describing the build, for Config.BuildInfo.
+func (l *Logger) logBuildBanner() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("module", info.Main.Path),
+		zap.String("go_version", info.GoVersion),
+		zap.String("platform", runtime.GOOS+"/"+runtime.GOARCH),
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.time" {
+			fields = append(fields, zap.String("vcs_time", s.Value))
+		}
+	}
+	l.Info("build info", fields...)
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// externalCaller returns the first frame on the stack outside this package
+// and zap, which is the code that logged the current entry however many
+// wrappers it went through.
+func externalCaller() (runtime.Frame, bool) {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if pkg := funcPackage(frame.Function); pkg != loggerPackage && !strings.HasPrefix(pkg, "go.uber.org/zap") {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+// funcPackage returns the import path of a function name as reported by
+// runtime, e.g. "github.com/acme/app/sync" for
+// "github.com/acme/app/sync.(*Worker).Run".
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+var loggerPackage = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	return funcPackage(runtime.FuncForPC(pc).Name())
+}()
+
+// callerCore sets the caller of every entry it writes to the code that
+// logged it. zap.AddCaller cannot be used because the package functions
+// and Logger methods reach zap through different numbers of frames.
+type callerCore struct {
+	zapcore.Core
+}
+
+func (c callerCore) With(fields []zapcore.Field) zapcore.Core {
+	return callerCore{c.Core.With(fields)}
+}
+
+func (c callerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c callerCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if frame, ok := externalCaller(); ok {
+		ent.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+		ent.Caller.Function = frame.Function
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backupTimeFormat is the timestamp lumberjack puts in backup file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// checksumManifest keeps "<log file>.sha256" listing the SHA-256 of every
+// rotated backup of a log file, in the format of sha256sum, so archived
+// logs can be checked with "sha256sum -c". The backupMill of the file
+// updates it after rotations, once backups are compressed.
+type checksumManifest struct {
+	path          string // the log file
+	mode          os.FileMode
+	compressedExt string // of backups compressed by the mill
+}
+
+func newChecksumManifest(path string, perm FilePermissions, compressedExt string) *checksumManifest {
+	mode := perm.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	return &checksumManifest{path: path, mode: mode, compressedExt: compressedExt}
+}
+
+// update appends the backups missing from the manifest.
+func (m *checksumManifest) update() {
+	if err := m.appendMissing(); err != nil {
+		fmt.Fprintf(os.Stderr, "logger: %s.sha256: %v\n", m.path, err)
+	}
+}
+
+func (m *checksumManifest) appendMissing() error {
+	listed, err := m.listed()
+	if err != nil {
+		return err
+	}
+	backups, err := backupNames(m.path, m.compressedExt)
+	if err != nil {
+		return err
+	}
+	var lines strings.Builder
+	for _, name := range backups {
+		if listed[name] {
+			continue
+		}
+		sum, err := fileSHA256(filepath.Join(filepath.Dir(m.path), name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&lines, "%s  %s\n", sum, name)
+	}
+	if lines.Len() == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(m.path+".sha256", os.O_CREATE|os.O_WRONLY|os.O_APPEND, m.mode)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(lines.String())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// listed returns the file names already in the manifest.
+func (m *checksumManifest) listed() (map[string]bool, error) {
+	listed := make(map[string]bool)
+	f, err := os.Open(m.path + ".sha256")
+	if os.IsNotExist(err) {
+		return listed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if _, name, ok := strings.Cut(sc.Text(), "  "); ok {
+			listed[name] = true
+		}
+	}
+	return listed, sc.Err()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
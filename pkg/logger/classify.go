@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Error categories attached to classified errors as the error_category field.
+const (
+	CategoryClientError     = "client_error"
+	CategoryDependencyError = "dependency_error"
+	CategoryInternal        = "internal"
+)
+
+// ErrorClass is the severity and category an expected error is logged with.
+type ErrorClass struct {
+	Level    zapcore.Level
+	Category string
+}
+
+type errorRule struct {
+	match func(error) bool
+	class ErrorClass
+}
+
+var (
+	errorRulesMu sync.RWMutex
+	errorRules   []errorRule
+)
+
+// RegisterError classifies every error matching target according to errors.Is.
+func RegisterError(target error, class ErrorClass) {
+	RegisterErrorMatcher(func(err error) bool { return errors.Is(err, target) }, class)
+}
+
+// RegisterErrorType classifies every error whose chain contains a T
+// according to errors.As.
+func RegisterErrorType[T error](class ErrorClass) {
+	RegisterErrorMatcher(func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}, class)
+}
+
+// RegisterErrorMatcher classifies every error for which match returns true.
+// Rules registered later take precedence over earlier ones.
+func RegisterErrorMatcher(match func(error) bool, class ErrorClass) {
+	errorRulesMu.Lock()
+	defer errorRulesMu.Unlock()
+	errorRules = append(errorRules, errorRule{match: match, class: class})
+}
+
+// RegisterDefaultErrorClasses installs the default policy for errors that
+// usually describe expected conditions rather than failures.
+func RegisterDefaultErrorClasses() {
+	RegisterError(sql.ErrNoRows, ErrorClass{Level: zapcore.DebugLevel, Category: CategoryClientError})
+	RegisterError(context.Canceled, ErrorClass{Level: zapcore.WarnLevel, Category: CategoryClientError})
+	RegisterError(context.DeadlineExceeded, ErrorClass{Level: zapcore.WarnLevel, Category: CategoryDependencyError})
+}
+
+// ClassifyError returns the class of the most recently registered rule
+// matching err.
+func ClassifyError(err error) (ErrorClass, bool) {
+	errorRulesMu.RLock()
+	defer errorRulesMu.RUnlock()
+	for i := len(errorRules) - 1; i >= 0; i-- {
+		if errorRules[i].match(err) {
+			return errorRules[i].class, true
+		}
+	}
+	return ErrorClass{}, false
+}
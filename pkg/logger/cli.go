@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CLIOptions configures NewCLILogger.
+type CLIOptions struct {
+	// Verbosity is the number of -v flags: 0 prints Info and above, 1 adds
+	// Debug and 2 or more also prints the caller of each entry.
+	Verbosity int
+
+	// Quiet prints only warnings and errors. It takes precedence over
+	// Verbosity.
+	Quiet bool
+
+	// Timestamps prefixes every line with the local time.
+	Timestamps bool
+
+	// Out receives the output, os.Stderr by default.
+	Out io.Writer
+}
+
+// NewCLILogger returns a logger for command-line tools that prints
+// human-readable lines to a single output and writes no files.
+func NewCLILogger(opts CLIOptions) *Logger {
+	out := opts.Out
+	if out == nil {
+		out = os.Stderr
+	}
+
+	level := zapcore.InfoLevel
+	switch {
+	case opts.Quiet:
+		level = zapcore.WarnLevel
+	case opts.Verbosity > 0:
+		level = zapcore.DebugLevel
+	}
+
+	encoderConfig := zapcore.EncoderConfig{
+		MessageKey:     "msg",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		EncodeLevel:    levelEncoder(zapcore.CapitalLevelEncoder, true),
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeName:     zapcore.FullNameEncoder,
+	}
+	if opts.Timestamps {
+		encoderConfig.TimeKey = "ts"
+		encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05")
+	}
+	var zopts []zap.Option
+	if opts.Verbosity >= 2 {
+		encoderConfig.CallerKey = "caller"
+		encoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
+		zopts = append(zopts, zap.AddCaller(), zap.AddCallerSkip(1))
+	}
+
+	enc := humanBytesEncoder{newFoldingEncoder(zapcore.NewConsoleEncoder(encoderConfig))}
+	w := &progressWriter{out: out, enc: enc.Clone(), tty: isTerminal(out), line: &statusLine{out: out}}
+	atomicLevel := zap.NewAtomicLevelAt(level)
+	core := consoleCore{newOutputCore(enc, w, atomicLevel)}
+	return &Logger{zap: zap.New(core, zopts...), progress: w, level: atomicLevel}
+}
+
+// Progress prints msg as a status line that the next Progress call or
+// entry replaces, e.g. for "downloaded 40/100 files". Outside a terminal,
+// and for loggers not built by NewCLILogger or in development mode, it
+// logs msg at Info level.
+func (l *Logger) Progress(msg string, fields ...zap.Field) {
+	if l.progress == nil || !l.progress.tty {
+		l.Info(msg, fields...)
+		return
+	}
+	if !l.zap.Core().Enabled(zapcore.InfoLevel) {
+		return
+	}
+	l.progress.update(zapcore.Entry{
+		Level:   zapcore.InfoLevel,
+		Time:    time.Now(),
+		Message: msg,
+	}, fields)
+}
+
+// progressWriter writes entries to out, first erasing the status line
+// left by the last progress update.
+type progressWriter struct {
+	out  io.Writer
+	enc  zapcore.Encoder
+	tty  bool
+	line *statusLine
+}
+
+const clearLine = "\r\x1b[K"
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.line.mu.Lock()
+	defer w.line.mu.Unlock()
+	w.line.clear()
+	return w.out.Write(p)
+}
+
+func (w *progressWriter) Sync() error {
+	if s, ok := w.out.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+func (w *progressWriter) update(ent zapcore.Entry, fields []zap.Field) {
+	buf, err := w.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return
+	}
+	defer buf.Free()
+	w.line.show(buf.Bytes())
+}
+
+// statusLine is the terminal line progress updates are drawn on, shared
+// by the writers whose entries erase it.
+type statusLine struct {
+	mu      sync.Mutex
+	out     io.Writer
+	pending bool
+}
+
+// clear erases the line; the caller holds mu.
+func (s *statusLine) clear() {
+	if s.pending {
+		s.pending = false
+		io.WriteString(s.out, clearLine)
+	}
+}
+
+// show replaces the line with text, without its trailing newline.
+func (s *statusLine) show(text []byte) {
+	if n := len(text); n > 0 && text[n-1] == '\n' {
+		text = text[:n-1]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	io.WriteString(s.out, clearLine)
+	s.out.Write(text)
+	s.pending = true
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClickHouseConfig configures a sink inserting batches into ClickHouse over
+// its HTTP interface. The table is expected to look like:
+//
+//	CREATE TABLE logs (
+//		timestamp DateTime64(9, 'UTC'),
+//		level     LowCardinality(String),
+//		message   String,
+//		fields    String -- JSON object, query with JSONExtract*
+//	) ENGINE = MergeTree
+//	ORDER BY (level, timestamp)
+//	TTL toDateTime(timestamp) + INTERVAL 30 DAY
+type ClickHouseConfig struct {
+	URL      string // HTTP endpoint, e.g. http://clickhouse:8123
+	Database string // default "default"
+	Table    string // default "logs"
+	User     string
+	Password string
+	Gzip     bool
+	Client   *http.Client
+
+	// Compression names the compressor of inserts instead of Gzip, e.g.
+	// "zstd" in binaries built with the zstd tag.
+	Compression string
+
+	Delivery DeliveryConfig
+}
+
+// NewClickHouseSink returns a sink that inserts each batch with a single
+// INSERT ... FORMAT JSONEachRow request.
+func NewClickHouseSink(name string, cfg ClickHouseConfig) (*Delivery, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("clickhouse sink: no URL")
+	}
+	if cfg.Database == "" {
+		cfg.Database = "default"
+	}
+	if cfg.Table == "" {
+		cfg.Table = "logs"
+	}
+	if !sqlIdentifier.MatchString(cfg.Database) || !sqlIdentifier.MatchString(cfg.Table) {
+		return nil, fmt.Errorf("clickhouse sink: invalid table %s.%s", cfg.Database, cfg.Table)
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse sink: %w", err)
+	}
+	q := u.Query()
+	q.Set("query", fmt.Sprintf("INSERT INTO %s.%s (timestamp, level, message, fields) FORMAT JSONEachRow", cfg.Database, cfg.Table))
+	q.Set("date_time_input_format", "best_effort")
+	u.RawQuery = q.Encode()
+	compressor, err := sinkCompressor(cfg.Compression, cfg.Gzip)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse sink: %w", err)
+	}
+
+	t := &httpTransport{
+		url:         u.String(),
+		header:      make(http.Header),
+		compressor:  compressor,
+		client:      cfg.Client,
+		contentType: "application/x-ndjson",
+		encode:      writeClickHouseRows,
+	}
+	if cfg.User != "" {
+		t.header.Set("X-ClickHouse-User", cfg.User)
+		t.header.Set("X-ClickHouse-Key", cfg.Password)
+	}
+	return NewDelivery(name, t, cfg.Delivery)
+}
+
+type clickHouseRow struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	Fields    string `json:"fields"`
+}
+
+func writeClickHouseRows(w io.Writer, batch [][]byte, keys entryKeys) error {
+	enc := json.NewEncoder(w)
+	for _, entry := range batch {
+		e, err := decodeEntry(entry, keys)
+		if err != nil {
+			continue
+		}
+		row := clickHouseRow{
+			Timestamp: e.Time.UTC().Format(time.RFC3339Nano),
+			Level:     strings.ToLower(e.Level),
+			Message:   e.Message,
+			Fields:    string(e.fieldsJSON()),
+		}
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
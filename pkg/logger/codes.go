@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EventCodeKey is the field Code adds, which alerting can key off instead
+// of the message text.
+const EventCodeKey = "event_code"
+
+var codeCatalog struct {
+	mu    sync.RWMutex
+	codes map[string]string
+}
+
+// RegisterCode adds code, e.g. "AUTH-001", with a description of the event
+// to the catalog. In development mode entries with codes missing from a
+// non-empty catalog fail validation.
+func RegisterCode(code, description string) {
+	codeCatalog.mu.Lock()
+	defer codeCatalog.mu.Unlock()
+	if codeCatalog.codes == nil {
+		codeCatalog.codes = make(map[string]string)
+	}
+	codeCatalog.codes[code] = description
+}
+
+// RegisterCodes adds every code in codes, mapped to its description.
+func RegisterCodes(codes map[string]string) {
+	for code, description := range codes {
+		RegisterCode(code, description)
+	}
+}
+
+// CodeDescription returns the description code was registered with.
+func CodeDescription(code string) (string, bool) {
+	codeCatalog.mu.RLock()
+	defer codeCatalog.mu.RUnlock()
+	description, ok := codeCatalog.codes[code]
+	return description, ok
+}
+
+// Code constructs the event code field, e.g.
+//
+//	log.Warn("login failed", logger.Code("AUTH-001"), zap.String("user", id))
+func Code(code string) zap.Field {
+	return zap.String(EventCodeKey, code)
+}
+
+// unregisteredCode returns the event code among fields that is missing
+// from the catalog, if the catalog is in use.
+func unregisteredCode(fields []zapcore.Field) (string, bool) {
+	codeCatalog.mu.RLock()
+	defer codeCatalog.mu.RUnlock()
+	if len(codeCatalog.codes) == 0 {
+		return "", false
+	}
+	for _, f := range fields {
+		if f.Key != EventCodeKey || f.Type != zapcore.StringType {
+			continue
+		}
+		if _, ok := codeCatalog.codes[f.String]; !ok {
+			return f.String, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compressor is a compression format for rotated log files and the request
+// bodies of HTTP sinks. gzip is built in, and zstd in binaries built with
+// the zstd tag (go build -tags zstd), which adds
+// github.com/klauspost/compress; others are registered with
+// RegisterCompressor.
+type Compressor struct {
+	// Name selects the compressor and is sent as the Content-Encoding of
+	// compressed requests.
+	Name string
+
+	// Ext is appended to the names of compressed backups, e.g. ".gz".
+	Ext string
+
+	// NewWriter returns a writer compressing to w. Closing it flushes the
+	// compressed stream but does not close w.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var gzipCompressor = Compressor{
+	Name: "gzip",
+	Ext:  ".gz",
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	},
+}
+
+var compressors = struct {
+	mu     sync.RWMutex
+	byName map[string]Compressor
+}{byName: map[string]Compressor{"gzip": gzipCompressor}}
+
+// RegisterCompressor makes c available to WithCompression and the
+// Compression of sinks by its name. Register compressors before building
+// loggers.
+func RegisterCompressor(c Compressor) {
+	compressors.mu.Lock()
+	defer compressors.mu.Unlock()
+	compressors.byName[c.Name] = c
+}
+
+// compressorNamed returns the registered compressor called name.
+func compressorNamed(name string) (*Compressor, error) {
+	compressors.mu.RLock()
+	defer compressors.mu.RUnlock()
+	c, ok := compressors.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("compression %q is not registered", name)
+	}
+	return &c, nil
+}
+
+// sinkCompressor returns the compressor of a sink configured with a
+// Compression name and the older Gzip flag, nil for none.
+func sinkCompressor(name string, gzip bool) (*Compressor, error) {
+	if name == "" && gzip {
+		name = "gzip"
+	}
+	if name == "" {
+		return nil, nil
+	}
+	return compressorNamed(name)
+}
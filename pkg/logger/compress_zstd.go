@@ -0,0 +1,23 @@
+//go:build zstd
+
+package logger
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdCompressor writes zstd frames, with a better ratio and speed than
+// gzip for logs.
+var zstdCompressor = Compressor{
+	Name: "zstd",
+	Ext:  ".zst",
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	},
+}
+
+func init() {
+	RegisterCompressor(zstdCompressor)
+}
@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// logConfig logs one entry describing the effective configuration, so
+// operators can check what the process does with its logs. Credentials in
+// output URLs are redacted.
+func (l *Logger) logConfig(config *Config) {
+	config = config.withInstancePaths()
+	level := config.Level
+	if level == "" {
+		level = "debug"
+	}
+	stderrLevel := config.StderrLevel
+	if stderrLevel == "" {
+		stderrLevel = "warn"
+	}
+	fileFormat := strings.ToLower(config.FileFormat)
+	if fileFormat == "" {
+		fileFormat = FileFormatJSON
+	}
+	mode := ModeProduction
+	if config.isDevelopment() {
+		mode = ModeDevelopment
+	}
+	fields := []zap.Field{
+		zap.String("mode", mode),
+		zap.String("level", level),
+	}
+	if r := config.VerboseRollout; r.Percent > 0 {
+		base, _ := ParseLevel(level)
+		fields = append(fields, Namespace("verbose_rollout",
+			zap.Float64("percent", r.Percent),
+			zap.Bool("this_host", r.level(base) != base),
+		))
+	}
+	if b := config.Budget; b.enabled() {
+		fields = append(fields, Namespace("budget",
+			zap.Int64("bytes_per_second", b.BytesPerSecond),
+			zap.Any("level_bytes_per_second", b.LevelBytesPerSecond),
+		))
+	}
+	if a := config.ErrorAnomalies; a.Enabled {
+		a = a.withDefaults()
+		fields = append(fields, Namespace("error_anomalies",
+			zap.Duration("window", a.Window),
+			zap.Float64("factor", a.Factor),
+			zap.Int("min_errors", a.MinErrors),
+		))
+	}
+	if len(config.LevelOverrides) > 0 {
+		fields = append(fields, zap.Strings("level_overrides", config.LevelOverrides))
+	}
+	if config.DisableFiles {
+		fields = append(fields, zap.Bool("files", false))
+	} else {
+		files := []zap.Field{
+			zap.String("info", redactPath(config.InfoLogPath)),
+			zap.String("error", redactPath(config.ErrorLogPath)),
+			zap.String("format", fileFormat),
+			zap.Int("rotate_megabytes", defaultRotation.megabytes),
+			zap.Int("rotate_backups", defaultRotation.backups),
+			zap.Int("rotate_days", defaultRotation.days),
+		}
+		if config.FileSharing != "" {
+			files = append(files, zap.String("sharing", strings.ToLower(config.FileSharing)))
+		}
+		fields = append(fields, Namespace("files", files...))
+	}
+	consoleFields := []zap.Field{
+		zap.Bool("json", config.ConsoleJSON),
+		zap.String("stderr_level", stderrLevel),
+		zap.Int("max_line_bytes", config.MaxLineBytes),
+	}
+	if config.ConsoleFormat != "" {
+		consoleFields = append(consoleFields, zap.String("format", strings.ToLower(config.ConsoleFormat)))
+	}
+	if config.ConsoleColor != "" {
+		consoleFields = append(consoleFields, zap.String("color", strings.ToLower(config.ConsoleColor)))
+	}
+	fields = append(fields, Namespace("console", consoleFields...))
+	if config.AuditLogPath != "" {
+		fields = append(fields, zap.String("audit_log", redactPath(config.AuditLogPath)))
+	}
+	sinks := make([]string, len(config.Sinks))
+	for i, s := range config.Sinks {
+		sinks[i] = s.Name()
+	}
+	fields = append(fields,
+		zap.Strings("sinks", sinks),
+		zap.Int("max_entry_bytes", config.MaxEntryBytes),
+	)
+	if len(config.AuditSinks) > 0 {
+		auditSinks := make([]string, len(config.AuditSinks))
+		for i, s := range config.AuditSinks {
+			auditSinks[i] = s.Name()
+		}
+		fields = append(fields, zap.Strings("audit_sinks", auditSinks))
+	}
+	if config.SchemaCompatVersion > 0 {
+		fields = append(fields, zap.Int("schema_compat_version", config.SchemaCompatVersion))
+	}
+	if config.Sanitize != "" {
+		fields = append(fields, zap.String("sanitize", config.Sanitize))
+	}
+	if config.OrderedOutputs {
+		fields = append(fields, zap.Bool("ordered_outputs", true))
+	}
+	if config.EntryIDs {
+		fields = append(fields, zap.Bool("entry_ids", true))
+	}
+	l.Info("logging configured", fields...)
+}
+
+// redactPath hides the password of an output given as a URL.
+func redactPath(path string) string {
+	if !strings.Contains(path, "://") {
+		return path
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return "<unparseable>"
+	}
+	return u.Redacted()
+}
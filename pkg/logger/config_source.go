@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ConfigSource delivers a logging config document from a central
+// configuration service.
+type ConfigSource interface {
+	// Watch calls fn with the current document and then with every new
+	// version, or with nil when it is deleted, until ctx is done. It
+	// retries failures itself and returns only once ctx is done.
+	Watch(ctx context.Context, fn func(doc []byte)) error
+}
+
+// WatchConfig reinitializes the global logger whenever the document of
+// src changes, with the JSON document applied over a copy of base, e.g.
+// {"Level": "debug", "LevelOverrides": ["github.com/acme/app/db=warn"]}.
+// Keys are Config field names; a deleted document restores base, which
+// should be the config the global logger was built with. Documents that
+// change nothing, such as a first one matching base, are skipped; a
+// change of Level alone is applied with SetLevel; any other change is
+// applied atomically through Reinit. Invalid documents are reported and
+// skipped. It returns a function that stops watching.
+func WatchConfig(base *Config, src ConfigSource) (stop func()) {
+	cfg := *base
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		current, set := cfg, map[string]bool{}
+		src.Watch(ctx, func(doc []byte) {
+			next, err := applyConfigDocument(cfg, doc)
+			if err != nil {
+				reportConfigError(err)
+				return
+			}
+			// Fields set by neither document are those of base in both
+			nextSet := documentFields(doc)
+			changed := changedFields(&current, &next, set, nextSet)
+			switch {
+			case len(changed) == 0:
+			case len(changed) == 1 && changed[0] == "Level" && logInstance.Load() != nil:
+				if err = next.Validate(); err == nil {
+					logInstance.Load().level.SetLevel(next.level())
+				}
+			default:
+				err = Reinit(&next)
+			}
+			if err != nil {
+				reportConfigError(err)
+				return
+			}
+			current, set = next, nextSet
+		})
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// documentFields returns the names of the Config fields a document sets,
+// matched without regard to case as encoding/json does.
+func documentFields(doc []byte) map[string]bool {
+	var keys map[string]json.RawMessage
+	json.Unmarshal(doc, &keys)
+	set := make(map[string]bool, len(keys))
+	t := reflect.TypeOf(Config{})
+	for key := range keys {
+		for i := 0; i < t.NumField(); i++ {
+			if strings.EqualFold(key, t.Field(i).Name) {
+				set[t.Field(i).Name] = true
+			}
+		}
+	}
+	return set
+}
+
+// changedFields returns the names of the fields among those of a and b
+// that differ. Only these are compared, since fields such as Sinks hold
+// values reflect.DeepEqual cannot compare, which documents do not set.
+func changedFields(a, b *Config, fieldsOfA, fieldsOfB map[string]bool) []string {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var changed []string
+	for i := 0; i < va.NumField(); i++ {
+		name := va.Type().Field(i).Name
+		if (fieldsOfA[name] || fieldsOfB[name]) && !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// applyConfigDocument returns base with the fields set in doc replaced.
+func applyConfigDocument(base Config, doc []byte) (Config, error) {
+	if len(bytes.TrimSpace(doc)) == 0 {
+		return base, nil
+	}
+	// Copy the slices so decoding into them leaves base untouched.
+	v := reflect.ValueOf(&base).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Slice && !f.IsNil() {
+			c := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+			reflect.Copy(c, f)
+			f.Set(c)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&base); err != nil {
+		return Config{}, fmt.Errorf("logging config document: %w", err)
+	}
+	return base, nil
+}
+
+func reportConfigError(err error) {
+	if l := logInstance.Load(); l != nil {
+		l.Warn("applying logging config failed", zap.Error(err))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "logger: applying logging config failed: %v\n", err)
+}
+
+// watchBackoff returns how long a config watch waits before retrying
+// after failures consecutive failures.
+func watchBackoff(failures int) time.Duration {
+	d := time.Second << failures
+	if failures > 5 || d > 30*time.Second {
+		d = 30 * time.Second
+	}
+	return d
+}
+
+// sleepCtx waits for d or until ctx is done, reporting whether d passed.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,245 @@
+package logger
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newConsoleCores prints entries below config.StderrLevel to stdout and
+// the rest to stderr, as container platforms and CLI tools expect. In
+// development mode on a terminal, the console is unbuffered and a status
+// line for progress updates is kept on stderr, drawn through the returned
+// writer.
+func newConsoleCores(encoderConfig zapcore.EncoderConfig, format outputFormat, config *Config, unbuffered bool) ([]zapcore.Core, *progressWriter) {
+	newEncoder := func(cfg zapcore.EncoderConfig, f *os.File) zapcore.Encoder {
+		if config.consoleJSON(f) {
+			return format.wrap(newJSONEncoder(cfg))
+		}
+		if config.consoleColor(f) {
+			cfg.EncodeLevel = colorLevelEncoder(cfg.EncodeLevel)
+		}
+		enc := newFoldingEncoder(zapcore.NewConsoleEncoder(cfg))
+		if config.ConsoleLocale != "" {
+			enc = &localizingEncoder{Encoder: enc, locale: config.ConsoleLocale}
+		}
+		return format.wrap(enc)
+	}
+	var line *statusLine
+	if config.isDevelopment() && isTerminal(os.Stderr) {
+		line = &statusLine{out: os.Stderr}
+		unbuffered = true
+	}
+	withLine := func(ws zapcore.WriteSyncer) zapcore.WriteSyncer {
+		if line == nil {
+			return ws
+		}
+		return &progressWriter{out: ws, line: line}
+	}
+	stdout := withLine(newConsoleWriter(os.Stdout, config, unbuffered))
+	var progress *progressWriter
+	if line != nil {
+		progress = &progressWriter{out: stdout, enc: newEncoder(encoderConfig, os.Stdout), tty: true, line: line}
+	}
+	if strings.EqualFold(config.StderrLevel, "off") {
+		return []zapcore.Core{
+			newOutputCore(newEncoder(encoderConfig, os.Stdout), stdout, allLevels),
+		}, progress
+	}
+	split := zapcore.WarnLevel
+	if lvl, err := ParseLevel(config.StderrLevel); config.StderrLevel != "" && err == nil {
+		split = lvl
+	}
+	return []zapcore.Core{
+		newOutputCore(
+			newEncoder(encoderConfig, os.Stdout),
+			stdout,
+			zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+				return lvl < split
+			}),
+		),
+		newOutputCore(
+			newEncoder(encoderConfig, os.Stderr),
+			withLine(newConsoleWriter(os.Stderr, config, unbuffered)),
+			split,
+		),
+	}, progress
+}
+
+// consoleCore is the console output, which skips entries drawn there as
+// a progress bar instead.
+type consoleCore struct {
+	zapcore.Core
+}
+
+func (c consoleCore) With(fields []zapcore.Field) zapcore.Core {
+	if hiddenFromConsole(fields) {
+		return zapcore.NewNopCore()
+	}
+	return consoleCore{c.Core.With(fields)}
+}
+
+func (c consoleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c consoleCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if hiddenFromConsole(fields) {
+		return nil
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+func (c consoleCore) writeBatch(entries []batchEntry) error {
+	kept := make([]batchEntry, 0, len(entries))
+	for _, e := range entries {
+		if !hiddenFromConsole(e.fields) {
+			kept = append(kept, e)
+		}
+	}
+	return writeBatch(c.Core, kept)
+}
+
+// newConsoleWriter buffers writes to f unless unbuffered is set, e.g. for
+// serverless functions, which are frozen between invocations and must not
+// leave entries in a buffer or a flush timer running.
+func newConsoleWriter(f *os.File, config *Config, unbuffered bool) zapcore.WriteSyncer {
+	var ws zapcore.WriteSyncer = zapcore.AddSync(f)
+	if !unbuffered {
+		ws = newShardedWriter(ws)
+	}
+	if config.MaxLineBytes > 0 {
+		ws = newLineSplitter(ws, config.MaxLineBytes)
+	}
+	return ws
+}
+
+const (
+	consoleShardBytes    = 32 * 1024
+	consoleFlushInterval = 100 * time.Millisecond
+)
+
+// shardedWriter spreads concurrent writes over GOMAXPROCS buffers, taken
+// in turn, so writers rarely wait on the same lock, and writes all of them
+// to out together. Entries are numbered as they are buffered and merged
+// back in that order, so the entries of a goroutine never appear out of
+// order; every entry reaches out inside a single Write, so lines never
+// interleave. Buffers are flushed when one is full, on Sync and at most
+// consoleFlushInterval after an entry is written, which is how long an
+// entry may wait before it appears.
+type shardedWriter struct {
+	out     zapcore.WriteSyncer
+	shards  []writerShard
+	next    uint32
+	seq     uint64
+	pending int32
+	merged  []byte // used while every shard is locked
+}
+
+type writerShard struct {
+	mu      sync.Mutex
+	buf     []byte
+	entries []shardEntry
+	_       [40]byte // keep shards on separate cache lines
+}
+
+// shardEntry is the number of a buffered entry and where it ends in buf.
+type shardEntry struct {
+	seq uint64
+	end int
+}
+
+func newShardedWriter(out zapcore.WriteSyncer) *shardedWriter {
+	return &shardedWriter{
+		out:    out,
+		shards: make([]writerShard, runtime.GOMAXPROCS(0)),
+	}
+}
+
+func (w *shardedWriter) Write(p []byte) (int, error) {
+	s := &w.shards[atomic.AddUint32(&w.next, 1)%uint32(len(w.shards))]
+	s.mu.Lock()
+	// Numbered under the lock, so the entries of a shard are in order
+	seq := atomic.AddUint64(&w.seq, 1)
+	s.buf = append(s.buf, p...)
+	s.entries = append(s.entries, shardEntry{seq: seq, end: len(s.buf)})
+	full := len(s.buf) >= consoleShardBytes
+	s.mu.Unlock()
+
+	var err error
+	if full {
+		err = w.flush()
+	}
+	if atomic.CompareAndSwapInt32(&w.pending, 0, 1) {
+		time.AfterFunc(consoleFlushInterval, func() {
+			atomic.StoreInt32(&w.pending, 0)
+			w.flush()
+		})
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *shardedWriter) Sync() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.out.Sync()
+}
+
+// flush merges the buffered entries of every shard in the order they were
+// numbered and writes them.
+func (w *shardedWriter) flush() error {
+	for i := range w.shards {
+		w.shards[i].mu.Lock()
+	}
+	defer func() {
+		for i := range w.shards {
+			w.shards[i].mu.Unlock()
+		}
+	}()
+	w.merged = w.merged[:0]
+	next := make([]int, len(w.shards)) // entries of each shard merged
+	for {
+		first := -1
+		for i := range w.shards {
+			s := &w.shards[i]
+			if next[i] < len(s.entries) && (first < 0 || s.entries[next[i]].seq < w.shards[first].entries[next[first]].seq) {
+				first = i
+			}
+		}
+		if first < 0 {
+			break
+		}
+		s := &w.shards[first]
+		start := 0
+		if next[first] > 0 {
+			start = s.entries[next[first]-1].end
+		}
+		w.merged = append(w.merged, s.buf[start:s.entries[next[first]].end]...)
+		next[first]++
+	}
+	for i := range w.shards {
+		w.shards[i].buf = w.shards[i].buf[:0]
+		w.shards[i].entries = w.shards[i].entries[:0]
+	}
+	if len(w.merged) == 0 {
+		return nil
+	}
+	_, err := w.out.Write(w.merged)
+	return err
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"os"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Config.ConsoleFormat values.
+const (
+	ConsoleFormatAuto  = "auto"  // human-readable on terminals, JSON elsewhere
+	ConsoleFormatHuman = "human" // always human-readable
+	ConsoleFormatJSON  = "json"  // always JSON
+)
+
+// Config.ConsoleColor values.
+const (
+	ConsoleColorAuto   = "auto" // on terminals, or as the environment says
+	ConsoleColorAlways = "always"
+	ConsoleColorNever  = "never"
+)
+
+// consoleJSON reports whether the console prints JSON to f.
+func (c *Config) consoleJSON(f *os.File) bool {
+	switch strings.ToLower(c.ConsoleFormat) {
+	case ConsoleFormatAuto:
+		return !isTerminal(f)
+	case ConsoleFormatHuman:
+		return false
+	case ConsoleFormatJSON:
+		return true
+	}
+	return c.ConsoleJSON
+}
+
+// consoleColor reports whether the human-readable console format colors
+// the levels it prints to f. Unless ConsoleColor decides, the environment
+// conventions of command-line tools do, in this order: NO_COLOR set to
+// anything disables colors (https://no-color.org), CLICOLOR_FORCE other
+// than 0 enables them even when f is not a terminal, and CLICOLOR=0
+// disables them (https://bixense.com/clicolors).
+func (c *Config) consoleColor(f *os.File) bool {
+	switch strings.ToLower(c.ConsoleColor) {
+	case ConsoleColorAlways:
+		return true
+	case ConsoleColorNever:
+		return false
+	}
+	switch {
+	case os.Getenv("NO_COLOR") != "":
+		return false
+	case envEnabled("CLICOLOR_FORCE"):
+		return true
+	case os.Getenv("CLICOLOR") == "0":
+		return false
+	}
+	return isTerminal(f)
+}
+
+// envEnabled reports whether the variable key is set to anything but 0.
+func envEnabled(key string) bool {
+	v := os.Getenv(key)
+	return v != "" && v != "0"
+}
+
+// ANSI colors of the levels, as zap colors them.
+const (
+	colorMagenta = "\x1b[35m"
+	colorBlue    = "\x1b[34m"
+	colorYellow  = "\x1b[33m"
+	colorRed     = "\x1b[31m"
+	colorReset   = "\x1b[0m"
+)
+
+func levelColor(lvl zapcore.Level) string {
+	switch {
+	case lvl < zapcore.InfoLevel:
+		return colorMagenta
+	case lvl < zapcore.WarnLevel:
+		return colorBlue
+	case lvl < zapcore.ErrorLevel:
+		return colorYellow
+	}
+	return colorRed
+}
+
+// colorLevelEncoder colors the level names enc writes, registered names
+// included.
+func colorLevelEncoder(enc zapcore.LevelEncoder) zapcore.LevelEncoder {
+	return func(lvl zapcore.Level, pae zapcore.PrimitiveArrayEncoder) {
+		enc(lvl, coloredArrayEncoder{pae, levelColor(lvl)})
+	}
+}
+
+// coloredArrayEncoder wraps the strings appended to it in a color.
+type coloredArrayEncoder struct {
+	zapcore.PrimitiveArrayEncoder
+	color string
+}
+
+func (e coloredArrayEncoder) AppendString(s string) {
+	e.PrimitiveArrayEncoder.AppendString(e.color + s + colorReset)
+}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ConsulSource watches a Consul KV key holding a logging config document,
+// using blocking queries so changes arrive as they are made.
+type ConsulSource struct {
+	// Address of the Consul agent, http://127.0.0.1:8500 by default.
+	Address string
+	Key     string
+
+	// Token is sent as X-Consul-Token when set.
+	Token  string
+	Client *http.Client
+}
+
+func (s *ConsulSource) Watch(ctx context.Context, fn func(doc []byte)) error {
+	var (
+		index    uint64
+		failures int
+		seen     bool
+	)
+	for ctx.Err() == nil {
+		doc, next, err := s.get(ctx, index)
+		if err != nil {
+			if !sleepCtx(ctx, watchBackoff(failures)) {
+				break
+			}
+			failures++
+			continue
+		}
+		failures = 0
+		if next < index {
+			// The index went backwards, e.g. after a snapshot restore.
+			index = 0
+			continue
+		}
+		if next != index || !seen {
+			fn(doc)
+			seen = true
+		}
+		index = next
+	}
+	return ctx.Err()
+}
+
+// get performs a blocking query for the key, returning its value, nil if
+// it does not exist, and the index to block on next.
+func (s *ConsulSource) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	addr := s.Address
+	if addr == "" {
+		addr = "http://127.0.0.1:8500"
+	}
+	segments := strings.Split(strings.TrimPrefix(s.Key, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	u := fmt.Sprintf("%s/v1/kv/%s?raw&wait=5m&index=%d",
+		strings.TrimSuffix(addr, "/"), strings.Join(segments, "/"), index)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Consul-Token", s.Token)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, next, nil
+	case http.StatusNotFound:
+		return nil, next, nil
+	}
+	return nil, 0, fmt.Errorf("consul: %s", resp.Status)
+}
@@ -0,0 +1,199 @@
+package logger
+
+import (
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// batchWriter is implemented by cores that can write many entries at once.
+type batchWriter interface {
+	writeBatch(entries []batchEntry) error
+}
+
+// outputCore is a zapcore ioCore that keeps its encoder and writer, so a
+// batch can be encoded into one buffer and written with a single Write,
+// or with one Write per entry to a datagramWriter.
+type outputCore struct {
+	zapcore.Core
+	enc zapcore.Encoder
+	out zapcore.WriteSyncer
+}
+
+func newOutputCore(enc zapcore.Encoder, out zapcore.WriteSyncer, enab zapcore.LevelEnabler) *outputCore {
+	return &outputCore{
+		Core: zapcore.NewCore(enc, out, enab),
+		enc:  enc,
+		out:  out,
+	}
+}
+
+func (c *outputCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return &outputCore{
+		Core: c.Core.With(fields),
+		enc:  enc,
+		out:  c.out,
+	}
+}
+
+func (c *outputCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// datagramWriter is implemented by writers sending each Write as one
+// message, which must be given a single entry at a time.
+type datagramWriter interface {
+	datagrams() bool
+}
+
+func (c *outputCore) writeBatch(entries []batchEntry) error {
+	var (
+		errs []error
+		data []byte
+		sync bool
+	)
+	dw, ok := c.out.(datagramWriter)
+	perEntry := ok && dw.datagrams()
+	for _, e := range entries {
+		if !c.Enabled(e.ent.Level) {
+			continue
+		}
+		buf, err := c.enc.EncodeEntry(e.ent, e.fields)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if perEntry {
+			if _, err := c.out.Write(buf.Bytes()); err != nil {
+				errs = append(errs, err)
+			}
+		} else {
+			data = append(data, buf.Bytes()...)
+		}
+		buf.Free()
+		// Like ioCore, sync after entries that may end the process.
+		sync = sync || e.ent.Level > zapcore.ErrorLevel
+	}
+	if len(data) > 0 {
+		if _, err := c.out.Write(data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if sync {
+		errs = append(errs, c.out.Sync())
+	}
+	return multierr.Combine(errs...)
+}
+
+// teeCore duplicates entries into several cores like zapcore.NewTee, and
+// passes batches on to those that can write them at once.
+type teeCore []zapcore.Core
+
+func newTee(cores ...zapcore.Core) zapcore.Core {
+	return teeCore(cores)
+}
+
+func (t teeCore) Enabled(lvl zapcore.Level) bool {
+	for _, c := range t {
+		if c.Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := make(teeCore, len(t))
+	for i, c := range t {
+		clone[i] = c.With(fields)
+	}
+	return clone
+}
+
+func (t teeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	for _, c := range t {
+		ce = c.Check(ent, ce)
+	}
+	return ce
+}
+
+func (t teeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var errs []error
+	for _, c := range t {
+		errs = append(errs, c.Write(ent, fields))
+	}
+	return multierr.Combine(errs...)
+}
+
+func (t teeCore) Sync() error {
+	var errs []error
+	for _, c := range t {
+		errs = append(errs, c.Sync())
+	}
+	return multierr.Combine(errs...)
+}
+
+func (t teeCore) writeBatch(entries []batchEntry) error {
+	var errs []error
+	for _, c := range t {
+		errs = append(errs, writeBatch(c, entries))
+	}
+	return multierr.Combine(errs...)
+}
+
+// orderedCore writes entries to all the outputs of its tee under one lock
+// shared with the cores derived with With, for Config.OrderedOutputs.
+// The outputs queue what they are given in order, so each receives the
+// entries in the order they took the lock.
+type orderedCore struct {
+	zapcore.Core
+	mu *sync.Mutex
+}
+
+func (c orderedCore) With(fields []zapcore.Field) zapcore.Core {
+	return orderedCore{Core: c.Core.With(fields), mu: c.mu}
+}
+
+func (c orderedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c orderedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+func (c orderedCore) writeBatch(entries []batchEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return writeBatch(c.Core, entries)
+}
+
+// writeBatch writes entries through c, one at a time unless c is a
+// batchWriter.
+func writeBatch(c zapcore.Core, entries []batchEntry) error {
+	if bw, ok := c.(batchWriter); ok {
+		return bw.writeBatch(entries)
+	}
+	for _, e := range entries {
+		if ce := c.Check(e.ent, nil); ce != nil {
+			ce.Write(e.fields...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"fmt"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Critical returns a logger for entries that must not be lost, such as
+// payment or compliance events. Its entries skip sampling, and before a
+// call returns they are fsynced to the log files, flushed from the console
+// buffer and delivered by queued sinks. Level filters still apply.
+func (l *Logger) Critical(fields ...zap.Field) *Logger {
+	base := l.critical
+	if base == nil {
+		base = l.zap
+	}
+	child := *l
+	child.zap = base.With(fields...)
+	child.critical = child.zap
+	return &child
+}
+
+// durableCore writes like Core and then runs sync, which makes the entry
+// durable on every output.
+type durableCore struct {
+	zapcore.Core
+	sync func() error
+}
+
+func (c durableCore) With(fields []zapcore.Field) zapcore.Core {
+	return durableCore{Core: c.Core.With(fields), sync: c.sync}
+}
+
+func (c durableCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c durableCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return c.sync()
+}
+
+// syncDurable flushes every output and fsyncs those backed by files.
+func syncDurable(outputs []output) error {
+	var errs []error
+	for _, o := range outputs {
+		sync := o.sync
+		if o.fsync != nil {
+			sync = o.fsync
+		}
+		if err := sync(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", o.name, err))
+		}
+	}
+	return multierr.Combine(errs...)
+}
@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// DeadLetter is an entry a Delivery gave up on, as recorded in the
+// DeliveryConfig.DeadLetterPath file, one JSON object per line.
+type DeadLetter struct {
+	Time     time.Time       `json:"time"` // when it was given up
+	Sink     string          `json:"sink"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	Entry    json.RawMessage `json:"entry"` // as the sink received it
+}
+
+// deadLetterFile is an append-only NDJSON file of DeadLetters.
+type deadLetterFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (f *deadLetterFile) append(sink string, cause error, attempts int, entries [][]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	now := time.Now()
+	for _, entry := range entries {
+		entry = bytes.TrimSpace(entry)
+		raw := json.RawMessage(entry)
+		if !json.Valid(entry) {
+			raw, _ = json.Marshal(string(entry))
+		}
+		enc.Encode(DeadLetter{Time: now, Sink: sink, Error: cause.Error(), Attempts: attempts, Entry: raw})
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *deadLetterFile) appendLines(r io.Reader) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadDeadLetters returns the dead letters in the file at path, for a
+// look at what was given up and why before re-driving them.
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var letters []DeadLetter
+	dec := json.NewDecoder(f)
+	for {
+		var l DeadLetter
+		err := dec.Decode(&l)
+		if errors.Is(err, io.EOF) {
+			return letters, nil
+		}
+		if err != nil {
+			return letters, fmt.Errorf("%s: %w", path, err)
+		}
+		letters = append(letters, l)
+	}
+}
+
+// RedriveDeadLetters writes the entries of the dead letters at path to
+// sink, typically a sink of the same kind as the one that gave them up,
+// once the cause is fixed, and returns how many it wrote. The sink should
+// be synced or closed afterwards; a Delivery records again the entries it
+// still cannot deliver, and needs a QueueSize holding them all.
+//
+// The file is moved aside first, so a running sink may keep adding dead
+// letters to it meanwhile. The entries not written, after an error, are
+// put back.
+func RedriveDeadLetters(path string, sink Sink) (int, error) {
+	redrive := path + ".redrive"
+	// A file left by an interrupted re-drive is taken up again.
+	if _, err := os.Stat(redrive); err != nil {
+		if err := os.Rename(path, redrive); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return 0, nil
+			}
+			return 0, err
+		}
+	}
+	f, err := os.Open(redrive)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(redrive)
+	defer f.Close()
+
+	sent := 0
+	r := bufio.NewReader(f)
+	for {
+		line, rerr := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err := redriveLetter(sink, line); err != nil {
+				// Put back this letter and the rest.
+				back := &deadLetterFile{path: path}
+				if perr := back.appendLines(io.MultiReader(bytes.NewReader(line), r)); perr != nil {
+					return sent, multierr.Combine(err, perr)
+				}
+				return sent, err
+			}
+			sent++
+		}
+		if errors.Is(rerr, io.EOF) {
+			return sent, nil
+		}
+		if rerr != nil {
+			return sent, rerr
+		}
+	}
+}
+
+// redriveLetter writes the entry of an encoded DeadLetter to sink.
+func redriveLetter(sink Sink, line []byte) error {
+	var l DeadLetter
+	if err := json.Unmarshal(line, &l); err != nil {
+		return fmt.Errorf("bad dead letter: %w", err)
+	}
+	entry := []byte(l.Entry)
+	var text string
+	if json.Unmarshal(l.Entry, &text) == nil {
+		entry = []byte(text)
+	}
+	_, err := sink.Write(append(entry, '\n'))
+	return err
+}
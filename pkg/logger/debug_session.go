@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DebugLogHeader carries a debug session token. HTTPMiddleware activates
+// debug logging for requests with one of Config.DebugLogTokens; services
+// forward the header on their outgoing calls to extend the session.
+const DebugLogHeader = "X-Debug-Log"
+
+// DebugSessionKey marks the entries of a debug session, so they can be
+// found and exempted from sampling with WithSamplingExemption.
+const DebugSessionKey = "debug_session"
+
+type debugSessionKey struct{}
+
+// ActivateDebug returns a context in which loggers from Ctx log debug
+// entries whatever the configured level, for a single request or trace.
+func ActivateDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugSessionKey{}, true)
+}
+
+// DebugActive reports whether ActivateDebug was called for ctx.
+func DebugActive(ctx context.Context) bool {
+	active, _ := ctx.Value(debugSessionKey{}).(bool)
+	return active
+}
+
+// debugSession is a field that levelCore's With looks for to let debug
+// entries through; encoders skip it.
+var debugSession = zap.Field{Key: "debug_session_marker", Type: zapcore.SkipType}
+
+func hasDebugSession(fields []zapcore.Field) bool {
+	for _, f := range fields {
+		if f.Key == debugSession.Key && f.Type == zapcore.SkipType {
+			return true
+		}
+	}
+	return false
+}
+
+// validDebugToken reports whether token is one of the configured tokens.
+func (l *Logger) validDebugToken(token string) bool {
+	for _, t := range l.debugTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,703 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// Transport sends a batch of encoded entries to a remote endpoint. Send is
+// only ever called from one goroutine at a time and must not retain batch
+// after it returns.
+type Transport interface {
+	Send(ctx context.Context, batch [][]byte) error
+	Close() error
+}
+
+// DeliveryConfig tunes how a Delivery queues, batches and retries entries.
+// Zero values select the defaults noted on each field.
+type DeliveryConfig struct {
+	QueueSize     int           // entries buffered in memory, default 10000
+	BatchSize     int           // entries per Send, default 500
+	FlushInterval time.Duration // maximum time an entry waits in a partial batch, default 1s
+	SendTimeout   time.Duration // deadline for a single Send, default 10s
+
+	MaxRetries int           // retries per batch before it is given up, default 5
+	MinBackoff time.Duration // delay before the first retry, default 100ms
+	MaxBackoff time.Duration // upper bound of the exponential backoff, default 30s
+
+	// After BreakerThreshold consecutive batches fail, Send is not called
+	// for BreakerCooldown; a single probe attempt then decides whether the
+	// circuit closes again. Defaults to 5 batches and 30s.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// SpillPath, when set, is a file that receives entries which could not
+	// be queued or delivered. They are re-sent once the endpoint recovers.
+	// Without it such entries are dropped.
+	SpillPath string
+
+	// DeadLetterPath, when set, is an NDJSON file that receives the
+	// entries of batches given up after their retries or rejected with a
+	// Permanent error, as DeadLetter records saying why, instead of being
+	// spilled or dropped. They are not re-sent on their own: inspect them,
+	// fix the cause and re-drive them with RedriveDeadLetters. Batches held
+	// back by an open circuit are still spilled.
+	DeadLetterPath string
+
+	// WALDir, when set, replaces the in-memory queue with a write-ahead log
+	// in that directory, so queued entries survive restarts and outages and
+	// are replayed on startup. Entries are delivered at least once. The log
+	// is split into segments of WALSegmentBytes (default 16MB) and the
+	// oldest segments are discarded once it exceeds WALMaxBytes (default
+	// 1GB).
+	WALDir          string
+	WALSegmentBytes int64
+	WALMaxBytes     int64
+}
+
+func (c DeliveryConfig) withDefaults() DeliveryConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 10000
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
+	}
+	if c.SendTimeout <= 0 {
+		c.SendTimeout = 10 * time.Second
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 5
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 30 * time.Second
+	}
+	if c.BreakerThreshold <= 0 {
+		c.BreakerThreshold = 5
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = 30 * time.Second
+	}
+	if c.WALSegmentBytes <= 0 {
+		c.WALSegmentBytes = 16 << 20
+	}
+	if c.WALMaxBytes <= 0 {
+		c.WALMaxBytes = 1 << 30
+	}
+	if c.WALMaxBytes < 2*c.WALSegmentBytes {
+		c.WALMaxBytes = 2 * c.WALSegmentBytes
+	}
+	return c
+}
+
+var errCircuitOpen = errors.New("circuit open")
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a Transport error as not worth retrying, e.g. a request
+// the endpoint rejected as malformed. The batch is dropped immediately and
+// does not count towards opening the circuit.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// Delivery is a Sink that hands entries to a Transport from a background
+// goroutine, so remote sinks share one implementation of queueing,
+// batching, exponential backoff, circuit breaking and spill-to-disk.
+type Delivery struct {
+	name      string
+	transport Transport
+	cfg       DeliveryConfig
+	queue     deliveryQueue
+	durable   bool
+	spill     *spillFile
+	dead      *deadLetterFile
+
+	wake     chan struct{}
+	flushReq chan chan error
+	done     chan struct{}
+	stopped  chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+
+	// Owned by the run goroutine.
+	failures  int
+	openUntil time.Time
+	attempts  int // made by the last deliver
+
+	dropped      uint64
+	deadLettered uint64
+	seen         SequenceTracker // of the entries delivered
+
+	// down is set while deliveries fail; behindSince is when the queue
+	// was last empty or a batch last delivered, in Unix nanoseconds, and 0
+	// while it is empty.
+	down        atomic.Bool
+	behindSince atomic.Int64
+
+	// keys are those of the logger the sink is an output of.
+	keys atomic.Value
+
+	flushes  latencyRecorder // of Send
+	pressure pressureWatches
+	errMu    sync.Mutex
+	lastErr  error
+}
+
+// NewDelivery starts delivering entries written to the returned sink
+// through t.
+func NewDelivery(name string, t Transport, cfg DeliveryConfig) (*Delivery, error) {
+	cfg = cfg.withDefaults()
+	d := &Delivery{
+		name:      name,
+		transport: t,
+		cfg:       cfg,
+		wake:      make(chan struct{}, 1),
+		flushReq:  make(chan chan error),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	if cfg.WALDir != "" {
+		wal, err := openWAL(cfg.WALDir, cfg.WALSegmentBytes, cfg.WALMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", name, err)
+		}
+		d.queue = wal
+		d.durable = true
+	} else {
+		d.queue = &memQueue{max: cfg.QueueSize}
+	}
+	if cfg.SpillPath != "" {
+		d.spill = &spillFile{path: cfg.SpillPath}
+	}
+	if cfg.DeadLetterPath != "" {
+		d.dead = &deadLetterFile{path: cfg.DeadLetterPath}
+	}
+	go d.run()
+	return d, nil
+}
+
+func (d *Delivery) Name() string {
+	return d.name
+}
+
+func (d *Delivery) setEntryKeys(keys entryKeys) {
+	d.keys.Store(keys)
+}
+
+// Write queues p. It never blocks: when the queue is full or closed the
+// entry is spilled or dropped.
+func (d *Delivery) Write(p []byte) (int, error) {
+	select {
+	case <-d.done:
+		d.overflow([][]byte{append([]byte(nil), p...)})
+		return len(p), nil
+	default:
+	}
+	if err := d.queue.push(p); err != nil {
+		d.overflow([][]byte{append([]byte(nil), p...)})
+		d.pressure.check(d.name, d.queueFill)
+		return len(p), nil
+	}
+	d.behindSince.CompareAndSwap(0, time.Now().UnixNano())
+	d.pressure.check(d.name, d.queueFill)
+	if d.queue.len() >= d.cfg.BatchSize {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Sync delivers everything queued so far and reports whether it succeeded.
+func (d *Delivery) Sync() error {
+	reply := make(chan error, 1)
+	select {
+	case d.flushReq <- reply:
+		return <-reply
+	case <-d.stopped:
+		return nil
+	}
+}
+
+// Close flushes the queue, making a single attempt per batch, and closes
+// the transport. With a WAL, entries that could not be sent stay on disk
+// and are delivered once the sink is started again.
+func (d *Delivery) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.done)
+		<-d.stopped
+		d.closeErr = multierr.Combine(d.queue.close(), d.transport.Close())
+	})
+	return d.closeErr
+}
+
+// Dropped returns the number of entries discarded because they could be
+// neither delivered, spilled nor kept as dead letters.
+func (d *Delivery) Dropped() uint64 {
+	return atomic.LoadUint64(&d.dropped) + d.queue.lost()
+}
+
+// DeadLettered returns the number of entries written to the dead letter
+// file.
+func (d *Delivery) DeadLettered() uint64 {
+	return atomic.LoadUint64(&d.deadLettered)
+}
+
+// Loss counts the entries delivered and, when they carry sequence numbers
+// (Config.EntryIDs), those missing from what was delivered: dropped here,
+// or lost before reaching the sink. Spilled entries are missing until they
+// are delivered.
+func (d *Delivery) Loss() LossReport {
+	return d.seen.Report()
+}
+
+// Health returns the state of the sink.
+func (d *Delivery) Health() SinkHealth {
+	h := SinkHealth{
+		Name:         d.name,
+		Enabled:      true,
+		Connected:    !d.down.Load(),
+		QueueDepth:   d.queue.len(),
+		Dropped:      d.Dropped(),
+		DeadLettered: d.DeadLettered(),
+		Missing:      d.Loss().Missing,
+	}
+	if since := d.behindSince.Load(); since != 0 && h.QueueDepth > 0 {
+		h.Lag = time.Since(time.Unix(0, since))
+	}
+	if err := d.LastError(); err != nil {
+		h.LastError = err.Error()
+	}
+	return h
+}
+
+// QueueStats returns the depth and oldest entry age of the queue and the
+// latency of sending batches.
+func (d *Delivery) QueueStats() QueueStats {
+	s := QueueStats{
+		Output:       "sink " + d.name,
+		Depth:        d.queue.len(),
+		FlushLatency: d.flushes.snapshot(),
+	}
+	if q, ok := d.queue.(*memQueue); ok {
+		s.Capacity = q.max
+		if t, ok := q.oldest(); ok {
+			s.OldestAge = time.Since(t)
+		}
+	} else if since := d.behindSince.Load(); since != 0 && s.Depth > 0 {
+		s.OldestAge = time.Since(time.Unix(0, since))
+	}
+	return s
+}
+
+func (d *Delivery) queueFill() float64 {
+	q, ok := d.queue.(*memQueue)
+	if !ok {
+		return 0
+	}
+	return float64(q.len()) / float64(q.max)
+}
+
+func (d *Delivery) watchPressure(w *pressureWatch) (stop func()) {
+	if d.durable {
+		return func() {}
+	}
+	return d.pressure.add(w)
+}
+
+// LastError returns the most recent delivery failure.
+func (d *Delivery) LastError() error {
+	d.errMu.Lock()
+	defer d.errMu.Unlock()
+	return d.lastErr
+}
+
+func (d *Delivery) run() {
+	defer close(d.stopped)
+	ticker := time.NewTicker(d.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.wake:
+			d.process(true)
+		case <-ticker.C:
+			d.process(false)
+			d.replaySpill()
+		case reply := <-d.flushReq:
+			err := d.process(false)
+			if err == nil {
+				err = d.queue.sync()
+			}
+			reply <- err
+		case <-d.done:
+			d.process(false)
+			return
+		}
+	}
+}
+
+// process delivers queued entries batch by batch until the queue is empty,
+// or until less than a full batch is left when fullOnly is set. A batch
+// that cannot be delivered stays at the head of a durable queue to be
+// retried later; otherwise it is given up.
+func (d *Delivery) process(fullOnly bool) error {
+	if d.durable && time.Now().Before(d.openUntil) {
+		// Leave the log untouched instead of reading batches just to fail.
+		return errCircuitOpen
+	}
+	for {
+		batch, err := d.queue.peek(d.cfg.BatchSize)
+		if err != nil {
+			d.setLastError(err)
+			return err
+		}
+		if len(batch) == 0 {
+			d.behindSince.Store(0)
+			return nil
+		}
+		if fullOnly && len(batch) < d.cfg.BatchSize {
+			return nil
+		}
+		err = d.deliver(batch)
+		if err != nil {
+			if d.durable && !isPermanent(err) {
+				return err
+			}
+			d.giveUp(batch, err)
+		}
+		if cerr := d.queue.commit(); cerr != nil {
+			d.setLastError(cerr)
+			return cerr
+		}
+		if err == nil {
+			d.behindSince.Store(time.Now().UnixNano())
+		}
+		d.pressure.check(d.name, d.queueFill)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// deliver sends batch with retries and updates the circuit breaker.
+func (d *Delivery) deliver(batch [][]byte) error {
+	d.attempts = 0
+	if time.Now().Before(d.openUntil) {
+		return errCircuitOpen
+	}
+	attempts := d.cfg.MaxRetries + 1
+	if d.failures >= d.cfg.BreakerThreshold {
+		// Half-open: one probe decides whether the endpoint is back.
+		attempts = 1
+	}
+
+	var err error
+	backoff := d.cfg.MinBackoff
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if !d.sleep(backoff) {
+				break
+			}
+			if backoff *= 2; backoff > d.cfg.MaxBackoff {
+				backoff = d.cfg.MaxBackoff
+			}
+		}
+		d.attempts++
+		if err = d.send(batch); err == nil {
+			for _, entry := range batch {
+				d.seen.ObserveEntry(entry)
+			}
+			d.failures = 0
+			d.openUntil = time.Time{}
+			d.down.Store(false)
+			return nil
+		}
+		if isPermanent(err) {
+			d.setLastError(err)
+			return err
+		}
+	}
+
+	d.setLastError(err)
+	d.down.Store(true)
+	d.failures++
+	if d.failures >= d.cfg.BreakerThreshold {
+		d.openUntil = time.Now().Add(d.cfg.BreakerCooldown)
+	}
+	return err
+}
+
+func isPermanent(err error) bool {
+	var perm permanentError
+	return errors.As(err, &perm)
+}
+
+func (d *Delivery) send(batch [][]byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.SendTimeout)
+	defer cancel()
+	start := time.Now()
+	if keys, ok := d.keys.Load().(entryKeys); ok {
+		ctx = withEntryKeys(ctx, keys)
+	}
+	err := d.transport.Send(ctx, batch)
+	d.flushes.observe(time.Since(start))
+	if err != nil {
+		return fmt.Errorf("sink %s: %w", d.name, err)
+	}
+	return nil
+}
+
+// sleep waits for a jittered backoff, returning false if the delivery is
+// closed in the meantime.
+func (d *Delivery) sleep(backoff time.Duration) bool {
+	wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-d.done:
+		return false
+	}
+}
+
+// giveUp disposes of a batch that deliver failed with err: it becomes dead
+// letters when there is a file for them, and is otherwise dropped when the
+// error is permanent and spilled when not.
+func (d *Delivery) giveUp(batch [][]byte, err error) {
+	if d.dead != nil && !errors.Is(err, errCircuitOpen) {
+		derr := d.dead.append(d.name, err, d.attempts, batch)
+		if derr == nil {
+			atomic.AddUint64(&d.deadLettered, uint64(len(batch)))
+			return
+		}
+		d.setLastError(derr)
+	}
+	if isPermanent(err) {
+		atomic.AddUint64(&d.dropped, uint64(len(batch)))
+		return
+	}
+	d.overflow(batch)
+}
+
+func (d *Delivery) overflow(entries [][]byte) {
+	if d.spill != nil {
+		err := d.spill.append(entries)
+		if err == nil {
+			return
+		}
+		d.setLastError(err)
+	}
+	atomic.AddUint64(&d.dropped, uint64(len(entries)))
+}
+
+func (d *Delivery) setLastError(err error) {
+	d.errMu.Lock()
+	d.lastErr = err
+	d.errMu.Unlock()
+}
+
+// replaySpill re-sends spilled entries unless the circuit is open. Entries
+// that fail again are spilled anew, and the unread remainder is put back
+// behind them.
+func (d *Delivery) replaySpill() {
+	if d.spill == nil || time.Now().Before(d.openUntil) {
+		return
+	}
+	path, ok := d.spill.take()
+	if !ok {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		d.setLastError(err)
+		return
+	}
+	defer os.Remove(path)
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	batch := make([][]byte, 0, d.cfg.BatchSize)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			batch = append(batch, line)
+		}
+		if len(batch) >= d.cfg.BatchSize || (err != nil && len(batch) > 0) {
+			if derr := d.deliver(batch); derr != nil {
+				d.giveUp(batch, derr)
+				d.spill.appendFrom(r)
+				return
+			}
+			batch = make([][]byte, 0, d.cfg.BatchSize)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// deliveryQueue holds entries until they are delivered. peek returns up to
+// max entries from the head without removing them; commit removes the
+// entries returned by the last peek.
+type deliveryQueue interface {
+	push(entry []byte) error
+	peek(max int) ([][]byte, error)
+	commit() error
+	len() int
+	lost() uint64
+	sync() error
+	close() error
+}
+
+var errQueueFull = errors.New("queue full")
+
+// memQueue is the default bounded in-memory queue.
+type memQueue struct {
+	mu      sync.Mutex
+	max     int
+	entries [][]byte
+	queued  []time.Time // when each entry was pushed
+	peeked  int
+}
+
+func (q *memQueue) push(entry []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries) >= q.max {
+		return errQueueFull
+	}
+	q.entries = append(q.entries, append([]byte(nil), entry...))
+	q.queued = append(q.queued, time.Now())
+	return nil
+}
+
+// oldest returns when the entry at the head was pushed.
+func (q *memQueue) oldest() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.queued) == 0 {
+		return time.Time{}, false
+	}
+	return q.queued[0], true
+}
+
+func (q *memQueue) peek(max int) ([][]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := len(q.entries)
+	if n > max {
+		n = max
+	}
+	q.peeked = n
+	return append([][]byte(nil), q.entries[:n]...), nil
+}
+
+func (q *memQueue) commit() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := 0; i < q.peeked; i++ {
+		q.entries[i] = nil
+	}
+	q.entries = q.entries[q.peeked:]
+	q.queued = q.queued[q.peeked:]
+	q.peeked = 0
+	return nil
+}
+
+func (q *memQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+func (q *memQueue) lost() uint64 { return 0 }
+func (q *memQueue) sync() error  { return nil }
+func (q *memQueue) close() error { return nil }
+
+// spillFile is an append-only NDJSON file of entries awaiting delivery.
+type spillFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (s *spillFile) append(entries [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range entries {
+		w.Write(entry)
+		if len(entry) > 0 && entry[len(entry)-1] != '\n' {
+			w.WriteByte('\n')
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *spillFile) appendFrom(r io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// take moves the spill file aside for replay. A replay file left behind by
+// an interrupted process is picked up first.
+func (s *spillFile) take() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	replay := s.path + ".replay"
+	if _, err := os.Stat(replay); err == nil {
+		return replay, true
+	}
+	if info, err := os.Stat(s.path); err != nil || info.Size() == 0 {
+		return "", false
+	}
+	if err := os.Rename(s.path, replay); err != nil {
+		return "", false
+	}
+	return replay, true
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DockerMaxLineBytes is the line length above which Docker's json-file
+// driver breaks a line into several log records.
+const DockerMaxLineBytes = 16 * 1024
+
+// splitRecord is one part of a line that was too long to write whole.
+// Concatenating the chunks of all parts with the same split_id, ordered by
+// part, restores the original line.
+type splitRecord struct {
+	SplitID string `json:"split_id"`
+	Part    int    `json:"part"`
+	Parts   int    `json:"parts"`
+	Chunk   string `json:"chunk"`
+}
+
+// lineSplitter writes lines up to max bytes, newline included, unchanged
+// and replaces longer ones with splitRecord lines.
+type lineSplitter struct {
+	zapcore.WriteSyncer
+	max int
+}
+
+func newLineSplitter(out zapcore.WriteSyncer, max int) zapcore.WriteSyncer {
+	return &lineSplitter{WriteSyncer: out, max: max}
+}
+
+func (s *lineSplitter) Write(p []byte) (int, error) {
+	if len(p) <= s.max {
+		return s.WriteSyncer.Write(p)
+	}
+	var out []byte
+	for rest := p; len(rest) > 0; {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line, rest = rest[:i+1], rest[i+1:]
+		} else {
+			rest = nil
+		}
+		if len(line) <= s.max {
+			out = append(out, line...)
+			continue
+		}
+		out = s.appendSplit(out, bytes.TrimSuffix(line, []byte{'\n'}))
+	}
+	if _, err := s.WriteSyncer.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *lineSplitter) appendSplit(out, line []byte) []byte {
+	// Leave room for the record's own keys; escaping may grow a chunk
+	// further, in which case it is cut shorter.
+	budget := s.max - 128
+	if budget < 64 {
+		budget = 64
+	}
+	var chunks [][]byte
+	for len(line) > 0 {
+		n := len(line)
+		if n > budget {
+			n = budget
+		}
+		for {
+			for n < len(line) && n > 1 && !utf8.RuneStart(line[n]) {
+				n--
+			}
+			if enc, _ := json.Marshal(string(line[:n])); len(enc) <= budget || n <= 1 {
+				break
+			}
+			n /= 2
+		}
+		chunks = append(chunks, line[:n])
+		line = line[n:]
+	}
+	id := randomHex(8)
+	for i, chunk := range chunks {
+		rec, err := json.Marshal(splitRecord{SplitID: id, Part: i + 1, Parts: len(chunks), Chunk: string(chunk)})
+		if err != nil {
+			continue
+		}
+		out = append(out, rec...)
+		out = append(out, '\n')
+	}
+	return out
+}
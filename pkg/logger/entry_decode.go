@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// decodedEntry is a JSON entry split into the standard keys and the rest,
+// for sinks whose backends store the time, level and message separately.
+type decodedEntry struct {
+	Time    time.Time
+	Level   string
+	Message string
+	Fields  map[string]json.RawMessage
+}
+
+// entryKeys are the names the JSON encoder uses for the standard fields,
+// and the layout of its timestamps when they are neither RFC 3339 nor
+// epoch numbers.
+type entryKeys struct {
+	Time, Level, Message string
+
+	TimeLayout string
+	TimeZone   *time.Location // of TimeLayout times without an offset
+}
+
+var defaultEntryKeys = entryKeys{Time: "ts", Level: "level", Message: "msg"}
+
+func newEntryKeys(encoderConfig zapcore.EncoderConfig, format outputFormat) entryKeys {
+	keys := entryKeys{
+		Time:    encoderConfig.TimeKey,
+		Level:   encoderConfig.LevelKey,
+		Message: encoderConfig.MessageKey,
+	}
+	switch strings.ToLower(format.time) {
+	case "", TimeFormatRFC3339Nano, TimeFormatRFC3339, TimeFormatEpochSeconds, TimeFormatEpochMillis:
+	default:
+		keys.TimeLayout = format.time
+		loc, err := loadTimeZone(format.zone)
+		if err != nil {
+			loc = beijingLocation
+		}
+		keys.TimeZone = loc
+	}
+	return keys
+}
+
+// keyedSink is implemented by sinks that decode the entries they receive,
+// which are told the keys of the logger they are an output of when it is
+// built.
+type keyedSink interface {
+	setEntryKeys(keys entryKeys)
+}
+
+type entryKeysKey struct{}
+
+// withEntryKeys returns a context carrying keys, with which Delivery
+// tells its transport how to decode the batch it sends.
+func withEntryKeys(ctx context.Context, keys entryKeys) context.Context {
+	return context.WithValue(ctx, entryKeysKey{}, keys)
+}
+
+// entryKeysFrom returns the keys carried by ctx, or the defaults.
+func entryKeysFrom(ctx context.Context) entryKeys {
+	if keys, ok := ctx.Value(entryKeysKey{}).(entryKeys); ok {
+		return keys
+	}
+	return defaultEntryKeys
+}
+
+func decodeEntry(entry []byte, keys entryKeys) (decodedEntry, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(bytes.TrimSpace(entry), &fields); err != nil {
+		return decodedEntry{}, err
+	}
+	d := decodedEntry{Fields: fields}
+	if raw, ok := fields[keys.Time]; ok {
+		d.Time = parseEntryTime(raw, keys)
+		delete(fields, keys.Time)
+	}
+	if raw, ok := fields[keys.Level]; ok {
+		json.Unmarshal(raw, &d.Level)
+		delete(fields, keys.Level)
+	}
+	if raw, ok := fields[keys.Message]; ok {
+		json.Unmarshal(raw, &d.Message)
+		delete(fields, keys.Message)
+	}
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+	return d, nil
+}
+
+// parseEntryTime accepts strings in the layout of keys or RFC 3339, and
+// epoch seconds or milliseconds. It returns the zero time for others.
+func parseEntryTime(raw json.RawMessage, keys entryKeys) time.Time {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if keys.TimeLayout != "" {
+			if t, err := time.ParseInLocation(keys.TimeLayout, s, keys.TimeZone); err == nil {
+				return t
+			}
+		}
+		t, _ := time.Parse(time.RFC3339Nano, s)
+		return t
+	}
+	var secs float64
+	if json.Unmarshal(raw, &secs) == nil {
+		if secs > 1e11 {
+			// Seconds this large are past the year 5000; read milliseconds.
+			secs /= 1000
+		}
+		whole, frac := math.Modf(secs)
+		return time.Unix(int64(whole), int64(frac*1e9))
+	}
+	return time.Time{}
+}
+
+// fieldsJSON re-encodes the non-standard fields as a JSON object.
+func (d decodedEntry) fieldsJSON() []byte {
+	b, err := json.Marshal(d.Fields)
+	if err != nil {
+		return []byte("{}")
+	}
+	return b
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EntryIDKey and SequenceKey are the fields Config.EntryIDs adds: a ULID
+// unique to the entry, and its number in the entries of the process,
+// counting from 1.
+const (
+	EntryIDKey  = "entry_id"
+	SequenceKey = "seq"
+)
+
+// entryIDCore stamps every entry reaching the outputs with an ID and a
+// sequence number. Entries dropped before, by level or sampling, take no
+// number, so a gap in the sequence of the merged outputs is an entry
+// lost on the way; an output receiving only some levels has gaps of its
+// own.
+type entryIDCore struct {
+	zapcore.Core
+	ids *entryIDs
+}
+
+func (c entryIDCore) With(fields []zapcore.Field) zapcore.Core {
+	return entryIDCore{c.Core.With(fields), c.ids}
+}
+
+func (c entryIDCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c entryIDCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(c.stamp(ent, fields)...)
+	}
+	return nil
+}
+
+func (c entryIDCore) writeBatch(entries []batchEntry) error {
+	stamped := make([]batchEntry, len(entries))
+	for i, e := range entries {
+		stamped[i] = batchEntry{ent: e.ent, fields: c.stamp(e.ent, e.fields)}
+	}
+	return writeBatch(c.Core, stamped)
+}
+
+func (c entryIDCore) stamp(ent zapcore.Entry, fields []zapcore.Field) []zapcore.Field {
+	id, seq := c.ids.next(ent.Time)
+	return append(fields[:len(fields):len(fields)], zap.String(EntryIDKey, id), zap.Uint64(SequenceKey, seq))
+}
+
+// entryIDs hands out increasing ULIDs and sequence numbers.
+type entryIDs struct {
+	mu   sync.Mutex
+	seq  uint64
+	ms   uint64   // of the last ULID
+	rand [10]byte // of the last ULID
+}
+
+// next returns the ID and number of an entry logged at t. IDs sort in the
+// order they are handed out: within a millisecond, or when the clock goes
+// back, the random part of the last one is incremented.
+func (g *entryIDs) next(t time.Time) (string, uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seq++
+	ms := uint64(t.UnixMilli())
+	if ms > g.ms {
+		g.ms = ms
+		if _, err := rand.Read(g.rand[:]); err != nil {
+			panic(fmt.Sprintf("logger: reading random bytes: %v", err))
+		}
+	} else {
+		g.increment()
+	}
+	return g.ulid(), g.seq
+}
+
+// increment adds one to the random part, carrying into the time when it
+// overflows.
+func (g *entryIDs) increment() {
+	for i := len(g.rand) - 1; i >= 0; i-- {
+		g.rand[i]++
+		if g.rand[i] != 0 {
+			return
+		}
+	}
+	g.ms++
+}
+
+// crockford is the base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulid encodes the 48-bit time and 80 random bits as 26 characters.
+func (g *entryIDs) ulid() string {
+	var b [16]byte
+	binary.BigEndian.PutUint16(b[0:], uint16(g.ms>>32))
+	binary.BigEndian.PutUint32(b[2:], uint32(g.ms))
+	copy(b[6:], g.rand[:])
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	// 128 bits are 26 characters of 5 bits, the first holding 3.
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Stack trace policies for Config.StackTraces.
+const (
+	StackTracesAlways = "always" // every Error, Errorf and ErrorNoErr entry
+	StackTracesError  = "error"  // only entries still at error level or above after classification
+	StackTracesNever  = "never"
+)
+
+// Error detection strategies for Config.ErrorfDetection.
+const (
+	ErrorfDetectFirst   = "first"   // the first argument that is an error
+	ErrorfDetectWrapped = "wrapped" // only errors formatted with %w
+	ErrorfDetectNone    = "none"    // never; Errorf only formats
+)
+
+// stackFields adds a stack trace to the fields of an entry at lvl when the
+// stack trace policy asks for one.
+func (l *Logger) stackFields(lvl zapcore.Level, fields []zap.Field) []zap.Field {
+	switch strings.ToLower(l.stackTraces) {
+	case StackTracesNever:
+		return fields
+	case StackTracesError:
+		if lvl < zapcore.ErrorLevel {
+			return fields
+		}
+	}
+	return append(fields[:len(fields):len(fields)], zap.String("stacktrace", captureStack()))
+}
+
+// errorfError returns the error Errorf reports for its arguments and the
+// formatted error, or nil.
+func (l *Logger) errorfError(formatted error, args []interface{}) error {
+	switch strings.ToLower(l.errorfDetection) {
+	case ErrorfDetectNone:
+		return nil
+	case ErrorfDetectWrapped:
+		// formatted wraps exactly the %w operands.
+		switch formatted.(type) {
+		case interface{ Unwrap() error }, interface{ Unwrap() []error }:
+			return formatted
+		}
+		return nil
+	}
+	for _, arg := range args {
+		if err, ok := arg.(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// zapErrorChain returns an "errors" field listing the constituents of err
+// when it was built with errors.Join, fmt.Errorf with several %w verbs or a
+// multi-error library, so each cause can be indexed on its own.
+func zapErrorChain(err error) (zap.Field, bool) {
+	errs := flattenErrors(err)
+	if len(errs) < 2 {
+		return zap.Skip(), false
+	}
+	return zap.Array("errors", errorChain(errs)), true
+}
+
+// flattenErrors finds the first multi-error in err's Unwrap chain and
+// returns its leaves, expanding nested multi-errors.
+func flattenErrors(err error) []error {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if errs := multiErrors(e); errs != nil {
+			var leaves []error
+			for _, inner := range errs {
+				if inner == nil {
+					continue
+				}
+				if nested := multiErrors(inner); nested != nil {
+					leaves = append(leaves, flattenErrors(inner)...)
+				} else {
+					leaves = append(leaves, inner)
+				}
+			}
+			return leaves
+		}
+	}
+	return nil
+}
+
+func multiErrors(err error) []error {
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		return e.Unwrap()
+	case interface{ Errors() []error }:
+		return e.Errors()
+	case interface{ WrappedErrors() []error }:
+		return e.WrappedErrors()
+	}
+	return nil
+}
+
+type errorChain []error
+
+func (c errorChain) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, err := range c {
+		if err := enc.AppendObject(chainedError{err}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type chainedError struct {
+	err error
+}
+
+func (e chainedError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", e.err.Error())
+	enc.AddString("type", fmt.Sprintf("%T", e.err))
+	return nil
+}
@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EtcdSource watches an etcd v3 key holding a logging config document
+// through etcd's JSON gateway, so no gRPC client is needed.
+type EtcdSource struct {
+	// Endpoint of an etcd member, http://127.0.0.1:2379 by default.
+	Endpoint string
+	Key      string
+
+	// Headers are set on every request, e.g. Authorization with a token
+	// from /v3/auth/authenticate.
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// etcd gateway messages; int64s are encoded as strings and bytes in
+// base64.
+type (
+	etcdKeyValue struct {
+		Value       []byte `json:"value"`
+		ModRevision string `json:"mod_revision"`
+	}
+	etcdHeader struct {
+		Revision string `json:"revision"`
+	}
+	etcdRangeResponse struct {
+		Header etcdHeader     `json:"header"`
+		Kvs    []etcdKeyValue `json:"kvs"`
+	}
+	etcdWatchResponse struct {
+		Result struct {
+			Header   etcdHeader `json:"header"`
+			Canceled bool       `json:"canceled"`
+			Events   []struct {
+				Type string       `json:"type"` // omitted for PUT
+				Kv   etcdKeyValue `json:"kv"`
+			} `json:"events"`
+		} `json:"result"`
+	}
+)
+
+func (s *EtcdSource) Watch(ctx context.Context, fn func(doc []byte)) error {
+	var (
+		rev      int64 // of the last document passed to fn, 0 before the first
+		failures int
+	)
+	for ctx.Err() == nil {
+		var err error
+		if rev == 0 {
+			rev, err = s.load(ctx, fn)
+		}
+		if err == nil {
+			rev, err = s.watch(ctx, rev, fn)
+		}
+		if err != nil && ctx.Err() == nil {
+			if !sleepCtx(ctx, watchBackoff(failures)) {
+				break
+			}
+			failures++
+			continue
+		}
+		failures = 0
+	}
+	return ctx.Err()
+}
+
+// load passes the current document to fn and returns the store revision.
+func (s *EtcdSource) load(ctx context.Context, fn func(doc []byte)) (int64, error) {
+	resp, err := s.post(ctx, "/v3/kv/range", map[string]interface{}{"key": []byte(s.Key)})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var r etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return 0, err
+	}
+	rev, err := strconv.ParseInt(r.Header.Revision, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("etcd: revision %q: %w", r.Header.Revision, err)
+	}
+	if len(r.Kvs) > 0 {
+		fn(r.Kvs[0].Value)
+	} else {
+		fn(nil)
+	}
+	return rev, nil
+}
+
+// watch streams changes after rev to fn until the stream ends, returning
+// the revision reached.
+func (s *EtcdSource) watch(ctx context.Context, rev int64, fn func(doc []byte)) (int64, error) {
+	resp, err := s.post(ctx, "/v3/watch", map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            []byte(s.Key),
+			"start_revision": strconv.FormatInt(rev+1, 10),
+		},
+	})
+	if err != nil {
+		return rev, err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var w etcdWatchResponse
+		if err := dec.Decode(&w); err != nil {
+			return rev, err
+		}
+		if w.Result.Canceled {
+			// The revision was compacted away; load the key again.
+			return 0, fmt.Errorf("etcd: watch canceled")
+		}
+		for _, ev := range w.Result.Events {
+			if ev.Type == "DELETE" {
+				fn(nil)
+			} else {
+				fn(ev.Kv.Value)
+			}
+			if r, err := strconv.ParseInt(ev.Kv.ModRevision, 10, 64); err == nil && r > rev {
+				rev = r
+			}
+		}
+	}
+}
+
+func (s *EtcdSource) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "http://127.0.0.1:2379"
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd: %s", resp.Status)
+	}
+	return resp, nil
+}
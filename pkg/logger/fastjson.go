@@ -0,0 +1,465 @@
+package logger
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// fastJSONEncoder writes the same JSON as zap's encoder, appending to a
+// plain byte slice and copying runs of plain ASCII in strings at once.
+// Entries whose fields are all primitives, strings, durations and times
+// take this path; others, and loggers with such fields added through
+// With, fall back to zap's encoder, which also receives every field.
+type fastJSONEncoder struct {
+	zapcore.Encoder
+	cfg        *zapcore.EncoderConfig
+	lineEnding string
+	ctx        jsonAppender // fields added through With
+	slow       bool         // a With field needs zap's encoder
+}
+
+// NewFastJSONEncoder returns an encoder producing the same output as
+// zapcore.NewJSONEncoder(cfg), faster for entries with simple fields.
+// Building with the fastjson tag makes it the encoder of every JSON output;
+// cmd/logbench compares the two.
+func NewFastJSONEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	lineEnding := cfg.LineEnding
+	if cfg.SkipLineEnding {
+		lineEnding = ""
+	} else if lineEnding == "" {
+		lineEnding = zapcore.DefaultLineEnding
+	}
+	enc := &fastJSONEncoder{
+		Encoder:    zapcore.NewJSONEncoder(cfg),
+		cfg:        &cfg,
+		lineEnding: lineEnding,
+	}
+	enc.ctx.cfg = enc.cfg
+	return enc
+}
+
+var (
+	fastJSONBuffers   = buffer.NewPool()
+	fastJSONAppenders = sync.Pool{New: func() interface{} { return &jsonAppender{} }}
+)
+
+func (e *fastJSONEncoder) Clone() zapcore.Encoder {
+	clone := &fastJSONEncoder{
+		Encoder:    e.Encoder.Clone(),
+		cfg:        e.cfg,
+		lineEnding: e.lineEnding,
+		slow:       e.slow,
+	}
+	clone.ctx = jsonAppender{b: append([]byte(nil), e.ctx.b...), cfg: e.cfg}
+	return clone
+}
+
+func (e *fastJSONEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if e.slow || !fastFields(fields) {
+		return e.Encoder.EncodeEntry(ent, fields)
+	}
+	a := fastJSONAppenders.Get().(*jsonAppender)
+	a.b, a.cfg = append(a.b[:0], '{'), e.cfg
+	cfg := e.cfg
+
+	if cfg.LevelKey != "" && cfg.EncodeLevel != nil {
+		a.addKey(cfg.LevelKey)
+		cur := len(a.b)
+		cfg.EncodeLevel(ent.Level, a)
+		if cur == len(a.b) {
+			a.AppendString(ent.Level.String())
+		}
+	}
+	if cfg.TimeKey != "" {
+		a.addKey(cfg.TimeKey)
+		a.AppendTime(ent.Time)
+	}
+	if ent.LoggerName != "" && cfg.NameKey != "" {
+		a.addKey(cfg.NameKey)
+		cur := len(a.b)
+		encodeName := cfg.EncodeName
+		if encodeName == nil {
+			encodeName = zapcore.FullNameEncoder
+		}
+		encodeName(ent.LoggerName, a)
+		if cur == len(a.b) {
+			a.AppendString(ent.LoggerName)
+		}
+	}
+	if ent.Caller.Defined {
+		if cfg.CallerKey != "" {
+			a.addKey(cfg.CallerKey)
+			cur := len(a.b)
+			if cfg.EncodeCaller != nil {
+				cfg.EncodeCaller(ent.Caller, a)
+			}
+			if cur == len(a.b) {
+				a.AppendString(ent.Caller.String())
+			}
+		}
+		if cfg.FunctionKey != "" {
+			a.addKey(cfg.FunctionKey)
+			a.AppendString(ent.Caller.Function)
+		}
+	}
+	if cfg.MessageKey != "" {
+		a.addKey(cfg.MessageKey)
+		a.AppendString(ent.Message)
+	}
+	if len(e.ctx.b) > 0 {
+		a.separate()
+		a.b = append(a.b, e.ctx.b...)
+	}
+	for _, f := range fields {
+		a.addField(f)
+	}
+	if ent.Stack != "" && cfg.StacktraceKey != "" {
+		a.addKey(cfg.StacktraceKey)
+		a.AppendString(ent.Stack)
+	}
+	a.b = append(a.b, '}')
+	a.b = append(a.b, e.lineEnding...)
+
+	buf := fastJSONBuffers.Get()
+	buf.Write(a.b)
+	fastJSONAppenders.Put(a)
+	return buf, nil
+}
+
+// fastFields reports whether the fast path can encode all fields.
+func fastFields(fields []zapcore.Field) bool {
+	for _, f := range fields {
+		switch f.Type {
+		case zapcore.StringType, zapcore.BoolType, zapcore.SkipType,
+			zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type,
+			zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType,
+			zapcore.Float64Type, zapcore.Float32Type,
+			zapcore.DurationType, zapcore.TimeType, zapcore.TimeFullType:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// The ObjectEncoder methods add With fields to both encoders.
+
+func (e *fastJSONEncoder) AddString(k, v string) {
+	e.Encoder.AddString(k, v)
+	e.ctx.addKey(k)
+	e.ctx.AppendString(v)
+}
+
+func (e *fastJSONEncoder) AddBool(k string, v bool) {
+	e.Encoder.AddBool(k, v)
+	e.ctx.addKey(k)
+	e.ctx.AppendBool(v)
+}
+
+func (e *fastJSONEncoder) AddInt64(k string, v int64) {
+	e.Encoder.AddInt64(k, v)
+	e.ctx.addKey(k)
+	e.ctx.AppendInt64(v)
+}
+
+func (e *fastJSONEncoder) AddInt(k string, v int)     { e.AddInt64(k, int64(v)) }
+func (e *fastJSONEncoder) AddInt32(k string, v int32) { e.AddInt64(k, int64(v)) }
+func (e *fastJSONEncoder) AddInt16(k string, v int16) { e.AddInt64(k, int64(v)) }
+func (e *fastJSONEncoder) AddInt8(k string, v int8)   { e.AddInt64(k, int64(v)) }
+
+func (e *fastJSONEncoder) AddUint64(k string, v uint64) {
+	e.Encoder.AddUint64(k, v)
+	e.ctx.addKey(k)
+	e.ctx.AppendUint64(v)
+}
+
+func (e *fastJSONEncoder) AddUint(k string, v uint)       { e.AddUint64(k, uint64(v)) }
+func (e *fastJSONEncoder) AddUint32(k string, v uint32)   { e.AddUint64(k, uint64(v)) }
+func (e *fastJSONEncoder) AddUint16(k string, v uint16)   { e.AddUint64(k, uint64(v)) }
+func (e *fastJSONEncoder) AddUint8(k string, v uint8)     { e.AddUint64(k, uint64(v)) }
+func (e *fastJSONEncoder) AddUintptr(k string, v uintptr) { e.AddUint64(k, uint64(v)) }
+
+func (e *fastJSONEncoder) AddFloat64(k string, v float64) {
+	e.Encoder.AddFloat64(k, v)
+	e.ctx.addKey(k)
+	e.ctx.AppendFloat64(v)
+}
+
+func (e *fastJSONEncoder) AddFloat32(k string, v float32) {
+	e.Encoder.AddFloat32(k, v)
+	e.ctx.addKey(k)
+	e.ctx.AppendFloat32(v)
+}
+
+func (e *fastJSONEncoder) AddDuration(k string, v time.Duration) {
+	e.Encoder.AddDuration(k, v)
+	e.ctx.addKey(k)
+	e.ctx.AppendDuration(v)
+}
+
+func (e *fastJSONEncoder) AddTime(k string, v time.Time) {
+	e.Encoder.AddTime(k, v)
+	e.ctx.addKey(k)
+	e.ctx.AppendTime(v)
+}
+
+func (e *fastJSONEncoder) AddArray(k string, v zapcore.ArrayMarshaler) error {
+	e.slow = true
+	return e.Encoder.AddArray(k, v)
+}
+
+func (e *fastJSONEncoder) AddObject(k string, v zapcore.ObjectMarshaler) error {
+	e.slow = true
+	return e.Encoder.AddObject(k, v)
+}
+
+func (e *fastJSONEncoder) AddReflected(k string, v interface{}) error {
+	e.slow = true
+	return e.Encoder.AddReflected(k, v)
+}
+
+func (e *fastJSONEncoder) AddBinary(k string, v []byte) {
+	e.slow = true
+	e.Encoder.AddBinary(k, v)
+}
+
+func (e *fastJSONEncoder) AddByteString(k string, v []byte) {
+	e.slow = true
+	e.Encoder.AddByteString(k, v)
+}
+
+func (e *fastJSONEncoder) AddComplex128(k string, v complex128) {
+	e.slow = true
+	e.Encoder.AddComplex128(k, v)
+}
+
+func (e *fastJSONEncoder) AddComplex64(k string, v complex64) {
+	e.slow = true
+	e.Encoder.AddComplex64(k, v)
+}
+
+func (e *fastJSONEncoder) OpenNamespace(k string) {
+	e.slow = true
+	e.Encoder.OpenNamespace(k)
+}
+
+// jsonAppender appends JSON to b. It is the PrimitiveArrayEncoder passed
+// to the level, time, duration, name and caller encoders of cfg.
+type jsonAppender struct {
+	b   []byte
+	cfg *zapcore.EncoderConfig
+}
+
+// separate adds a comma unless b is empty or ends where a value starts.
+func (a *jsonAppender) separate() {
+	if n := len(a.b); n > 0 {
+		switch a.b[n-1] {
+		case '{', '[', ':', ',':
+		default:
+			a.b = append(a.b, ',')
+		}
+	}
+}
+
+func (a *jsonAppender) addKey(key string) {
+	a.separate()
+	a.b = append(a.b, '"')
+	a.b = appendJSONString(a.b, key)
+	a.b = append(a.b, '"', ':')
+}
+
+// addField adds a field of a type fastFields accepts.
+func (a *jsonAppender) addField(f zapcore.Field) {
+	switch f.Type {
+	case zapcore.SkipType:
+		return
+	case zapcore.StringType:
+		a.addKey(f.Key)
+		a.AppendString(f.String)
+	case zapcore.BoolType:
+		a.addKey(f.Key)
+		a.AppendBool(f.Integer == 1)
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		a.addKey(f.Key)
+		a.AppendInt64(f.Integer)
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		a.addKey(f.Key)
+		a.AppendUint64(uint64(f.Integer))
+	case zapcore.Float64Type:
+		a.addKey(f.Key)
+		a.AppendFloat64(math.Float64frombits(uint64(f.Integer)))
+	case zapcore.Float32Type:
+		a.addKey(f.Key)
+		a.AppendFloat32(math.Float32frombits(uint32(f.Integer)))
+	case zapcore.DurationType:
+		a.addKey(f.Key)
+		a.AppendDuration(time.Duration(f.Integer))
+	case zapcore.TimeType:
+		t := time.Unix(0, f.Integer)
+		if loc, ok := f.Interface.(*time.Location); ok {
+			t = t.In(loc)
+		}
+		a.addKey(f.Key)
+		a.AppendTime(t)
+	case zapcore.TimeFullType:
+		a.addKey(f.Key)
+		a.AppendTime(f.Interface.(time.Time))
+	}
+}
+
+func (a *jsonAppender) AppendString(v string) {
+	a.separate()
+	a.b = append(a.b, '"')
+	a.b = appendJSONString(a.b, v)
+	a.b = append(a.b, '"')
+}
+
+func (a *jsonAppender) AppendByteString(v []byte) {
+	a.AppendString(string(v))
+}
+
+func (a *jsonAppender) AppendBool(v bool) {
+	a.separate()
+	a.b = strconv.AppendBool(a.b, v)
+}
+
+func (a *jsonAppender) AppendInt64(v int64) {
+	a.separate()
+	a.b = strconv.AppendInt(a.b, v, 10)
+}
+
+func (a *jsonAppender) AppendInt(v int)     { a.AppendInt64(int64(v)) }
+func (a *jsonAppender) AppendInt32(v int32) { a.AppendInt64(int64(v)) }
+func (a *jsonAppender) AppendInt16(v int16) { a.AppendInt64(int64(v)) }
+func (a *jsonAppender) AppendInt8(v int8)   { a.AppendInt64(int64(v)) }
+
+func (a *jsonAppender) AppendUint64(v uint64) {
+	a.separate()
+	a.b = strconv.AppendUint(a.b, v, 10)
+}
+
+func (a *jsonAppender) AppendUint(v uint)       { a.AppendUint64(uint64(v)) }
+func (a *jsonAppender) AppendUint32(v uint32)   { a.AppendUint64(uint64(v)) }
+func (a *jsonAppender) AppendUint16(v uint16)   { a.AppendUint64(uint64(v)) }
+func (a *jsonAppender) AppendUint8(v uint8)     { a.AppendUint64(uint64(v)) }
+func (a *jsonAppender) AppendUintptr(v uintptr) { a.AppendUint64(uint64(v)) }
+
+func (a *jsonAppender) AppendFloat64(v float64) { a.appendFloat(v, 64) }
+func (a *jsonAppender) AppendFloat32(v float32) { a.appendFloat(float64(v), 32) }
+
+// appendFloat writes NaN and infinities as strings, like zap.
+func (a *jsonAppender) appendFloat(v float64, bitSize int) {
+	a.separate()
+	switch {
+	case math.IsNaN(v):
+		a.b = append(a.b, `"NaN"`...)
+	case math.IsInf(v, 1):
+		a.b = append(a.b, `"+Inf"`...)
+	case math.IsInf(v, -1):
+		a.b = append(a.b, `"-Inf"`...)
+	default:
+		a.b = strconv.AppendFloat(a.b, v, 'f', -1, bitSize)
+	}
+}
+
+func (a *jsonAppender) AppendComplex128(v complex128) { a.appendComplex(v, 64) }
+func (a *jsonAppender) AppendComplex64(v complex64)   { a.appendComplex(complex128(v), 32) }
+
+func (a *jsonAppender) appendComplex(v complex128, bitSize int) {
+	a.separate()
+	r, i := real(v), imag(v)
+	a.b = append(a.b, '"')
+	a.b = strconv.AppendFloat(a.b, r, 'f', -1, bitSize)
+	if i >= 0 {
+		a.b = append(a.b, '+')
+	}
+	a.b = strconv.AppendFloat(a.b, i, 'f', -1, bitSize)
+	a.b = append(a.b, 'i', '"')
+}
+
+func (a *jsonAppender) AppendDuration(v time.Duration) {
+	cur := len(a.b)
+	if e := a.cfg.EncodeDuration; e != nil {
+		e(v, a)
+	}
+	if cur == len(a.b) {
+		a.AppendInt64(int64(v))
+	}
+}
+
+func (a *jsonAppender) AppendTime(v time.Time) {
+	cur := len(a.b)
+	if e := a.cfg.EncodeTime; e != nil {
+		e(v, a)
+	}
+	if cur == len(a.b) {
+		a.AppendInt64(v.UnixNano())
+	}
+}
+
+// AppendTimeLayout writes t formatted with layout without building a
+// string, for the time encoders that check for it like zap's do.
+func (a *jsonAppender) AppendTimeLayout(t time.Time, layout string) {
+	a.separate()
+	a.b = append(a.b, '"')
+	a.b = t.AppendFormat(a.b, layout)
+	a.b = append(a.b, '"')
+}
+
+// jsonPlain marks the ASCII bytes copied into JSON strings as they are.
+var jsonPlain = func() (plain [utf8.RuneSelf]bool) {
+	for c := 0x20; c < utf8.RuneSelf; c++ {
+		plain[c] = c != '"' && c != '\\'
+	}
+	return plain
+}()
+
+const lowerHex = "0123456789abcdef"
+
+// appendJSONString appends s escaped like zap does: quotes, backslashes
+// and control characters are escaped, and invalid UTF-8 becomes U+FFFD.
+// Runs of bytes needing no escape are copied at once.
+func appendJSONString(b []byte, s string) []byte {
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if jsonPlain[c] {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', lowerHex[c>>4], lowerHex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i++
+			start = i
+			continue
+		}
+		i += size
+	}
+	return append(b, s[start:]...)
+}
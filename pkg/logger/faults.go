@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjectedFault is the error of calls failed by FaultySink and
+// FaultyTransport unless Faults.Err is set.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Faults are the adverse conditions FaultySink and FaultyTransport inject,
+// to test how an application, and the retries, breaker and spill of
+// Delivery, behave with a slow or failing backend. Rates are fractions of
+// calls, from 0 to 1.
+type Faults struct {
+	Latency time.Duration // added to every call
+	Jitter  time.Duration // random extra latency, up to this
+
+	// FailureRate of calls fail with Err, writing nothing.
+	FailureRate float64
+
+	// PartialRate of calls write only part of their data, then fail: a
+	// sink write is cut short with io.ErrShortWrite, and a transport sends
+	// the first part of the batch and fails with Err.
+	PartialRate float64
+
+	Err error // ErrInjectedFault by default
+
+	// Seed makes the random choices repeatable; 0 picks a random seed.
+	Seed int64
+}
+
+// FaultStats counts the calls of a faulty sink or transport.
+type FaultStats struct {
+	Calls    uint64
+	Failures uint64 // calls failed outright, including during outages
+	Partials uint64
+}
+
+// faultInjector decides the fate of each call.
+type faultInjector struct {
+	faults Faults
+	down   atomic.Bool
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+
+	calls, failures, partials atomic.Uint64
+}
+
+func newFaultInjector(faults Faults) *faultInjector {
+	if faults.Err == nil {
+		faults.Err = ErrInjectedFault
+	}
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &faultInjector{faults: faults, rnd: rand.New(rand.NewSource(seed))}
+}
+
+// SetDown starts or ends an outage, during which every call fails.
+func (f *faultInjector) SetDown(down bool) {
+	f.down.Store(down)
+}
+
+// Stats returns the calls so far and the faults injected into them.
+func (f *faultInjector) Stats() FaultStats {
+	return FaultStats{Calls: f.calls.Load(), Failures: f.failures.Load(), Partials: f.partials.Load()}
+}
+
+// fault is what happens to one call.
+type fault struct {
+	delay   time.Duration
+	fail    bool
+	partial float64 // fraction of the data written, when above 0
+}
+
+func (f *faultInjector) next() fault {
+	f.calls.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := fault{delay: f.faults.Latency}
+	if f.faults.Jitter > 0 {
+		d.delay += time.Duration(f.rnd.Int63n(int64(f.faults.Jitter)))
+	}
+	switch r := f.rnd.Float64(); {
+	case f.down.Load() || r < f.faults.FailureRate:
+		d.fail = true
+		f.failures.Add(1)
+	case r < f.faults.FailureRate+f.faults.PartialRate:
+		d.partial = f.rnd.Float64()
+		f.partials.Add(1)
+	}
+	return d
+}
+
+// FaultySink is a sink injecting Faults into the writes to another sink,
+// e.g. to check that the application keeps working when a sink is slow or
+// down:
+//
+//	faulty := logger.NewFaultySink(sink, logger.Faults{Latency: 200 * time.Millisecond, FailureRate: 0.3})
+//	log, err := logger.New(logger.WithSink(faulty))
+type FaultySink struct {
+	Sink
+	*faultInjector
+}
+
+// NewFaultySink returns a sink writing to sink with faults.
+func NewFaultySink(sink Sink, faults Faults) *FaultySink {
+	return &FaultySink{Sink: sink, faultInjector: newFaultInjector(faults)}
+}
+
+func (s *FaultySink) Write(p []byte) (int, error) {
+	d := s.next()
+	time.Sleep(d.delay)
+	if d.fail {
+		return 0, s.faults.Err
+	}
+	if d.partial > 0 {
+		n, err := s.Sink.Write(p[:int(d.partial*float64(len(p)))])
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		return n, err
+	}
+	return s.Sink.Write(p)
+}
+
+// FaultyTransport is a Transport injecting Faults into the sends of
+// another, to exercise the retries, breaker and spill of a Delivery:
+//
+//	t := logger.NewFaultyTransport(transport, logger.Faults{Latency: 50 * time.Millisecond, FailureRate: 0.5})
+//	d, err := logger.NewDelivery("flaky", t, logger.DeliveryConfig{})
+//	t.SetDown(true) // an outage, until SetDown(false)
+type FaultyTransport struct {
+	Transport
+	*faultInjector
+}
+
+// NewFaultyTransport returns a transport sending through t with faults.
+func NewFaultyTransport(t Transport, faults Faults) *FaultyTransport {
+	return &FaultyTransport{Transport: t, faultInjector: newFaultInjector(faults)}
+}
+
+// Send waits out the injected latency, giving up when ctx ends first as a
+// slow endpoint would time out.
+func (t *FaultyTransport) Send(ctx context.Context, batch [][]byte) error {
+	d := t.next()
+	if d.delay > 0 {
+		timer := time.NewTimer(d.delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if d.fail {
+		return t.faults.Err
+	}
+	if d.partial > 0 {
+		if n := int(d.partial * float64(len(batch))); n > 0 {
+			if err := t.Transport.Send(ctx, batch[:n]); err != nil {
+				return err
+			}
+		}
+		return t.faults.Err
+	}
+	return t.Transport.Send(ctx, batch)
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package logger
+
+// logFilePath returns path as it is; only Windows limits its length.
+func logFilePath(path string) string {
+	return path
+}
+
+// retryShared runs op. Unix lets open files be renamed and removed, so
+// there is nothing to wait for.
+func retryShared(op func() error) error {
+	return op()
+}
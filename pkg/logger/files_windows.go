@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Windows errors of files opened by another process, e.g. a log shipper
+// reading a backup, which Unix would let be renamed or removed.
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// logFilePath makes path absolute: the os package only lifts the MAX_PATH
+// limit of 260 characters, with the \\?\ and \\?\UNC\ prefixes, for
+// absolute paths, drive letters and UNC shares alike.
+func logFilePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// retryShared runs op, retrying it for up to about a second while it fails
+// because another process has the file open.
+func retryShared(op func() error) error {
+	delay := 10 * time.Millisecond
+	for i := 0; ; i++ {
+		err := op()
+		var errno syscall.Errno
+		if err == nil || i == 6 || !errors.As(err, &errno) {
+			return err
+		}
+		switch errno {
+		case errorAccessDenied, errorSharingViolation, errorLockViolation:
+		default:
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,226 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Flags read from a FlagProvider. Flags that are missing leave their
+// setting unchanged.
+const (
+	FlagLevel      = "log-level"    // a level name, e.g. "debug"
+	FlagSampling   = "log-sampling" // "first/thereafter", e.g. "100/100", or "off"
+	FlagSinkPrefix = "log-sink-"    // followed by a sink name: "on" or "off"
+)
+
+// FlagProvider reads the logging flags of a feature flag system such as
+// LaunchDarkly or ConfigCat, so a fleet of services can be controlled
+// centrally.
+type FlagProvider interface {
+	Flags(ctx context.Context) (map[string]string, error)
+}
+
+// FlagProviderFunc adapts a function to FlagProvider.
+type FlagProviderFunc func(ctx context.Context) (map[string]string, error)
+
+func (f FlagProviderFunc) Flags(ctx context.Context) (map[string]string, error) {
+	return f(ctx)
+}
+
+// FlagSubscriber is implemented by providers that push changes instead of
+// being polled. Subscribe calls fn with all flags whenever they change,
+// until cancel is called.
+type FlagSubscriber interface {
+	Subscribe(fn func(flags map[string]string)) (cancel func())
+}
+
+// ApplyFlags changes the level, sampling and sinks of l as flags say. The
+// error reports the flags with invalid values; the others are applied.
+func (l *Logger) ApplyFlags(flags map[string]string) error {
+	var errs []error
+	for name, value := range flags {
+		var err error
+		switch {
+		case name == FlagLevel:
+			err = l.SetLevel(value)
+		case name == FlagSampling:
+			var first, thereafter int
+			if first, thereafter, err = parseSampling(value); err == nil {
+				l.SetSampling(first, thereafter)
+			}
+		case strings.HasPrefix(name, FlagSinkPrefix):
+			var on bool
+			if on, err = parseSwitch(value); err == nil {
+				err = l.EnableSink(strings.TrimPrefix(name, FlagSinkPrefix), on)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("flag %s: %w", name, err))
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// SetSampling changes the sampling of l and the loggers sharing its
+// outputs, like WithSampling; first <= 0 turns it off.
+func (l *Logger) SetSampling(first, thereafter int) {
+	if l.sampler != nil {
+		l.sampler.setRate(first, thereafter)
+	}
+}
+
+// EnableSink turns the sink with the given name on or off.
+func (l *Logger) EnableSink(name string, enabled bool) error {
+	on, ok := l.sinkSwitches[name]
+	if !ok {
+		return fmt.Errorf("no sink %q", name)
+	}
+	on.Store(enabled)
+	return nil
+}
+
+func parseSampling(value string) (first, thereafter int, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "off") {
+		return 0, 0, nil
+	}
+	f, t, ok := strings.Cut(value, "/")
+	if first, err = strconv.Atoi(f); !ok || err != nil {
+		return 0, 0, fmt.Errorf("invalid sampling %q: want first/thereafter or off", value)
+	}
+	if thereafter, err = strconv.Atoi(t); err != nil {
+		return 0, 0, fmt.Errorf("invalid sampling %q: want first/thereafter or off", value)
+	}
+	return first, thereafter, nil
+}
+
+func parseSwitch(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid value %q: want on or off", value)
+}
+
+// WatchFlags applies the flags of p to l, as they are pushed when p is a
+// FlagSubscriber and otherwise polling every interval, a minute if it is
+// not positive. Invalid flags are logged as warnings. It returns a
+// function that stops watching.
+func (l *Logger) WatchFlags(p FlagProvider, interval time.Duration) (stop func()) {
+	return watchFlags(func() *Logger { return l }, p, interval)
+}
+
+// WatchFlags applies the flags of p to the global logger, including
+// loggers that later replace it through Reinit.
+func WatchFlags(p FlagProvider, interval time.Duration) (stop func()) {
+	return watchFlags(logInstance.Load, p, interval)
+}
+
+// defaultFlagsInterval is the polling interval used for intervals that
+// are not positive.
+const defaultFlagsInterval = time.Minute
+
+func watchFlags(target func() *Logger, p FlagProvider, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultFlagsInterval
+	}
+	var (
+		mu         sync.Mutex
+		last       map[string]string
+		lastLogger *Logger // the flags were applied to
+	)
+	apply := func(flags map[string]string) {
+		mu.Lock()
+		defer mu.Unlock()
+		// A logger that replaced the last one gets even unchanged flags
+		l := target()
+		if l == lastLogger && reflect.DeepEqual(flags, last) {
+			return
+		}
+		last, lastLogger = flags, l
+		if err := l.ApplyFlags(flags); err != nil {
+			l.Warn("invalid logging flags", zap.Error(err))
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	poll := func() {
+		flags, err := p.Flags(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				target().Warn("reading logging flags failed", zap.Error(err))
+			}
+			return
+		}
+		apply(flags)
+	}
+
+	var unsubscribe func()
+	if s, ok := p.(FlagSubscriber); ok {
+		go poll()
+		unsubscribe = s.Subscribe(apply)
+	} else {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				poll()
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			if unsubscribe != nil {
+				unsubscribe()
+			}
+		})
+	}
+}
+
+// switchCore passes entries to Core while on is set.
+type switchCore struct {
+	zapcore.Core
+	on *atomic.Bool
+}
+
+func (c switchCore) Enabled(lvl zapcore.Level) bool {
+	return c.on.Load() && c.Core.Enabled(lvl)
+}
+
+func (c switchCore) With(fields []zapcore.Field) zapcore.Core {
+	return switchCore{Core: c.Core.With(fields), on: c.on}
+}
+
+func (c switchCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.on.Load() {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func (c switchCore) writeBatch(entries []batchEntry) error {
+	if !c.on.Load() {
+		return nil
+	}
+	return writeBatch(c.Core, entries)
+}
@@ -0,0 +1,15 @@
+package logger
+
+import "os"
+
+// Windows has no flock, and files opened for appending are not shared
+// between processes the same way; Config.Validate rejects FileSharing.
+const fileSharingSupported = false
+
+func lockFile(f *os.File) error {
+	return errNoFileLocks
+}
+
+func unlockFile(f *os.File) error {
+	return errNoFileLocks
+}
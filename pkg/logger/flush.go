@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"syscall"
+
+	"go.uber.org/multierr"
+)
+
+// output is one destination a Logger flushes on Sync and, when close is
+// set, releases on Close.
+type output struct {
+	name   string
+	sync   func() error
+	fsync  func() error // for file outputs, flushes them to stable storage
+	reopen func() error // for files and sockets, see Logger.Reopen
+	close  func() error
+	sink   Sink // for sink outputs, which a replacing logger may reuse
+}
+
+// Sync flushes every output, including queued and batched sink entries,
+// and waits until they finish or ctx is done. The error names each output
+// that failed or did not finish in time.
+func (l *Logger) Sync(ctx context.Context) error {
+	outputs := l.outputs
+	if len(outputs) == 0 {
+		outputs = []output{{name: "logger", sync: l.zap.Sync}}
+	}
+	type result struct {
+		i   int
+		err error
+	}
+	results := make(chan result, len(outputs))
+	for i, o := range outputs {
+		i, o := i, o
+		go func() {
+			results <- result{i, o.sync()}
+		}()
+	}
+	var errs []error
+	done := make([]bool, len(outputs))
+	for range outputs {
+		select {
+		case r := <-results:
+			done[r.i] = true
+			if r.err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", outputs[r.i].name, r.err))
+			}
+		case <-ctx.Done():
+			for i, o := range outputs {
+				if !done[i] {
+					errs = append(errs, fmt.Errorf("%s: %w", o.name, ctx.Err()))
+				}
+			}
+			return multierr.Combine(errs...)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// Close flushes the logger like Sync and then closes its log files,
+// sockets and sinks. Loggers derived with With share these outputs, so
+// none of them may be used afterwards.
+func (l *Logger) Close(ctx context.Context) error {
+	return l.closeReplaced(ctx, &Logger{})
+}
+
+// closeReplaced flushes and closes l after next replaced it, leaving open
+// the sinks next also writes to.
+func (l *Logger) closeReplaced(ctx context.Context, next *Logger) error {
+	errs := []error{l.Sync(ctx)}
+	for _, o := range l.outputs {
+		if o.close == nil || o.sink != nil && next.hasSink(o.sink) {
+			continue
+		}
+		if err := o.close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: close: %w", o.name, err))
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+func (l *Logger) hasSink(sink Sink) bool {
+	if !reflect.TypeOf(sink).Comparable() {
+		return false
+	}
+	for _, o := range l.outputs {
+		if o.sink == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// syncConsole flushes the console cores. Syncing a terminal or pipe
+// fails with EINVAL or ENOTTY, which is not a lost entry.
+func syncConsole(sync func() error) func() error {
+	return func() error {
+		err := sync()
+		if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) {
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// foldingEncoder wraps a console encoder so string fields spanning several
+// lines, stack traces in particular, are printed as indented blocks below
+// the entry instead of with escaped newlines. Fields added through With
+// are encoded ahead of time and stay inline.
+type foldingEncoder struct {
+	zapcore.Encoder
+}
+
+func newFoldingEncoder(enc zapcore.Encoder) zapcore.Encoder {
+	return foldingEncoder{enc}
+}
+
+func (e foldingEncoder) Clone() zapcore.Encoder {
+	return foldingEncoder{e.Encoder.Clone()}
+}
+
+func (e foldingEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	var inline, folded []zapcore.Field
+	for _, f := range fields {
+		if f.Type == zapcore.StringType && strings.Contains(f.String, "\n") {
+			folded = append(folded, f)
+			continue
+		}
+		inline = append(inline, f)
+	}
+	if len(folded) == 0 {
+		return e.Encoder.EncodeEntry(ent, fields)
+	}
+	buf, err := e.Encoder.EncodeEntry(ent, inline)
+	if err != nil {
+		return nil, err
+	}
+	buf.TrimNewline()
+	for _, f := range folded {
+		buf.AppendString("\n    ")
+		buf.AppendString(f.Key)
+		buf.AppendByte(':')
+		for _, line := range strings.Split(strings.TrimRight(f.String, "\n"), "\n") {
+			buf.AppendString("\n        ")
+			buf.AppendString(line)
+		}
+	}
+	buf.AppendByte('\n')
+	return buf, nil
+}
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Duration formats for Config.DurationFormat and its per-output overrides.
+const (
+	DurationFormatSeconds = "seconds" // float seconds, default
+	DurationFormatMillis  = "millis"  // integer milliseconds
+	DurationFormatNanos   = "nanos"   // integer nanoseconds
+	DurationFormatString  = "string"  // e.g. "1.2s"
+)
+
+// Byte size formats for Config.ByteFormat and its per-output overrides,
+// applied to fields built with Bytes.
+const (
+	ByteFormatInt   = "int"   // plain integer, default
+	ByteFormatHuman = "human" // e.g. "34MB", in powers of 1024
+)
+
+// outputFormat is how one output encodes timestamps, durations and sizes,
+// and how it sanitizes strings. With a meter, the entries it encodes are
+// counted under the output's name.
+type outputFormat struct {
+	time, zone, duration, bytes string
+	sanitize                    string
+
+	meter  *usageMeter
+	output string
+}
+
+// override returns f with the non-empty values replaced.
+func (f outputFormat) override(time, zone, duration, bytes string) outputFormat {
+	if time != "" {
+		f.time = time
+	}
+	if zone != "" {
+		f.zone = zone
+	}
+	if duration != "" {
+		f.duration = duration
+	}
+	if bytes != "" {
+		f.bytes = bytes
+	}
+	return f
+}
+
+func (f outputFormat) encoderConfig(encoderConfig zapcore.EncoderConfig) zapcore.EncoderConfig {
+	encoderConfig.EncodeTime = timeEncoder(f.time, f.zone)
+	encoderConfig.EncodeDuration = durationEncoder(f.duration)
+	return encoderConfig
+}
+
+// named returns f for the output called name.
+func (f outputFormat) named(name string) outputFormat {
+	f.output = name
+	return f
+}
+
+// wrap returns enc adjusted for the sanitize mode and byte size format.
+// humanBytesEncoder stays outermost, where Bytes fields look for it.
+func (f outputFormat) wrap(enc zapcore.Encoder) zapcore.Encoder {
+	enc = newSanitizingEncoder(enc, f.sanitize)
+	if f.meter != nil {
+		enc = meteringEncoder{Encoder: enc, meter: f.meter, output: f.output}
+	}
+	if strings.EqualFold(f.bytes, ByteFormatHuman) {
+		enc = humanBytesEncoder{enc}
+	}
+	return enc
+}
+
+func durationEncoder(format string) zapcore.DurationEncoder {
+	switch strings.ToLower(format) {
+	case DurationFormatMillis:
+		return func(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendInt64(d.Milliseconds())
+		}
+	case DurationFormatNanos:
+		return zapcore.NanosDurationEncoder
+	case DurationFormatString:
+		return zapcore.StringDurationEncoder
+	}
+	return zapcore.SecondsDurationEncoder
+}
+
+// Bytes constructs a field for a size in bytes, encoded as an integer or,
+// on outputs configured with ByteFormatHuman, as a string such as "34MB".
+func Bytes(key string, n int64) zap.Field {
+	return zap.Inline(byteSize{key: key, n: n})
+}
+
+type byteSize struct {
+	key string
+	n   int64
+}
+
+func (b byteSize) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if _, ok := enc.(humanBytesEncoder); ok {
+		enc.AddString(b.key, humanBytes(b.n))
+	} else {
+		enc.AddInt64(b.key, b.n)
+	}
+	return nil
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit && n > -unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n)
+	for _, suffix := range []string{"KB", "MB", "GB", "TB", "PB"} {
+		v /= unit
+		if v < unit && v > -unit || suffix == "PB" {
+			return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", v), "0"), ".") + suffix
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// humanBytesEncoder marks an encoder whose Bytes fields are written as
+// human-readable strings. Fields added through With see the marker
+// directly; entry fields are converted before encoding.
+type humanBytesEncoder struct {
+	zapcore.Encoder
+}
+
+func (e humanBytesEncoder) Clone() zapcore.Encoder {
+	return humanBytesEncoder{e.Encoder.Clone()}
+}
+
+func (e humanBytesEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	var converted []zapcore.Field
+	for i, f := range fields {
+		b, ok := f.Interface.(byteSize)
+		if !ok || f.Type != zapcore.InlineMarshalerType {
+			continue
+		}
+		if converted == nil {
+			converted = append([]zapcore.Field(nil), fields...)
+		}
+		converted[i] = zap.String(b.key, humanBytes(b.n))
+	}
+	if converted != nil {
+		fields = converted
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
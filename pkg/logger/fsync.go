@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// FsyncPolicy is when a log file is flushed to stable storage. The zero
+// value leaves it to the operating system, which is the fastest; the
+// policies can be combined.
+type FsyncPolicy struct {
+	// Interval fsyncs the file at most this long after it was written.
+	Interval time.Duration
+
+	// Level fsyncs the file after every entry at or above this level,
+	// e.g. "error".
+	Level string
+}
+
+func (p FsyncPolicy) validate() error {
+	if p.Interval < 0 {
+		return errors.New("negative fsync interval")
+	}
+	if p.Level != "" {
+		if _, err := ParseLevel(p.Level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newFileOutput returns the core writing entries enabled by enab to the
+// log file at path, and the output flushing and closing it.
+func newFileOutput(name, path string, policy FsyncPolicy, settings settings, enc zapcore.Encoder, enab zapcore.LevelEnabler) (zapcore.Core, output) {
+	w := newLogWriter(path, settings)
+	fsync := fsyncFunc(w)
+	if fsync != nil && policy.Interval > 0 {
+		w = newPeriodicFsync(w, fsync, policy.Interval)
+	}
+	oc := newOutputCore(enc, w, enab)
+	var core zapcore.Core = oc
+	if lvl, err := ParseLevel(policy.Level); fsync != nil && policy.Level != "" && err == nil {
+		core = &fsyncCore{outputCore: oc, level: lvl, fsync: fsync}
+	}
+	return core, output{name: name, sync: oc.Sync, fsync: fsync, reopen: reopenFunc(w), close: w.Close}
+}
+
+// fsyncCore fsyncs its file after entries at or above level.
+type fsyncCore struct {
+	*outputCore
+	level zapcore.Level
+	fsync func() error
+}
+
+func (c *fsyncCore) With(fields []zapcore.Field) zapcore.Core {
+	return &fsyncCore{
+		outputCore: c.outputCore.With(fields).(*outputCore),
+		level:      c.level,
+		fsync:      c.fsync,
+	}
+}
+
+func (c *fsyncCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *fsyncCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if err := c.outputCore.Write(ent, fields); err != nil || ent.Level < c.level {
+		return err
+	}
+	return c.fsync()
+}
+
+func (c *fsyncCore) writeBatch(entries []batchEntry) error {
+	if err := c.outputCore.writeBatch(entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if c.Enabled(e.ent.Level) && e.ent.Level >= c.level {
+			return c.fsync()
+		}
+	}
+	return nil
+}
+
+// periodicFsync fsyncs a log file from a background goroutine once every
+// interval in which it was written.
+type periodicFsync struct {
+	logWriter
+	fsync func() error
+	dirty atomic.Bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newPeriodicFsync(w logWriter, fsync func() error, interval time.Duration) *periodicFsync {
+	p := &periodicFsync{
+		logWriter: w,
+		fsync:     fsync,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go p.run(interval)
+	return p
+}
+
+func (p *periodicFsync) Write(b []byte) (int, error) {
+	n, err := p.logWriter.Write(b)
+	p.dirty.Store(true)
+	return n, err
+}
+
+func (p *periodicFsync) reopen() error {
+	if r, ok := p.logWriter.(reopener); ok {
+		return r.reopen()
+	}
+	return nil
+}
+
+func (p *periodicFsync) run(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if p.dirty.Swap(false) {
+				p.fsync()
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Close stops the goroutine, fsyncs what is left and closes the file.
+func (p *periodicFsync) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+		var err error
+		if p.dirty.Swap(false) {
+			err = p.fsync()
+		}
+		p.closeErr = multierr.Combine(err, p.logWriter.Close())
+	})
+	return p.closeErr
+}
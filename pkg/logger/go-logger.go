@@ -1,144 +1,751 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/natefinch/lumberjack"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 type Logger struct {
 	zap *zap.Logger
+
+	// progress is set for loggers built by NewCLILogger, and in
+	// development mode when the console is a terminal.
+	progress *progressWriter
+
+	// outputs are flushed by Sync.
+	outputs []output
+
+	// level is the lowest level written, changed by SetLevel.
+	level zap.AtomicLevel
+
+	// critical writes the entries of loggers returned by Critical.
+	critical *zap.Logger
+
+	debugTokens []string
+	tail        TailRetention
+
+	// sampler and sinkSwitches are changed by SetSampling and EnableSink.
+	sampler      *samplingCore
+	sinkSwitches map[string]*atomic.Bool
+
+	// usage counts what the outputs write.
+	usage *usageMeter
+
+	// anomalies is set with Config.ErrorAnomalies, for OnErrorAnomaly.
+	anomalies *anomalyDetector
+
+	// clock is set by WithClock; nil is the system clock.
+	clock zapcore.Clock
+
+	flagNilErrors   bool
+	stackTraces     string
+	errorfDetection string
+	printf          string
 }
 
 type Config struct {
 	InfoLogPath  string
 	ErrorLogPath string
 	Mode         string
+
+	// MaxEntryBytes is the encoded size above which an entry is reported in
+	// development mode. Defaults to 32KB.
+	MaxEntryBytes int
+
+	// Sinks receive every entry as JSON in addition to the files and console.
+	Sinks []Sink
+
+	// AuditLogPath and AuditSinks receive the AuditLevel entries, and only
+	// those. The audit log is written even with DisableFiles.
+	AuditLogPath string
+	AuditSinks   []Sink
+
+	// InfoLogFsync, ErrorLogFsync and AuditLogFsync set when each log file
+	// is flushed to disk. By default that is left to the operating system.
+	InfoLogFsync  FsyncPolicy
+	ErrorLogFsync FsyncPolicy
+	AuditLogFsync FsyncPolicy
+
+	// DisableFiles turns off the info and error log files, e.g. when a
+	// container runtime collects the console output.
+	DisableFiles bool
+
+	// FileFormat is the format of the info and error log files,
+	// FileFormatJSON by default or FileFormatBinary for smaller files read
+	// back with EntryReader or cmd/logcat.
+	FileFormat string
+
+	// FileSharing lets several processes, such as the workers of a
+	// prefork server, write the same log files without interleaving
+	// entries or rotating over each other: FileSharingAppend, or
+	// FileSharingLock for network file systems. Empty assumes one process
+	// per file. Not available on Windows.
+	FileSharing string
+
+	// FileInstance suffixes the info, error and audit log paths with an
+	// ID of the process, as in app-info-1234.log, so replicas landing on
+	// the same volume write files of their own: FileInstancePID,
+	// FileInstanceHostname, or the ID itself, such as a pod name. The
+	// files of other instances left unmodified for StaleFileAge, with
+	// their backups, are removed at startup; 0 keeps them. An instance
+	// that logs nothing for that long loses its files too.
+	FileInstance string
+	StaleFileAge time.Duration
+
+	// ConsoleJSON prints JSON to the console instead of the human-readable
+	// format.
+	ConsoleJSON bool
+
+	// ConsoleFormat chooses the console format for stdout and stderr
+	// separately: ConsoleFormatAuto prints the human-readable format to
+	// terminals and JSON when the output is piped or redirected to a file,
+	// and ConsoleFormatHuman or ConsoleFormatJSON force one. Empty follows
+	// ConsoleJSON.
+	ConsoleFormat string
+
+	// ConsoleColor colors the levels of the human-readable format:
+	// ConsoleColorAuto, the default, on terminals unless the NO_COLOR,
+	// CLICOLOR_FORCE and CLICOLOR environment variables say otherwise,
+	// ConsoleColorAlways or ConsoleColorNever.
+	ConsoleColor string
+
+	// ConsoleLocale selects the translations registered with
+	// RegisterTranslation for the human-readable console format.
+	ConsoleLocale string
+
+	// MaxLineBytes, when set, splits console lines longer than this into
+	// records of at most this size carrying a shared split_id and their
+	// part number. Set it to DockerMaxLineBytes under Docker's json-file
+	// driver so long entries are not cut apart by the runtime.
+	MaxLineBytes int
+
+	// TimeKey, MessageKey, LevelKey and CallerKey rename the standard
+	// fields, "ts", "msg", "level" and "caller" by default. LevelCase is
+	// "lower" (default) or "upper" for the level labels.
+	TimeKey    string
+	MessageKey string
+	LevelKey   string
+	CallerKey  string
+	LevelCase  string
+
+	// TimeFormat is the timestamp format, one of the TimeFormat constants
+	// or a time.Format layout. ConsoleTimeFormat and SinkTimeFormat
+	// override it for the console and for sinks.
+	TimeFormat        string
+	ConsoleTimeFormat string
+	SinkTimeFormat    string
+
+	// TimeZone is the zone of formatted timestamps, an IANA name such as
+	// "UTC" or "Local" for the machine's zone; Beijing time by default.
+	// ConsoleTimeZone and SinkTimeZone override it like TimeFormat, e.g.
+	// to keep files in UTC and show local time on the console.
+	TimeZone        string
+	ConsoleTimeZone string
+	SinkTimeZone    string
+
+	// DurationFormat and ByteFormat select how durations and Bytes fields
+	// are encoded, one of the DurationFormat and ByteFormat constants, with
+	// per-output overrides like TimeFormat.
+	DurationFormat        string
+	ConsoleDurationFormat string
+	SinkDurationFormat    string
+	ByteFormat            string
+	ConsoleByteFormat     string
+	SinkByteFormat        string
+
+	// Sanitize is SanitizeStrip or SanitizeEscape to remove or escape
+	// control characters and invalid UTF-8 in messages and string fields
+	// on every output. Empty leaves them to the encoders.
+	Sanitize string
+
+	// OrderedOutputs writes each entry to all the files, console and sinks
+	// before the next, so they receive entries in the same order and can
+	// be compared line by line. Otherwise concurrent entries may reach
+	// different outputs in different orders. The console is unbuffered,
+	// so stdout and stderr are written in that order too. It serializes
+	// encoding, and timestamps, taken before, may still be out of order.
+	OrderedOutputs bool
+
+	// EntryIDs adds a ULID and a sequence number to every entry, as
+	// EntryIDKey and SequenceKey, so entries can be put back in order and
+	// losses found downstream even when timestamps collide. With
+	// OrderedOutputs the numbers follow the order of the outputs.
+	EntryIDs bool
+
+	// DebugLogTokens are the secrets that activate a debug session for a
+	// request through DebugLogHeader.
+	DebugLogTokens []string
+
+	// TailRetention keeps the debug and info entries of requests only
+	// when they fail or are slow.
+	TailRetention TailRetention
+
+	// Level is the lowest level logged, debug by default.
+	// LevelOverrides set other levels for code in some packages, as
+	// "import/path=level" rules applying to the package and those below it,
+	// e.g. "github.com/acme/app/internal/sync=debug". The longest matching
+	// path wins.
+	Level          string
+	LevelOverrides []string
+
+	// VerboseRollout lowers Level on a sample of hosts.
+	VerboseRollout VerboseRollout
+
+	// Budget caps the bytes logged per second, see VolumeBudget.
+	Budget VolumeBudget
+
+	// ErrorAnomalies logs an entry when the errors of a logger spike.
+	ErrorAnomalies ErrorAnomalies
+
+	// StderrLevel is the lowest level printed to stderr instead of stdout,
+	// "warn" by default, in any form ParseLevel accepts. "off" prints
+	// everything to stdout.
+	StderrLevel string
+
+	// SchemaVersion is stamped on every entry as schema_version when set.
+	// SchemaCompatVersion also writes the fields renamed since that older
+	// version under their old names, see RegisterSchemaMigration, while
+	// downstream parsers move to the new ones.
+	SchemaVersion       int
+	SchemaCompatVersion int
+
+	// Fields are added to every entry, e.g. ServiceFields. They are encoded
+	// once when the logger is built instead of on every entry.
+	Fields []zap.Field
+
+	// StackTraces is when error entries carry a stack trace, one of the
+	// StackTraces constants; StackTracesAlways by default.
+	StackTraces string
+
+	// ErrorfDetection is how Errorf finds the error it reports among its
+	// arguments, one of the ErrorfDetection constants;
+	// ErrorfDetectFirst by default.
+	ErrorfDetection string
+
+	// Printf is the policy for the printf-style methods such as Infof, one
+	// of the Printf constants, to help migrate to structured fields.
+	// PrintfAllow by default. cmd/logvet finds the calls statically.
+	Printf string
+
+	// FlagNilErrors marks entries logged by Error with a nil error with
+	// nil_error. Either way they are logged like ErrorNoErr, without error
+	// fields.
+	FlagNilErrors bool
+
+	// BuildInfo adds BuildFields to every entry and logs one entry with
+	// the module path, Go version and commit time when the logger is built.
+	BuildInfo bool
+
+	// FieldProviders add fields with changing values to every entry, see
+	// FieldProvider.
+	FieldProviders []FieldProvider
+
+	// ResourceDetectors find fields describing where the process runs, such
+	// as the Kubernetes pod or cloud zone, which are added to Fields. Nil
+	// selects DefaultResourceDetectors; DisableResourceDetection turns
+	// detection off.
+	ResourceDetectors        []ResourceDetector
+	DisableResourceDetection bool
+}
+
+const (
+	ModeDevelopment = "development"
+	ModeProduction  = "production"
+)
+
+// applyKeys sets the configured field names and level casing on
+// encoderConfig.
+func (c *Config) applyKeys(encoderConfig *zapcore.EncoderConfig) {
+	if c.TimeKey != "" {
+		encoderConfig.TimeKey = c.TimeKey
+	}
+	if c.MessageKey != "" {
+		encoderConfig.MessageKey = c.MessageKey
+	}
+	if c.LevelKey != "" {
+		encoderConfig.LevelKey = c.LevelKey
+	}
+	if c.CallerKey != "" {
+		encoderConfig.CallerKey = c.CallerKey
+	}
+	if strings.EqualFold(c.LevelCase, "upper") {
+		encoderConfig.EncodeLevel = levelEncoder(zapcore.CapitalLevelEncoder, true)
+	} else {
+		encoderConfig.EncodeLevel = levelEncoder(encoderConfig.EncodeLevel, false)
+	}
+}
+
+// Validate reports configuration values NewLogger would otherwise ignore.
+func (c *Config) Validate() error {
+	if c.Level != "" {
+		if _, err := ParseLevel(c.Level); err != nil {
+			return fmt.Errorf("Level: %w", err)
+		}
+	}
+	if _, err := parseLevelOverrides(c.LevelOverrides); err != nil {
+		return fmt.Errorf("LevelOverrides: %w", err)
+	}
+	if p := c.VerboseRollout.Percent; p < 0 || p > 100 {
+		return fmt.Errorf("VerboseRollout: percent %v not between 0 and 100", p)
+	}
+	if c.VerboseRollout.Level != "" {
+		if _, err := ParseLevel(c.VerboseRollout.Level); err != nil {
+			return fmt.Errorf("VerboseRollout: %w", err)
+		}
+	}
+	if c.SchemaVersion < 0 || c.SchemaCompatVersion < 0 {
+		return fmt.Errorf("SchemaVersion: negative version")
+	}
+	if c.SchemaCompatVersion > c.SchemaVersion {
+		return fmt.Errorf("SchemaCompatVersion: %d is after SchemaVersion %d", c.SchemaCompatVersion, c.SchemaVersion)
+	}
+	switch strings.ToLower(c.FileFormat) {
+	case "", FileFormatJSON, FileFormatBinary:
+	default:
+		return fmt.Errorf("FileFormat: unknown format %q", c.FileFormat)
+	}
+	switch strings.ToLower(c.ConsoleFormat) {
+	case "", ConsoleFormatAuto, ConsoleFormatHuman, ConsoleFormatJSON:
+	default:
+		return fmt.Errorf("ConsoleFormat: unknown format %q", c.ConsoleFormat)
+	}
+	switch strings.ToLower(c.ConsoleColor) {
+	case "", ConsoleColorAuto, ConsoleColorAlways, ConsoleColorNever:
+	default:
+		return fmt.Errorf("ConsoleColor: unknown mode %q", c.ConsoleColor)
+	}
+	if c.StaleFileAge < 0 {
+		return fmt.Errorf("StaleFileAge: negative age")
+	}
+	switch strings.ToLower(c.FileSharing) {
+	case "":
+	case FileSharingAppend, FileSharingLock:
+		if !fileSharingSupported {
+			return fmt.Errorf("FileSharing: %w", errNoFileLocks)
+		}
+	default:
+		return fmt.Errorf("FileSharing: unknown mode %q", c.FileSharing)
+	}
+	if err := c.TailRetention.validate(); err != nil {
+		return fmt.Errorf("TailRetention: %w", err)
+	}
+	if err := c.Budget.validate(); err != nil {
+		return fmt.Errorf("Budget: %w", err)
+	}
+	if err := c.ErrorAnomalies.validate(); err != nil {
+		return fmt.Errorf("ErrorAnomalies: %w", err)
+	}
+	for _, fsync := range []struct {
+		name   string
+		policy FsyncPolicy
+	}{
+		{"InfoLogFsync", c.InfoLogFsync},
+		{"ErrorLogFsync", c.ErrorLogFsync},
+		{"AuditLogFsync", c.AuditLogFsync},
+	} {
+		if err := fsync.policy.validate(); err != nil {
+			return fmt.Errorf("%s: %w", fsync.name, err)
+		}
+	}
+	for _, zone := range []struct{ name, value string }{
+		{"TimeZone", c.TimeZone},
+		{"ConsoleTimeZone", c.ConsoleTimeZone},
+		{"SinkTimeZone", c.SinkTimeZone},
+	} {
+		if _, err := loadTimeZone(zone.value); err != nil {
+			return fmt.Errorf("%s: %w", zone.name, err)
+		}
+	}
+	if c.StderrLevel != "" && !strings.EqualFold(c.StderrLevel, "off") {
+		if _, err := ParseLevel(c.StderrLevel); err != nil {
+			return fmt.Errorf("StderrLevel: %w", err)
+		}
+	}
+	return nil
+}
+
+// fileEncoder returns the encoder of the info and error log files.
+func (c *Config) fileEncoder(encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+	enc := newJSONEncoder(encoderConfig)
+	if strings.EqualFold(c.FileFormat, FileFormatBinary) {
+		return binaryEncoder{enc}
+	}
+	return enc
+}
+
+// level is the lowest level written: Level, debug when it is empty or
+// invalid, or lower on hosts in the VerboseRollout.
+func (c *Config) level() zapcore.Level {
+	base, err := ParseLevel(c.Level)
+	if c.Level == "" || err != nil {
+		base = zapcore.DebugLevel
+	}
+	return c.VerboseRollout.level(base)
+}
+
+func (c *Config) isDevelopment() bool {
+	switch strings.ToLower(c.Mode) {
+	case "dev", ModeDevelopment:
+		return true
+	}
+	return false
 }
 
 var (
-	logInstance *Logger
+	logInstance atomic.Pointer[Logger]
 	once        sync.Once
 )
 
 func Init(config *Config) {
 	once.Do(func() {
-		logInstance = NewLogger(config)
+		l := NewLogger(config)
+		logInstance.Store(l)
+		l.logConfig(config)
 	})
 }
 
-func zapErrorWithStack(err error) (msg zap.Field, stack zap.Field) {
-	// Get the stack trace
-	buf := make([]byte, 1024)
-	n := runtime.Stack(buf, false) // false for all goroutines, true for current goroutine
-	return zap.String("error", err.Error()), zap.String("stacktrace", string(buf[:n]))
+// reinitFlushTimeout bounds flushing the replaced logger in Reinit.
+const reinitFlushTimeout = 5 * time.Second
+
+// Reinit replaces the global logger with one built from a copy of config,
+// then flushes the old logger and closes the outputs the new one does not
+// reuse. Loggers derived from the old one with With keep writing to its
+// closed outputs, so they should be derived again.
+func Reinit(config *Config) error {
+	cfg := *config
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	once.Do(func() {})
+	l := NewLogger(&cfg)
+	old := logInstance.Swap(l)
+	l.logConfig(&cfg)
+	if old == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reinitFlushTimeout)
+	defer cancel()
+	return old.closeReplaced(ctx, l)
+}
+
+// SetLevel changes the lowest level the global logger writes.
+func SetLevel(level string) error {
+	return logInstance.Load().SetLevel(level)
+}
+
+// Stack buffers start at minStackBytes and are doubled while the trace is
+// truncated, up to maxStackBytes. Grown buffers go back to the pool, so
+// services with deep stacks stop paying for the retries.
+const (
+	minStackBytes = 1024
+	maxStackBytes = 64 * 1024
+)
+
+var stackBufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, minStackBytes)
+		return &buf
+	},
+}
+
+func captureStack() string {
+	bp := stackBufPool.Get().(*[]byte)
+	buf := *bp
+	for {
+		n := runtime.Stack(buf, false) // false for the current goroutine only
+		if n < len(buf) || len(buf) >= maxStackBytes {
+			stack := string(buf[:n])
+			*bp = buf
+			stackBufPool.Put(bp)
+			return stack
+		}
+		buf = make([]byte, 2*len(buf))
+	}
 }
 
 func Info(msg string, tags ...zap.Field) {
-	logInstance.Info(msg, tags...)
+	logInstance.Load().Info(msg, tags...)
 }
 
 func Error(msg string, err error, tags ...zap.Field) {
-	logInstance.Error(msg, err, tags...)
+	logInstance.Load().Error(msg, err, tags...)
+}
+
+func ErrorNoErr(msg string, tags ...zap.Field) {
+	logInstance.Load().ErrorNoErr(msg, tags...)
 }
 
 func Debug(msg string, tags ...zap.Field) {
-	logInstance.Debug(msg, tags...)
+	logInstance.Load().Debug(msg, tags...)
+}
+
+func Trace(msg string, tags ...zap.Field) {
+	logInstance.Load().Trace(msg, tags...)
 }
 
 func Warn(msg string, tags ...zap.Field) {
-	logInstance.Warn(msg, tags...)
+	logInstance.Load().Warn(msg, tags...)
 }
 
 func Fatal(msg string, tags ...zap.Field) {
-	logInstance.Fatal(msg, tags...)
+	logInstance.Load().Fatal(msg, tags...)
 }
 
 // Formatted logging for Info level
 func Infof(msg string, args ...interface{}) {
-	logInstance.Info(fmt.Sprintf(msg, args...))
+	logInstance.Load().Infof(msg, args...)
 }
 
 // Formatted logging for Error level
 func Errorf(format string, args ...interface{}) {
-	logInstance.Errorf(format, args...)
+	logInstance.Load().Errorf(format, args...)
 }
 
 // Formatted logging for Debug level
 func Debugf(msg string, args ...interface{}) {
-	logInstance.Debug(fmt.Sprintf(msg, args...))
+	logInstance.Load().Debugf(msg, args...)
 }
 
 // Formatted logging for Warn level
 func Warnf(msg string, args ...interface{}) {
-	logInstance.Warn(fmt.Sprintf(msg, args...))
+	logInstance.Load().Warnf(msg, args...)
 }
 
 // Formatted logging for Fatal level
 func Fatalf(msg string, args ...interface{}) {
-	logInstance.Fatal(fmt.Sprintf(msg, args...))
+	logInstance.Load().Fatalf(msg, args...)
 }
 
 func beijingTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-	beijingLocation, _ := time.LoadLocation("Asia/Shanghai")
-	enc.AppendString(t.In(beijingLocation).Format(time.RFC3339Nano))
+	appendTimeLayout(enc, t.In(beijingLocation), time.RFC3339Nano)
 }
 
 func NewLogger(config *Config) *Logger {
-	// Create a lumberjack logger (from "gopkg.in/natefinch/lumberjack.v2") for file rotation.
-	infoLogWriter := &lumberjack.Logger{
-		Filename:   config.InfoLogPath,
-		MaxSize:    500, // megabytes after which new file is created
-		MaxBackups: 3,   // number of backups
-		MaxAge:     28,  //days
-	}
+	return newLogger(config, defaultSettings())
+}
 
-	errorLogWriter := &lumberjack.Logger{
-		Filename:   config.ErrorLogPath,
-		MaxSize:    500,
-		MaxBackups: 3,
-		MaxAge:     28,
+func newLogger(config *Config, settings settings) *Logger {
+	settings.fileSharing = config.FileSharing
+	if id := config.instanceID(); id != "" {
+		if !config.DisableFiles {
+			removeStaleInstanceFiles(config.InfoLogPath, id, config.StaleFileAge)
+			removeStaleInstanceFiles(config.ErrorLogPath, id, config.StaleFileAge)
+		}
+		removeStaleInstanceFiles(config.AuditLogPath, id, config.StaleFileAge)
+		config = config.withInstancePaths()
 	}
 	encoderConfig := zap.NewProductionEncoderConfig()
-	encoderConfig.EncodeTime = beijingTimeEncoder
-
-	// Create a zapcore.Core for each log level you need
-	infoCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		zapcore.AddSync(infoLogWriter),
-		zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-			return lvl >= zapcore.DebugLevel && lvl <= zapcore.WarnLevel
-		}),
+	config.applyKeys(&encoderConfig)
+	fileFormat := outputFormat{
+		time:     config.TimeFormat,
+		zone:     config.TimeZone,
+		duration: config.DurationFormat,
+		bytes:    config.ByteFormat,
+		sanitize: config.Sanitize,
+		meter:    newUsageMeter(),
+	}
+	consoleFormat := fileFormat.override(config.ConsoleTimeFormat, config.ConsoleTimeZone, config.ConsoleDurationFormat, config.ConsoleByteFormat)
+	sinkFormat := fileFormat.override(config.SinkTimeFormat, config.SinkTimeZone, config.SinkDurationFormat, config.SinkByteFormat)
+	fileConfig := fileFormat.encoderConfig(encoderConfig)
+
+	var (
+		cores    []zapcore.Core
+		outputs  []output
+		progress *progressWriter
 	)
+	if !config.DisableFiles {
+		// Create a zapcore.Core for each log level you need
+		infoCore, infoOutput := newFileOutput("info log", config.InfoLogPath, config.InfoLogFsync, settings,
+			fileFormat.named("info log").wrap(config.fileEncoder(fileConfig)),
+			zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+				return lvl <= zapcore.WarnLevel
+			}),
+		)
+		errorCore, errorOutput := newFileOutput("error log", config.ErrorLogPath, config.ErrorLogFsync, settings,
+			fileFormat.named("error log").wrap(config.fileEncoder(fileConfig)),
+			zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+				return lvl >= zapcore.ErrorLevel
+			}),
+		)
+		cores = append(cores, infoCore, errorCore)
+		outputs = append(outputs, infoOutput, errorOutput)
+	}
 
-	errorCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		zapcore.AddSync(errorLogWriter),
-		zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-			return lvl >= zapcore.ErrorLevel
-		}),
-	)
+	// Add the stdout/stderr cores
+	if settings.console {
+		consoleCores, consoleProgress := newConsoleCores(consoleFormat.encoderConfig(encoderConfig), consoleFormat.named("console"), config, settings.unbufferedConsole || config.OrderedOutputs)
+		console := consoleCore{newTee(consoleCores...)}
+		progress = consoleProgress
+		cores = append(cores, console)
+		outputs = append(outputs, output{name: "console", sync: syncConsole(console.Sync)})
+	}
+	sinkSwitches := make(map[string]*atomic.Bool, len(config.Sinks))
+	for _, sink := range config.Sinks {
+		on := new(atomic.Bool)
+		on.Store(true)
+		sinkSwitches[sink.Name()] = on
+		cores = append(cores, switchCore{Core: newSinkCore(sink, sinkFormat.encoderConfig(encoderConfig), sinkFormat.named("sink "+sink.Name())), on: on})
+		outputs = append(outputs, output{name: "sink " + sink.Name(), sync: sink.Sync, close: sink.Close, sink: sink})
+	}
+	core := newTee(cores...)
+	if config.EntryIDs {
+		core = entryIDCore{Core: core, ids: new(entryIDs)}
+	}
+	if config.OrderedOutputs {
+		core = orderedCore{Core: core, mu: new(sync.Mutex)}
+	}
 
-	// Create a zapcore.Core for stdout
-	consoleCore := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.AddSync(zapcore.Lock(os.Stdout)),
-		zapcore.DebugLevel, // or whichever minimum level you want to be printed to console
-	)
+	// Audit entries skip the filters below; without audit outputs they
+	// go to the regular ones
+	audit := core
+	if auditCores, auditOutputs := newAuditOutputs(config, settings, fileFormat, sinkFormat, encoderConfig); len(auditCores) > 0 {
+		audit = newTee(auditCores...)
+		outputs = append(outputs, auditOutputs...)
+	}
+
+	// Critical entries skip sampling and are flushed to disk and to remote
+	// sinks before the call returns
+	durable := func() error { return syncDurable(outputs) }
+
+	// Message templates, field providers and caller annotation apply to
+	// every pipeline
+	decorate := func(c zapcore.Core) zapcore.Core {
+		c = templateCore{c}
+		if config.SchemaCompatVersion > 0 || config.SchemaVersion > 0 {
+			if aliases := schemaAliases(config.SchemaCompatVersion, config.SchemaVersion); len(aliases) > 0 {
+				c = schemaCompatCore{Core: c, aliases: aliases}
+			}
+		}
+		if len(config.FieldProviders) > 0 {
+			c = newProviderCore(c, config.FieldProviders)
+		}
+		if settings.caller {
+			c = callerCore{c}
+		}
+		return c
+	}
+	critical := decorate(durableCore{Core: core, sync: durable})
 
-	// Combine them together
-	core := zapcore.NewTee(infoCore, errorCore, consoleCore)
+	// In development mode, check every entry for size and schema problems
+	if config.isDevelopment() {
+		core = newTee(core, newValidationCore(core, fileConfig, config.MaxEntryBytes))
+	}
+
+	if config.Budget.enabled() {
+		core = newBudgetCore(core, config.Budget, fileConfig)
+	}
+	var anomalies *anomalyDetector
+	if config.ErrorAnomalies.Enabled {
+		anomalies = newAnomalyDetector(config.ErrorAnomalies)
+		core = anomalyCore{Core: core, detector: anomalies}
+	}
+	// Tapes decorate entries as they record them and replay them here
+	unsampled := core
+	core = decorate(core)
+	sampler := newSamplingCore(core, time.Second, settings.sampleFirst, settings.sampleThereafter, settings.samplingExemptions, settings.samplingBoost)
+	core = sampler
+
+	// Apply the base level and per-package overrides
+	level := zap.NewAtomicLevelAt(config.level())
+	rules, _ := parseLevelOverrides(config.LevelOverrides)
+	core = auditCore{Core: newTapeCore(newLevelCore(core, level, rules), unsampled, decorate), audit: decorate(audit)}
+	critical = auditCore{
+		Core:  newLevelCore(critical, level, rules),
+		audit: decorate(durableCore{Core: audit, sync: durable}),
+	}
 
 	// Create a zap logger with the combined core
-	zlog := zap.New(core)
+	// zap adds stack traces to levels above fatal unless told otherwise
+	zopts := []zap.Option{zap.AddStacktrace(zap.LevelEnablerFunc(func(zapcore.Level) bool { return false }))}
+	if settings.clock != nil {
+		zopts = append(zopts, zap.WithClock(settings.clock))
+	}
+	zlog := zap.New(core, zopts...)
+	zcritical := zap.New(critical, zopts...)
+	fields := config.Fields
+	if !config.DisableResourceDetection {
+		detectors := config.ResourceDetectors
+		if detectors == nil {
+			detectors = DefaultResourceDetectors()
+		}
+		fields = append(fields[:len(fields):len(fields)], DetectResource(context.Background(), detectors...)...)
+	}
+	if config.BuildInfo {
+		fields = append(fields[:len(fields):len(fields)], BuildFields()...)
+	}
+	if config.SchemaVersion > 0 {
+		fields = append(fields[:len(fields):len(fields)], zap.Int(SchemaVersionKey, config.SchemaVersion))
+	}
+	if len(fields) > 0 {
+		zlog = zlog.With(fields...)
+		zcritical = zcritical.With(fields...)
+	}
+
+	l := &Logger{
+		zap:             zlog,
+		critical:        zcritical,
+		outputs:         outputs,
+		level:           level,
+		flagNilErrors:   config.FlagNilErrors,
+		stackTraces:     config.StackTraces,
+		errorfDetection: config.ErrorfDetection,
+		printf:          config.Printf,
+		debugTokens:     config.DebugLogTokens,
+		tail:            config.TailRetention,
+		sampler:         sampler,
+		sinkSwitches:    sinkSwitches,
+		usage:           fileFormat.meter,
+		progress:        progress,
+		anomalies:       anomalies,
+		clock:           settings.clock,
+	}
+	if config.BuildInfo {
+		l.logBuildBanner()
+	}
+	return l
+}
 
-	return &Logger{zap: zlog}
+// ServiceFields returns the service, version and hostname fields that
+// identify the running process, for use as Config.Fields.
+func ServiceFields(service, version string) []zap.Field {
+	fields := []zap.Field{zap.String("service", service), zap.String("version", version)}
+	if host, err := os.Hostname(); err == nil {
+		fields = append(fields, zap.String("hostname", host))
+	}
+	return fields
+}
+
+// With returns a child logger that adds fields to every entry. Like
+// Config.Fields they are encoded once, when the child is created, so
+// long-lived children are cheaper than passing the same fields per call.
+func (l *Logger) With(fields ...zap.Field) *Logger {
+	child := *l
+	child.zap = l.zap.With(fields...)
+	if l.critical != nil {
+		child.critical = l.critical.With(fields...)
+	}
+	return &child
+}
+
+// Named returns a child logger whose entries carry name as their logger
+// field, appended to the name of l with a dot as zap does. Sampling and
+// ErrorAnomalies keep the entries of each name apart.
+func (l *Logger) Named(name string) *Logger {
+	child := *l
+	child.zap = l.zap.Named(name)
+	if l.critical != nil {
+		child.critical = l.critical.Named(name)
+	}
+	return &child
 }
 
 func (l *Logger) Info(msg string, tags ...zap.Field) {
@@ -146,15 +753,71 @@ func (l *Logger) Info(msg string, tags ...zap.Field) {
 }
 
 func (l *Logger) Error(msg string, err error, tags ...zap.Field) {
-	errMsg, errStack := zapErrorWithStack(err)
-	allFields := append(tags, zap.String("error", err.Error()), errMsg, errStack)
-	l.zap.Error(msg, allFields...)
+	if err == nil {
+		l.ErrorNoErr(msg, l.nilErrorFields(tags)...)
+		return
+	}
+	l.writeError(msg, err, tags)
+}
+
+// ErrorNoErr logs msg at error level for failures without an error value.
+func (l *Logger) ErrorNoErr(msg string, fields ...zap.Field) {
+	l.zap.Error(msg, l.stackFields(zapcore.ErrorLevel, fields)...)
+}
+
+// nilErrorFields adds the nil_error flag to fields when
+// Config.FlagNilErrors is set, so calls passing a nil error to Error can
+// be found.
+func (l *Logger) nilErrorFields(fields []zap.Field) []zap.Field {
+	if !l.flagNilErrors {
+		return fields
+	}
+	return append(fields[:len(fields):len(fields)], zap.Bool("nil_error", true))
+}
+
+// writeError logs msg at the level registered for err's class, or at Error
+// level when err is not classified.
+func (l *Logger) writeError(msg string, err error, fields []zap.Field) {
+	lvl, fields := l.errorEntry(err, fields)
+	if ce := l.zap.Check(lvl, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// errorEntry returns the level of an entry about err and fields with the
+// error, its chain, its category and, as Config.StackTraces allows, the
+// stack added.
+func (l *Logger) errorEntry(err error, fields []zap.Field) (zapcore.Level, []zap.Field) {
+	fields = append(fields[:len(fields):len(fields)], zap.String("error", err.Error()))
+	if chain, ok := zapErrorChain(err); ok {
+		fields = append(fields, chain)
+	}
+	lvl, fields := classifyError(err, fields)
+	return lvl, l.stackFields(lvl, fields)
+}
+
+// classifyError returns the level err is logged at and fields with its
+// category added.
+func classifyError(err error, fields []zap.Field) (zapcore.Level, []zap.Field) {
+	if class, ok := ClassifyError(err); ok {
+		return class.Level, append(fields, zap.String("error_category", class.Category))
+	}
+	return zapcore.ErrorLevel, fields
 }
 
 func (l *Logger) Debug(msg string, tags ...zap.Field) {
 	l.zap.Debug(msg, tags...)
 }
 
+func (l *Logger) Trace(msg string, tags ...zap.Field) {
+	l.zap.Log(TraceLevel, msg, tags...)
+}
+
+// Log logs at any level, including TraceLevel and registered custom levels.
+func (l *Logger) Log(lvl zapcore.Level, msg string, tags ...zap.Field) {
+	l.zap.Log(lvl, msg, tags...)
+}
+
 func (l *Logger) Warn(msg string, tags ...zap.Field) {
 	l.zap.Warn(msg, tags...)
 }
@@ -166,45 +829,66 @@ func (l *Logger) Fatal(msg string, tags ...zap.Field) {
 // Formatted logger methods
 
 func (l *Logger) Infof(msg string, args ...interface{}) {
-	l.zap.Info(fmt.Sprintf(msg, args...))
+	if fields, ok := l.printfFields(msg); ok {
+		l.zap.Info(fmt.Sprintf(msg, args...), fields...)
+	}
 }
 
+// Errorf formats an error entry. The error it reports is found in args
+// according to Config.ErrorfDetection; with one, the entry is classified
+// and carries the same fields as Error, and without one it is logged like
+// ErrorNoErr.
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	var stackErr error
-	for _, arg := range args {
-		if e, ok := arg.(error); ok {
-			stackErr = e
-			break
-		}
+	// fmt.Errorf formats like Sprintf and also accepts %w.
+	fields, ok := l.printfFields(format)
+	if !ok {
+		return
 	}
-	if stackErr != nil {
-		errMsg, errStack := zapErrorWithStack(stackErr)
-		l.zap.Error(msg, errMsg, errStack)
+	formatted := fmt.Errorf(format, args...)
+	msg := formatted.Error()
+	if err := l.errorfError(formatted, args); err != nil {
+		l.writeError(msg, err, fields)
 	} else {
-		l.zap.Error(msg)
+		l.ErrorNoErr(msg, fields...)
 	}
 }
 
 func (l *Logger) Debugf(msg string, args ...interface{}) {
-	l.zap.Debug(fmt.Sprintf(msg, args...))
+	if fields, ok := l.printfFields(msg); ok {
+		l.zap.Debug(fmt.Sprintf(msg, args...), fields...)
+	}
 }
 
 func (l *Logger) Warnf(msg string, args ...interface{}) {
-	l.zap.Warn(fmt.Sprintf(msg, args...))
+	if fields, ok := l.printfFields(msg); ok {
+		l.zap.Warn(fmt.Sprintf(msg, args...), fields...)
+	}
 }
 
+// Fatalf is never rejected by Config.Printf, since callers rely on it
+// ending the process.
 func (l *Logger) Fatalf(msg string, args ...interface{}) {
-	l.zap.Fatal(fmt.Sprintf(msg, args...))
+	var fields []zap.Field
+	if l.printf != "" && !strings.EqualFold(l.printf, PrintfAllow) {
+		fields = append(fields, zap.Bool("printf", true))
+	}
+	l.zap.Fatal(fmt.Sprintf(msg, args...), fields...)
 }
 
 func GetInstance() *Logger {
-	return logInstance
+	return logInstance.Load()
 }
 
 // ... Implement similar functions for other log levels like Debug, Warn, Fatal ...
 
-// Cleanup should be called to ensure all log messages are flushed
-func Cleanup() {
-	logInstance.zap.Sync()
+// Cleanup flushes the files, console and sinks of the global logger,
+// waiting at most until ctx is done, and reports the outputs that failed.
+func Cleanup(ctx context.Context) error {
+	return logInstance.Load().Sync(ctx)
+}
+
+// Shutdown flushes the global logger like Cleanup and then closes its
+// files, sockets and sinks. It must not be used afterwards.
+func Shutdown(ctx context.Context) error {
+	return logInstance.Load().Close(ctx)
 }
@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// GoldenUpdateEnv names the environment variable that, set to 1, makes
+// golden logs rewrite their files instead of comparing with them:
+//
+//	UPDATE_GOLDEN_LOGS=1 go test ./...
+const GoldenUpdateEnv = "UPDATE_GOLDEN_LOGS"
+
+// GoldenT is the part of testing.TB that NewGolden uses.
+type GoldenT interface {
+	TestingT
+	Name() string
+	Errorf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// GoldenOption configures a golden log.
+type GoldenOption func(*goldenLog)
+
+// GoldenFile sets the golden file, by default testdata/<test name>.log.
+func GoldenFile(path string) GoldenOption {
+	return func(g *goldenLog) {
+		g.path = path
+	}
+}
+
+// GoldenMask replaces the values of the fields keys with "<key>", for
+// values that change from run to run but do not look like IDs.
+func GoldenMask(keys ...string) GoldenOption {
+	return func(g *goldenLog) {
+		for _, k := range keys {
+			g.masked[k] = true
+		}
+	}
+}
+
+// NewGolden returns a logger recording the entries of a test scenario,
+// which are compared with a golden file when the test ends; any
+// difference fails the test with a diff. Set GoldenUpdateEnv to record the
+// file, and check it in so CI catches unintended changes to what the code
+// logs:
+//
+//	func TestCheckout(t *testing.T) {
+//		log := logger.NewGolden(t)
+//		checkout(log, cart)
+//	}
+//
+// Entries are JSON lines without timestamps, callers or stack traces, and
+// with values that differ between runs normalized: durations and times
+// become "<duration>" and "<time>", and IDs such as UUIDs and long hex
+// strings become "<id1>", "<id2>" and so on in order of appearance, so
+// entries sharing an ID still do in the file.
+func NewGolden(t GoldenT, opts ...GoldenOption) *Logger {
+	g := &goldenLog{
+		path:   filepath.Join("testdata", goldenFileName(t.Name())+".log"),
+		masked: make(map[string]bool),
+		ids:    make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = zapcore.OmitKey
+	encoderConfig.CallerKey = zapcore.OmitKey
+	encoderConfig.StacktraceKey = zapcore.OmitKey
+	encoderConfig.EncodeLevel = levelEncoder(encoderConfig.EncodeLevel, false)
+	level := zap.NewAtomicLevelAt(TraceLevel)
+	// Normalize before templates are rendered, so messages show the
+	// placeholders too.
+	core := goldenCore{Core: templateCore{zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), g, level)}, log: g}
+	t.Cleanup(func() {
+		t.Helper()
+		if err := g.check(); err != nil {
+			t.Errorf("golden log %s: %v", g.path, err)
+		}
+	})
+	return &Logger{zap: zap.New(core), level: level}
+}
+
+// goldenFileName turns a test name, with subtests, into a file name.
+func goldenFileName(test string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, test)
+}
+
+// goldenLog collects the normalized entries of a test.
+type goldenLog struct {
+	path   string
+	masked map[string]bool
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+	ids map[string]string // value -> placeholder
+}
+
+func (g *goldenLog) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.Write(p)
+}
+
+func (g *goldenLog) Sync() error {
+	return nil
+}
+
+// goldenID matches values taken for IDs: UUIDs and hex strings of 16
+// characters or more, as trace, span, request and run IDs are.
+var goldenID = regexp.MustCompile(`^(?i:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|[0-9a-f]{16,})$`)
+
+// id returns the placeholder of an ID value.
+func (g *goldenLog) id(v string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p, ok := g.ids[v]
+	if !ok {
+		p = fmt.Sprintf("<id%d>", len(g.ids)+1)
+		g.ids[v] = p
+	}
+	return p
+}
+
+// normalize returns fields with the values that change between runs
+// replaced.
+func (g *goldenLog) normalize(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch {
+		case f.Key == "stacktrace":
+			f = zap.Skip()
+		case g.masked[f.Key]:
+			f = zap.String(f.Key, "<"+f.Key+">")
+		case f.Type == zapcore.DurationType:
+			f = zap.String(f.Key, "<duration>")
+		case f.Type == zapcore.TimeType || f.Type == zapcore.TimeFullType:
+			f = zap.String(f.Key, "<time>")
+		case f.Type == zapcore.StringType && goldenID.MatchString(f.String):
+			f = zap.String(f.Key, g.id(f.String))
+		}
+		out[i] = f
+	}
+	return out
+}
+
+// check compares the entries with the golden file, or writes them to it
+// when GoldenUpdateEnv is set.
+func (g *goldenLog) check() error {
+	g.mu.Lock()
+	got := append([]byte(nil), g.buf.Bytes()...)
+	g.mu.Unlock()
+	if os.Getenv(GoldenUpdateEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(g.path), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(g.path, got, 0644)
+	}
+	want, err := os.ReadFile(g.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no golden file; record it with %s=1", GoldenUpdateEnv)
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(got, want) {
+		return nil
+	}
+	return fmt.Errorf("entries differ (-golden +got); update with %s=1 if intended:\n%s", GoldenUpdateEnv, diffLines(splitLines(want), splitLines(got)))
+}
+
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+// diffLines returns the lines to remove from a and add to get b, from their
+// longest common subsequence, prefixed with "-" and "+".
+func diffLines(a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			fmt.Fprintf(&out, "-%s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+%s\n", b[j])
+			j++
+		}
+	}
+	return out.String()
+}
+
+// goldenCore normalizes the fields of entries and of With.
+type goldenCore struct {
+	zapcore.Core
+	log *goldenLog
+}
+
+func (c goldenCore) With(fields []zapcore.Field) zapcore.Core {
+	return goldenCore{c.Core.With(c.log.normalize(fields)), c.log}
+}
+
+func (c goldenCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c goldenCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.log.normalize(fields))
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// SinkHealth is the state of a sink.
+type SinkHealth struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"` // see Logger.EnableSink
+
+	// Connected is false while deliveries fail, until one succeeds again.
+	Connected bool `json:"connected"`
+
+	QueueDepth int `json:"queue_depth"` // entries waiting to be delivered
+
+	// Lag is how long the sink has been behind: the time since its queue
+	// was last empty or a batch was last delivered, 0 when it is empty.
+	Lag time.Duration `json:"-"`
+
+	Dropped      uint64 `json:"dropped"`
+	DeadLettered uint64 `json:"dead_lettered"`
+	Missing      uint64 `json:"missing"` // see Delivery.Loss
+
+	LastError string `json:"last_error,omitempty"`
+}
+
+// MarshalJSON writes Lag as a duration string such as "1.5s".
+func (h SinkHealth) MarshalJSON() ([]byte, error) {
+	type plain SinkHealth
+	return json.Marshal(struct {
+		plain
+		Lag string `json:"lag"`
+	}{plain(h), h.Lag.String()})
+}
+
+// HealthStatus is the state of the sinks of a logger. It is Healthy when
+// every enabled sink is connected.
+type HealthStatus struct {
+	Healthy bool         `json:"healthy"`
+	Sinks   []SinkHealth `json:"sinks"`
+}
+
+// healthReporter is implemented by sinks that know their state, such as
+// Delivery. Other sinks are reported as connected.
+type healthReporter interface {
+	Health() SinkHealth
+}
+
+// Health returns the state of the sinks of the global logger.
+func Health() HealthStatus {
+	return logInstance.Load().Health()
+}
+
+// Health returns the state of the sinks, for orchestration to notice a
+// degraded logging pipeline.
+func (l *Logger) Health() HealthStatus {
+	h := HealthStatus{Healthy: true, Sinks: []SinkHealth{}}
+	for _, o := range l.outputs {
+		if o.sink == nil {
+			continue
+		}
+		s := SinkHealth{Name: o.sink.Name(), Connected: true}
+		if r, ok := o.sink.(healthReporter); ok {
+			s = r.Health()
+		}
+		s.Enabled = true
+		if on, ok := l.sinkSwitches[s.Name]; ok {
+			s.Enabled = on.Load()
+		}
+		if s.Enabled && !s.Connected {
+			h.Healthy = false
+		}
+		h.Sinks = append(h.Sinks, s)
+	}
+	return h
+}
+
+// HealthHandler serves the Health of the global logger as JSON, with
+// status 503 when it is not healthy, e.g. for a readiness probe:
+//
+//	http.Handle("/healthz/logging", logger.HealthHandler())
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logInstance.Load().HealthHandler().ServeHTTP(w, r)
+	})
+}
+
+// HealthHandler serves the Health of l as JSON, with status 503 when it
+// is not healthy.
+func (l *Logger) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := l.Health()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
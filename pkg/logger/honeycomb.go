@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HoneycombConfig configures a sink sending every entry as a Honeycomb
+// event, with all of its fields as event columns.
+type HoneycombConfig struct {
+	APIKey  string
+	Dataset string
+	APIHost string // default https://api.honeycomb.io
+
+	// SampleRate is reported for every event, default 1. An entry that
+	// carries a numeric sample_rate field, e.g. one written after sampling
+	// in the application, is reported with that rate instead.
+	SampleRate int
+
+	Client   *http.Client
+	Delivery DeliveryConfig
+}
+
+// NewHoneycombSink returns a sink posting batches to the Honeycomb batch
+// events API.
+func NewHoneycombSink(name string, cfg HoneycombConfig) (*Delivery, error) {
+	if cfg.APIKey == "" || cfg.Dataset == "" {
+		return nil, errors.New("honeycomb sink: API key and dataset are required")
+	}
+	if cfg.APIHost == "" {
+		cfg.APIHost = "https://api.honeycomb.io"
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 1
+	}
+	t := &httpTransport{
+		url:         strings.TrimRight(cfg.APIHost, "/") + "/1/batch/" + url.PathEscape(cfg.Dataset),
+		header:      make(http.Header),
+		compressor:  &gzipCompressor,
+		client:      cfg.Client,
+		contentType: "application/json",
+		encode: func(w io.Writer, batch [][]byte, keys entryKeys) error {
+			return writeHoneycombEvents(w, batch, keys, cfg.SampleRate)
+		},
+	}
+	t.header.Set("X-Honeycomb-Team", cfg.APIKey)
+	return NewDelivery(name, t, cfg.Delivery)
+}
+
+type honeycombEvent struct {
+	Time       string                     `json:"time"`
+	SampleRate int                        `json:"samplerate"`
+	Data       map[string]json.RawMessage `json:"data"`
+}
+
+func writeHoneycombEvents(w io.Writer, batch [][]byte, keys entryKeys, sampleRate int) error {
+	events := make([]honeycombEvent, 0, len(batch))
+	for _, entry := range batch {
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(entry, &data); err != nil {
+			continue
+		}
+		ev := honeycombEvent{SampleRate: sampleRate, Data: data}
+		t := time.Now()
+		if raw, ok := data[keys.Time]; ok {
+			if pt := parseEntryTime(raw, keys); !pt.IsZero() {
+				t = pt
+			}
+			delete(data, keys.Time)
+		}
+		ev.Time = t.Format(time.RFC3339Nano)
+		if raw, ok := data["sample_rate"]; ok {
+			var rate int
+			if json.Unmarshal(raw, &rate) == nil && rate > 0 {
+				ev.SampleRate = rate
+			}
+		}
+		events = append(events, ev)
+	}
+	return json.NewEncoder(w).Encode(events)
+}
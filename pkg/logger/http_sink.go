@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPConfig configures a sink that POSTs batches of entries as NDJSON.
+type HTTPConfig struct {
+	URL string
+
+	// Headers are set on every request, e.g. Authorization or an API key.
+	Headers map[string]string
+
+	// Gzip compresses request bodies.
+	Gzip bool
+
+	// Compression names the compressor of request bodies instead, e.g.
+	// "zstd" in binaries built with the zstd tag.
+	Compression string
+
+	// Client sends the requests, http.DefaultClient by default. Request
+	// deadlines come from Delivery.SendTimeout.
+	Client *http.Client
+
+	Delivery DeliveryConfig
+}
+
+// NewHTTPSink returns a sink for ingestion endpoints that accept NDJSON
+// over HTTP. Responses other than 2xx are retried, except 4xx responses
+// other than 408 and 429, which drop the batch.
+func NewHTTPSink(name string, cfg HTTPConfig) (*Delivery, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("http sink: no URL")
+	}
+	compressor, err := sinkCompressor(cfg.Compression, cfg.Gzip)
+	if err != nil {
+		return nil, fmt.Errorf("http sink: %w", err)
+	}
+	t := &httpTransport{
+		url:         cfg.URL,
+		header:      make(http.Header),
+		compressor:  compressor,
+		client:      cfg.Client,
+		contentType: "application/x-ndjson",
+		encode: func(w io.Writer, batch [][]byte, _ entryKeys) error {
+			return writeNDJSON(w, batch)
+		},
+	}
+	for k, v := range cfg.Headers {
+		t.header.Set(k, v)
+	}
+	return NewDelivery(name, t, cfg.Delivery)
+}
+
+// httpTransport POSTs each batch, encoded by encode, to url. Sinks for
+// specific services reuse it with their own encoding and headers.
+type httpTransport struct {
+	url         string
+	header      http.Header
+	compressor  *Compressor // nil sends bodies as they are
+	client      *http.Client
+	contentType string
+	encode      func(w io.Writer, batch [][]byte, keys entryKeys) error
+}
+
+func (t *httpTransport) Send(ctx context.Context, batch [][]byte) error {
+	keys := entryKeysFrom(ctx)
+	var body bytes.Buffer
+	if t.compressor != nil {
+		zw, err := t.compressor.NewWriter(&body)
+		if err != nil {
+			return Permanent(err)
+		}
+		if err := t.encode(zw, batch, keys); err != nil {
+			return Permanent(err)
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	} else if err := t.encode(&body, batch, keys); err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, &body)
+	if err != nil {
+		return Permanent(err)
+	}
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", t.contentType)
+	if t.compressor != nil {
+		req.Header.Set("Content-Encoding", t.compressor.Name)
+	}
+
+	client := t.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
+
+func (t *httpTransport) Close() error {
+	return nil
+}
+
+// writeNDJSON writes one entry per line.
+func writeNDJSON(w io.Writer, batch [][]byte) error {
+	for _, entry := range batch {
+		if _, err := w.Write(entry); err != nil {
+			return err
+		}
+		if len(entry) > 0 && entry[len(entry)-1] != '\n' {
+			if _, err := w.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
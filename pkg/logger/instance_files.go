@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config.FileInstance values naming an ID of the process.
+const (
+	FileInstancePID      = "pid"
+	FileInstanceHostname = "hostname"
+)
+
+// instanceID returns the ID suffixing the log file paths, empty without
+// FileInstance. Characters other than letters, digits, '_' and '.' become
+// '_', so the ID never holds the '-' separating it from the name.
+func (c *Config) instanceID() string {
+	id := c.FileInstance
+	switch strings.ToLower(id) {
+	case "":
+		return ""
+	case FileInstancePID:
+		id = strconv.Itoa(os.Getpid())
+	case FileInstanceHostname:
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		id = host
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// withInstancePaths returns c with the info, error and audit log paths
+// suffixed with the instance ID, or c itself without FileInstance.
+func (c *Config) withInstancePaths() *Config {
+	id := c.instanceID()
+	if id == "" {
+		return c
+	}
+	suffixed := *c
+	suffixed.InfoLogPath = instancePath(c.InfoLogPath, id)
+	suffixed.ErrorLogPath = instancePath(c.ErrorLogPath, id)
+	suffixed.AuditLogPath = instancePath(c.AuditLogPath, id)
+	return &suffixed
+}
+
+// instancePath inserts id before the extension of path: logs/app-info.log
+// becomes logs/app-info-<id>.log. Sockets and empty paths are left alone.
+func instancePath(path, id string) string {
+	if path == "" || strings.HasPrefix(path, unixStreamPrefix) || strings.HasPrefix(path, unixgramPrefix) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + id + ext
+}
+
+// removeStaleInstanceFiles removes, in the background, the files of other
+// instances of the log file at path, as configured before suffixing, that
+// were not modified for maxAge: their log files, backups, checksum
+// manifests and lock files.
+func removeStaleInstanceFiles(path, id string, maxAge time.Duration) {
+	if maxAge <= 0 || path == "" || instancePath(path, id) == path {
+		return
+	}
+	go func() {
+		if err := removeStaleInstances(path, id, maxAge); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: %s: removing stale files: %v\n", path, err)
+		}
+	}()
+}
+
+func removeStaleInstances(path, id string, maxAge time.Duration) error {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	// name-<id>[-<backup time>]<ext>[.gz, .sha256, .lock ...]
+	stale := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `-[A-Za-z0-9_.]+` +
+		`(-\d{4}-\d\d-\d\dT\d\d-\d\d-\d\d\.\d{3})?` + regexp.QuoteMeta(ext) + `(\.[A-Za-z0-9]+)*$`)
+	own := name + "-" + id
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		n := e.Name()
+		if !e.Type().IsRegular() || !stale.MatchString(n) || strings.HasPrefix(n, own+"-") || strings.HasPrefix(n, own+ext) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, n)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
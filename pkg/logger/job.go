@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Job describes one run of a background job taken from a queue.
+type Job struct {
+	ID    string
+	Queue string
+	Type  string // the task name, e.g. "email:send"
+
+	// Attempt is 1 for the first run and counts up with retries; 0 omits
+	// it.
+	Attempt int
+
+	// EnqueuedAt, when known, is used for the queue_latency field: how
+	// long the job waited before it started.
+	EnqueuedAt time.Time
+}
+
+type jobKey struct{}
+
+// jobRun is the job of a context and when it started.
+type jobRun struct {
+	job   Job
+	start time.Time
+}
+
+// fields returns the fields Ctx adds for the job.
+func (r jobRun) fields() []zap.Field {
+	fields := []zap.Field{zap.String("job_id", r.job.ID)}
+	if r.job.Queue != "" {
+		fields = append(fields, zap.String("queue", r.job.Queue))
+	}
+	if r.job.Type != "" {
+		fields = append(fields, zap.String("job_type", r.job.Type))
+	}
+	if r.job.Attempt > 0 {
+		fields = append(fields, zap.Int("attempt", r.job.Attempt))
+	}
+	if !r.job.EnqueuedAt.IsZero() {
+		fields = append(fields, zap.Duration("queue_latency", r.start.Sub(r.job.EnqueuedAt)))
+	}
+	return fields
+}
+
+// ContextWithJob returns a context in which loggers from Ctx tag entries
+// with the job_id, queue, job_type, attempt and queue_latency of job,
+// measuring the latency up to now.
+func ContextWithJob(ctx context.Context, job Job) context.Context {
+	return context.WithValue(ctx, jobKey{}, jobRun{job: job, start: time.Now()})
+}
+
+func jobFields(ctx context.Context) []zap.Field {
+	r, ok := ctx.Value(jobKey{}).(jobRun)
+	if !ok {
+		return nil
+	}
+	return r.fields()
+}
+
+// RunJob runs fn as job with a context from ContextWithJob and the logger
+// for it, and logs when the job starts and how it ends, with its duration.
+// It returns fn's error. With machinery, whose tasks get their signature
+// from the context:
+//
+//	func sendEmail(ctx context.Context, to string) error {
+//		sig := tasks.SignatureFromContext(ctx)
+//		job := logger.Job{ID: sig.UUID, Queue: sig.RoutingKey, Type: sig.Name, Attempt: sig.RetryCount + 1}
+//		return log.RunJob(ctx, job, func(ctx context.Context, log *logger.Logger) error {
+//			...
+//		})
+//	}
+func (l *Logger) RunJob(ctx context.Context, job Job, fn func(context.Context, *Logger) error) error {
+	ctx = ContextWithJob(ctx, job)
+	jl := l.Ctx(ctx)
+	start := time.Now()
+	jl.Info("job started")
+	err := fn(ctx, jl)
+	if err != nil {
+		jl.Error("job failed", err, zap.Duration("duration", time.Since(start)))
+		return err
+	}
+	jl.Info("job finished", zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// WrapJobHandler returns a handler running handler through RunJob, for
+// worker libraries whose handlers take a context and a task, with describe
+// telling what job a task is. With asynq:
+//
+//	describe := func(ctx context.Context, t *asynq.Task) logger.Job {
+//		id, _ := asynq.GetTaskID(ctx)
+//		queue, _ := asynq.GetQueueName(ctx)
+//		retried, _ := asynq.GetRetryCount(ctx)
+//		return logger.Job{ID: id, Queue: queue, Type: t.Type(), Attempt: retried + 1}
+//	}
+//	mux.HandleFunc("email:send", logger.WrapJobHandler(log, describe, sendEmail))
+//
+// The handler gets the logger for the job with Ctx(ctx).
+func WrapJobHandler[T any](l *Logger, describe func(context.Context, T) Job, handler func(context.Context, T) error) func(context.Context, T) error {
+	return func(ctx context.Context, task T) error {
+		return l.RunJob(ctx, describe(ctx, task), func(ctx context.Context, _ *Logger) error {
+			return handler(ctx, task)
+		})
+	}
+}
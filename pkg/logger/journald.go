@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+// JournaldSink writes entries to the local systemd journal using its native
+// protocol. The message, priority and caller become the standard journal
+// fields and every other field becomes a journal field of its own, with
+// its name upper-cased and invalid characters replaced by '_', so entries
+// are not wrapped as JSON inside MESSAGE. Names journald reserves, such as
+// MESSAGE or PRIORITY, are prefixed with FIELD_.
+type JournaldSink struct {
+	mu         sync.Mutex
+	conn       *net.UnixConn
+	addr       *net.UnixAddr
+	identifier string
+}
+
+// NewJournaldSink connects to the journal socket. identifier is recorded as
+// SYSLOG_IDENTIFIER and defaults to the executable name.
+func NewJournaldSink(identifier string) (*JournaldSink, error) {
+	if identifier == "" {
+		identifier = filepath.Base(os.Args[0])
+	}
+	addr := &net.UnixAddr{Name: journaldSocket, Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	return &JournaldSink{conn: conn, addr: addr, identifier: identifier}, nil
+}
+
+func (s *JournaldSink) Name() string {
+	return "journald"
+}
+
+// Write sends an already encoded entry as the MESSAGE of an Info entry.
+// Entries logged through a Logger do not go through Write.
+func (s *JournaldSink) Write(p []byte) (int, error) {
+	var msg bytes.Buffer
+	appendJournalField(&msg, "MESSAGE", string(bytes.TrimRight(p, "\n")))
+	appendJournalField(&msg, "PRIORITY", "6")
+	appendJournalField(&msg, "SYSLOG_IDENTIFIER", s.identifier)
+	if err := s.send(msg.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *JournaldSink) Sync() error {
+	return nil
+}
+
+func (s *JournaldSink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *JournaldSink) send(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _, err := s.conn.WriteMsgUnix(msg, nil, s.addr)
+	if err != nil && isMessageTooLong(err) {
+		// Large entries are passed in a sealed file descriptor instead.
+		err = sendJournalFD(s.conn, s.addr, msg)
+	}
+	if err != nil {
+		return fmt.Errorf("journald: %w", err)
+	}
+	return nil
+}
+
+func (s *JournaldSink) newCore(encoderConfig zapcore.EncoderConfig) zapcore.Core {
+	return &journaldCore{
+		LevelEnabler: allLevels,
+		sink:         s,
+		fields:       zapcore.NewMapObjectEncoder(),
+	}
+}
+
+type journaldCore struct {
+	zapcore.LevelEnabler
+	sink   *JournaldSink
+	fields *zapcore.MapObjectEncoder
+}
+
+func (c *journaldCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &journaldCore{
+		LevelEnabler: c.LevelEnabler,
+		sink:         c.sink,
+		fields:       zapcore.NewMapObjectEncoder(),
+	}
+	for k, v := range c.fields.Fields {
+		clone.fields.Fields[k] = v
+	}
+	for i := range fields {
+		fields[i].AddTo(clone.fields)
+	}
+	return clone
+}
+
+func (c *journaldCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *journaldCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for k, v := range c.fields.Fields {
+		enc.Fields[k] = v
+	}
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+
+	var msg bytes.Buffer
+	appendJournalField(&msg, "MESSAGE", ent.Message)
+	appendJournalField(&msg, "PRIORITY", strconv.Itoa(journalPriority(ent.Level)))
+	appendJournalField(&msg, "SYSLOG_IDENTIFIER", c.sink.identifier)
+	if ent.LoggerName != "" {
+		appendJournalField(&msg, "LOGGER", ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		appendJournalField(&msg, "CODE_FILE", ent.Caller.File)
+		appendJournalField(&msg, "CODE_LINE", strconv.Itoa(ent.Caller.Line))
+		appendJournalField(&msg, "CODE_FUNC", ent.Caller.Function)
+	}
+	if ent.Stack != "" {
+		appendJournalField(&msg, "STACKTRACE", ent.Stack)
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		appendJournalField(&msg, journalFieldName(k), journalValue(enc.Fields[k]))
+	}
+	return c.sink.send(msg.Bytes())
+}
+
+func (c *journaldCore) Sync() error {
+	return nil
+}
+
+// journalPriority maps levels to syslog priorities.
+func journalPriority(lvl zapcore.Level) int {
+	return SeverityOf(lvl).Syslog
+}
+
+// journalReservedFields are the names Write sets itself and those journald
+// interprets, which user fields are renamed away from with a FIELD_ prefix.
+var journalReservedFields = map[string]bool{
+	"MESSAGE":            true,
+	"MESSAGE_ID":         true,
+	"PRIORITY":           true,
+	"CODE_FILE":          true,
+	"CODE_LINE":          true,
+	"CODE_FUNC":          true,
+	"ERRNO":              true,
+	"INVOCATION_ID":      true,
+	"USER_INVOCATION_ID": true,
+	"SYSLOG_FACILITY":    true,
+	"SYSLOG_IDENTIFIER":  true,
+	"SYSLOG_PID":         true,
+	"SYSLOG_TIMESTAMP":   true,
+	"SYSLOG_RAW":         true,
+	"DOCUMENTATION":      true,
+	"TID":                true,
+	"UNIT":               true,
+	"USER_UNIT":          true,
+	"LOGGER":             true,
+	"STACKTRACE":         true,
+}
+
+// journalFieldName converts key to a valid journal field name: upper case
+// letters, digits and '_', not starting with a digit or '_', at most 64
+// bytes, and not one of journalReservedFields.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, b := range name {
+		if !(b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_') {
+			name[i] = '_'
+		}
+	}
+	name = bytes.TrimLeft(name, "_")
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		name = append([]byte("F_"), name...)
+	}
+	if journalReservedFields[string(name)] {
+		name = append([]byte("FIELD_"), name...)
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return string(name)
+}
+
+func journalValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// appendJournalField appends one field in the native protocol format;
+// values containing newlines are length-prefixed.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
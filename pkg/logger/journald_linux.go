@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+func isMessageTooLong(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendJournalFD writes msg to an unlinked temporary file and passes its
+// descriptor to journald, which reads the entry from it.
+func sendJournalFD(conn *net.UnixConn, addr *net.UnixAddr, msg []byte) error {
+	f, err := os.CreateTemp("/dev/shm", "journal.")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	os.Remove(f.Name())
+	if _, err := f.Write(msg); err != nil {
+		return err
+	}
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), addr)
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package logger
+
+import (
+	"errors"
+	"net"
+)
+
+func isMessageTooLong(err error) bool {
+	return false
+}
+
+func sendJournalFD(conn *net.UnixConn, addr *net.UnixAddr, msg []byte) error {
+	return errors.New("journald is only available on linux")
+}
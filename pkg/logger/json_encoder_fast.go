@@ -0,0 +1,11 @@
+//go:build fastjson
+
+package logger
+
+import "go.uber.org/zap/zapcore"
+
+// newJSONEncoder returns the encoder of JSON outputs, NewFastJSONEncoder
+// when built with the fastjson tag.
+func newJSONEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return NewFastJSONEncoder(cfg)
+}
@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DownwardAPIDir is where KubernetesFields looks for a downward API
+	// volume exposing metadata.name, metadata.namespace and metadata.labels
+	// as the files "name", "namespace" and "labels".
+	DownwardAPIDir = "/etc/podinfo"
+
+	serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// KubernetesConfig returns a configuration for in-cluster logging: JSON on
+// stdout for the node's log collector and no log files.
+func KubernetesConfig() *Config {
+	return &Config{
+		Mode:         ModeProduction,
+		DisableFiles: true,
+		ConsoleJSON:  true,
+		StderrLevel:  "off",
+	}
+}
+
+// DetectKubernetes reports KubernetesFields when running in a Kubernetes
+// pod.
+func DetectKubernetes(ctx context.Context) ([]zap.Field, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil, nil
+	}
+	return KubernetesFields(DownwardAPIDir), nil
+}
+
+// KubernetesFields returns the pod name, namespace, node and labels exposed
+// through the downward API, either as the POD_NAME, POD_NAMESPACE and
+// NODE_NAME environment variables or as files in dir. The pod name falls
+// back to the hostname and the namespace to the service account's.
+func KubernetesFields(dir string) []zap.Field {
+	pod := firstNonEmpty(os.Getenv("POD_NAME"), readTrimmed(filepath.Join(dir, "name")))
+	if pod == "" {
+		pod, _ = os.Hostname()
+	}
+	namespace := firstNonEmpty(
+		os.Getenv("POD_NAMESPACE"),
+		readTrimmed(filepath.Join(dir, "namespace")),
+		readTrimmed(serviceAccountNamespace),
+	)
+
+	fields := []zap.Field{zap.String("k8s.pod.name", pod)}
+	if namespace != "" {
+		fields = append(fields, zap.String("k8s.namespace.name", namespace))
+	}
+	if node := os.Getenv("NODE_NAME"); node != "" {
+		fields = append(fields, zap.String("k8s.node.name", node))
+	}
+	if labels := readDownwardMap(filepath.Join(dir, "labels")); len(labels) > 0 {
+		fields = append(fields, zap.Any("k8s.pod.labels", labels))
+	}
+	return fields
+}
+
+// readDownwardMap parses a downward API labels or annotations file, which
+// has one key="value" pair per line with the value quoted as in Go.
+func readDownwardMap(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	m := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, quoted, ok := strings.Cut(sc.Text(), "=")
+		if !ok {
+			continue
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			value = quoted
+		}
+		m[key] = value
+	}
+	return m
+}
+
+func readTrimmed(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
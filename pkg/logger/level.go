@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ParseLevel parses a level name case-insensitively. Besides zap's names
+// it accepts "trace" and the names given to RegisterLevel, "warning",
+// "err", "critical" and "crit", the latter two meaning dpanic, and zap's
+// numeric levels from -1 (debug) to 5 (fatal).
+func ParseLevel(s string) (zapcore.Level, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if lvl, ok := registeredLevel(name); ok {
+		return lvl, nil
+	}
+	switch name {
+	case "warning":
+		return zapcore.WarnLevel, nil
+	case "err":
+		return zapcore.ErrorLevel, nil
+	case "critical", "crit":
+		return zapcore.DPanicLevel, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < int(zapcore.DebugLevel) || n > int(zapcore.FatalLevel) {
+			return 0, fmt.Errorf("invalid level %d: must be between %d and %d", n, zapcore.DebugLevel, zapcore.FatalLevel)
+		}
+		return zapcore.Level(n), nil
+	}
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(name)); err != nil || name == "" {
+		return 0, fmt.Errorf("invalid level %q: use trace, debug, info, warn, error, dpanic, panic or fatal", s)
+	}
+	return lvl, nil
+}
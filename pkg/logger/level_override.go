@@ -0,0 +1,171 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelRule sets the level for code in pkg and the packages below it.
+type levelRule struct {
+	pkg   string
+	level zapcore.Level
+}
+
+// parseLevelOverrides parses rules of the form "import/path=level".
+func parseLevelOverrides(specs []string) ([]levelRule, error) {
+	rules := make([]levelRule, 0, len(specs))
+	for _, spec := range specs {
+		pkg, level, ok := strings.Cut(spec, "=")
+		pkg = strings.TrimSpace(pkg)
+		if !ok || pkg == "" {
+			return nil, fmt.Errorf("invalid level override %q: want package=level", spec)
+		}
+		lvl, err := ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("level override %q: %w", spec, err)
+		}
+		rules = append(rules, levelRule{pkg: strings.TrimSuffix(pkg, "/"), level: lvl})
+	}
+	// Longest first, so the most specific rule matches.
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].pkg) > len(rules[j].pkg) })
+	return rules, nil
+}
+
+// levelCore applies a base level and per-package overrides chosen by the
+// package of the code that logged each entry. Entries every rule would
+// accept pass straight through; the others are decided in Write, where
+// the matching rule is looked up once per call site.
+type levelCore struct {
+	zapcore.Core
+	base     zap.AtomicLevel
+	rules    []levelRule
+	min, max zapcore.Level // over the rules
+	sites    *sync.Map     // program counter -> index of rule, -1 for none
+	debug    bool          // in a debug session, see ActivateDebug
+}
+
+func newLevelCore(core zapcore.Core, base zap.AtomicLevel, rules []levelRule) zapcore.Core {
+	c := &levelCore{Core: core, base: base, rules: rules, sites: new(sync.Map)}
+	c.min, c.max = zapcore.FatalLevel, zapcore.DebugLevel
+	for _, r := range rules {
+		if r.level < c.min {
+			c.min = r.level
+		}
+		if r.level > c.max {
+			c.max = r.level
+		}
+	}
+	return c
+}
+
+// bounds returns the lowest and highest level any rule or the base sets.
+func (c *levelCore) bounds() (min, max zapcore.Level) {
+	base := c.base.Level()
+	if len(c.rules) == 0 {
+		return base, base
+	}
+	min, max = c.min, c.max
+	if base < min {
+		min = base
+	}
+	if base > max {
+		max = base
+	}
+	return min, max
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	if c.debug && lvl >= zapcore.DebugLevel {
+		return c.Core.Enabled(lvl)
+	}
+	min, _ := c.bounds()
+	return lvl >= min && c.Core.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	clone.debug = c.debug || hasDebugSession(fields)
+	return &clone
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.debug && ent.Level >= zapcore.DebugLevel {
+		return c.Core.Check(ent, ce)
+	}
+	min, max := c.bounds()
+	switch {
+	case ent.Level < min:
+		return ce
+	case ent.Level >= max:
+		return c.Core.Check(ent, ce)
+	case c.Core.Enabled(ent.Level):
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *levelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < c.callerLevel() {
+		return nil
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+func (c *levelCore) writeBatch(entries []batchEntry) error {
+	lvl := c.callerLevel()
+	kept := make([]batchEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.ent.Level >= lvl {
+			kept = append(kept, e)
+		}
+	}
+	return writeBatch(c.Core, kept)
+}
+
+// callerLevel returns the level for the code that logged the entry.
+func (c *levelCore) callerLevel() zapcore.Level {
+	frame, ok := externalCaller()
+	if !ok {
+		return c.base.Level()
+	}
+	rule, ok := c.sites.Load(frame.PC)
+	if !ok {
+		rule = c.ruleFor(funcPackage(frame.Function))
+		c.sites.Store(frame.PC, rule)
+	}
+	if i := rule.(int); i >= 0 {
+		return c.rules[i].level
+	}
+	return c.base.Level()
+}
+
+// ruleFor returns the index of the rule for pkg, or -1.
+func (c *levelCore) ruleFor(pkg string) int {
+	for i, r := range c.rules {
+		if pkg == r.pkg || strings.HasPrefix(pkg, r.pkg+"/") {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetLevel changes the lowest level the logger writes, in any form
+// ParseLevel accepts. Per-package overrides stay in effect. Loggers
+// derived with With share the level.
+func (l *Logger) SetLevel(level string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.level.SetLevel(lvl)
+	return nil
+}
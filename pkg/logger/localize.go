@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"sync"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var translations struct {
+	mu       sync.RWMutex
+	messages map[string]map[string]string // locale -> event code -> message
+}
+
+// RegisterTranslation sets the message printed on the console for entries
+// with the event code when Config.ConsoleLocale is locale. Files, sinks
+// and JSON console output keep the original message.
+func RegisterTranslation(locale, code, message string) {
+	translations.mu.Lock()
+	defer translations.mu.Unlock()
+	if translations.messages == nil {
+		translations.messages = make(map[string]map[string]string)
+	}
+	if translations.messages[locale] == nil {
+		translations.messages[locale] = make(map[string]string)
+	}
+	translations.messages[locale][code] = message
+}
+
+func translation(locale, code string) (string, bool) {
+	translations.mu.RLock()
+	defer translations.mu.RUnlock()
+	message, ok := translations.messages[locale][code]
+	return message, ok
+}
+
+// localizingEncoder replaces the message of entries with an event code by
+// its translation for locale.
+type localizingEncoder struct {
+	zapcore.Encoder
+	locale string
+	code   string // added through With
+}
+
+func (e *localizingEncoder) Clone() zapcore.Encoder {
+	return &localizingEncoder{Encoder: e.Encoder.Clone(), locale: e.locale, code: e.code}
+}
+
+func (e *localizingEncoder) AddString(key, value string) {
+	if key == EventCodeKey {
+		e.code = value
+	}
+	e.Encoder.AddString(key, value)
+}
+
+func (e *localizingEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	code := e.code
+	for _, f := range fields {
+		if f.Key == EventCodeKey && f.Type == zapcore.StringType {
+			code = f.String
+		}
+	}
+	if code != "" {
+		if message, ok := translation(e.locale, code); ok {
+			ent.Message = message
+		}
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// LossReport counts the entries of a stream numbered by Config.EntryIDs.
+type LossReport struct {
+	Received   uint64
+	Missing    uint64 // numbers skipped and not received since
+	Duplicates uint64
+	Restarts   uint64 // times the numbering started over, at 1
+}
+
+func (r LossReport) String() string {
+	s := fmt.Sprintf("%d received, %d missing", r.Received, r.Missing)
+	if total := r.Received + r.Missing; total > 0 && r.Missing > 0 {
+		s += fmt.Sprintf(" (%.2f%%)", 100*float64(r.Missing)/float64(total))
+	}
+	if r.Duplicates > 0 {
+		s += fmt.Sprintf(", %d duplicates", r.Duplicates)
+	}
+	if r.Restarts > 0 {
+		s += fmt.Sprintf(", %d restarts", r.Restarts)
+	}
+	return s
+}
+
+// maxTrackedGaps bounds the gaps a SequenceTracker remembers; numbers of
+// older gaps arriving late are taken for duplicates.
+const maxTrackedGaps = 4096
+
+// SequenceTracker detects entries lost on the way by the gaps in their
+// SequenceKey numbers. Numbers may arrive somewhat out of order, as when
+// the info and error files are read one after the other or spilled entries
+// are delivered late: a number filling a gap is no longer missing. The
+// numbers before the first one observed are not counted, since rotated
+// files may have been removed. The zero value is ready to use.
+type SequenceTracker struct {
+	mu     sync.Mutex
+	next   uint64     // expected number, 0 before the first
+	gaps   []seqRange // missing numbers, in order
+	report LossReport
+}
+
+// seqRange is the numbers from first to last, inclusive.
+type seqRange struct {
+	first, last uint64
+}
+
+// Observe records the arrival of entry number seq.
+func (t *SequenceTracker) Observe(seq uint64) {
+	if seq == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.report.Received++
+	switch {
+	case t.next == 0 || seq == t.next:
+	case seq > t.next:
+		t.report.Missing += seq - t.next
+		t.gaps = append(t.gaps, seqRange{t.next, seq - 1})
+		if len(t.gaps) > maxTrackedGaps {
+			t.gaps = append(t.gaps[:0], t.gaps[1:]...)
+		}
+	case t.fill(seq):
+		return
+	case seq == 1:
+		// The process started over; the gaps of the last run can no
+		// longer be told from those of this one.
+		t.report.Restarts++
+		t.gaps = t.gaps[:0]
+	default:
+		t.report.Received--
+		t.report.Duplicates++
+		return
+	}
+	t.next = seq + 1
+}
+
+// ObserveEntry records the arrival of a JSON entry, if it has a sequence
+// number.
+func (t *SequenceTracker) ObserveEntry(entry []byte) {
+	if seq, ok := sequenceOf(entry); ok {
+		t.Observe(seq)
+	}
+}
+
+// fill removes seq from the gaps, reporting whether it was in one.
+func (t *SequenceTracker) fill(seq uint64) bool {
+	i := sort.Search(len(t.gaps), func(i int) bool { return t.gaps[i].last >= seq })
+	if i == len(t.gaps) || t.gaps[i].first > seq {
+		return false
+	}
+	t.report.Missing--
+	switch g := t.gaps[i]; {
+	case g.first == g.last:
+		t.gaps = append(t.gaps[:i], t.gaps[i+1:]...)
+	case seq == g.first:
+		t.gaps[i].first++
+	case seq == g.last:
+		t.gaps[i].last--
+	default:
+		t.gaps = append(t.gaps[:i+1], t.gaps[i:]...)
+		t.gaps[i].last = seq - 1
+		t.gaps[i+1].first = seq + 1
+	}
+	return true
+}
+
+// Report returns the counts so far.
+func (t *SequenceTracker) Report() LossReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.report
+}
+
+// sequenceKey is how SequenceKey starts in a JSON entry.
+var sequenceKey = []byte(`"` + SequenceKey + `":`)
+
+// sequenceOf returns the sequence number of a JSON entry. The number is
+// looked for from the end, where entryIDCore puts it, without decoding the
+// entry.
+func sequenceOf(entry []byte) (uint64, bool) {
+	i := bytes.LastIndex(entry, sequenceKey)
+	if i < 0 {
+		return 0, false
+	}
+	rest := entry[i+len(sequenceKey):]
+	n := 0
+	for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+		n++
+	}
+	seq, err := strconv.ParseUint(string(rest[:n]), 10, 64)
+	return seq, err == nil
+}
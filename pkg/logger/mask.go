@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Mask constructs a field for a secret such as a password or token,
+// logged as asterisks followed by its last four characters when it is long
+// enough for those not to give it away, e.g. "****f00d".
+func Mask(key, value string) zap.Field {
+	return zap.String(key, maskValue(value))
+}
+
+// maskedTail is the number of characters Mask keeps; values shorter than
+// maskedMinLen are hidden completely.
+const (
+	maskedTail   = 4
+	maskedMinLen = 12
+)
+
+func maskValue(value string) string {
+	runes := []rune(value)
+	if len(runes) < maskedMinLen {
+		return strings.Repeat("*", 4)
+	}
+	return strings.Repeat("*", 4) + string(runes[len(runes)-maskedTail:])
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// HTTPMiddleware logs every request handled by next using the global logger.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logInstance.Load().HTTPMiddleware(next).ServeHTTP(w, r)
+	})
+}
+
+// HTTPMiddleware continues the W3C trace from the incoming traceparent
+// header, or one found by a registered TraceExtractor, or starts a new
+// one, and logs the request when it completes.
+// The trace is stored in the request context so Ctx(r.Context()) tags
+// entries from the handler with the same trace_id and span_id, and the
+// traceparent header of the request next gets, a copy of the caller's, is
+// rewritten to the server span so it can be copied onto outgoing calls.
+// Requests with a valid DebugLogHeader token get a debug session. With
+// TailRetention, the debug and info entries of Ctx loggers are only
+// written for requests that fail or are slow.
+func (l *Logger) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		var tc TraceContext
+		parent, extra, ok := extractTrace(r.Header)
+		if ok {
+			tc = parent.Child()
+		} else {
+			tc = NewTraceContext()
+		}
+		ctx := contextWithTraceFields(ContextWithTrace(r.Context(), tc), extra)
+		if token := r.Header.Get(DebugLogHeader); token != "" && l.validDebugToken(token) {
+			ctx = ActivateDebug(ctx)
+		}
+		var tape *Tape
+		if l.tail.Enabled {
+			ctx, tape = StartTape(ctx, l.tail.MaxEntries)
+		}
+		r = r.WithContext(ctx)
+		r.Header = r.Header.Clone()
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		r.Header.Set(TraceparentHeader, tc.Traceparent())
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if tape != nil {
+			if rec.status >= http.StatusInternalServerError || l.tail.Latency > 0 && time.Since(start) > l.tail.Latency {
+				tape.Flush()
+			} else {
+				tape.Discard()
+			}
+		}
+
+		fields := append(append(tc.Fields(), extra...),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", rec.status),
+			zap.Int("bytes", rec.bytes),
+			zap.Duration("duration", time.Since(start)),
+		)
+		l.Info("http request", fields...)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush passes flushes of streaming handlers on to the underlying writer.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket and other upgrading handlers take over the
+// connection.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer cannot be hijacked")
+	}
+	r.wroteHeader = true
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MQTTConfig configures a sink that publishes entries to an MQTT topic.
+// Like the NATS sink it takes a publish function rather than a client;
+// with paho.mqtt.golang:
+//
+//	Publish: func(ctx context.Context, topic string, qos byte, retain bool, payload []byte) error {
+//		timeout := 10 * time.Second
+//		if dl, ok := ctx.Deadline(); ok {
+//			timeout = time.Until(dl)
+//		}
+//		tok := client.Publish(topic, qos, retain, payload)
+//		if !tok.WaitTimeout(timeout) {
+//			return errors.New("mqtt publish timed out")
+//		}
+//		return tok.Error()
+//	}
+//
+// While the broker is unreachable entries wait in the delivery queue; set
+// Delivery.WALDir to keep them on disk across restarts of the device.
+type MQTTConfig struct {
+	Topic  string
+	QoS    byte // 0, 1 or 2
+	Retain bool
+
+	// Batched sends each batch as a single NDJSON message, which saves
+	// per-message overhead on constrained links.
+	Batched bool
+
+	// Publish sends one message and, for QoS 1 and 2, returns once the
+	// broker has acknowledged it. It must not retain payload.
+	Publish func(ctx context.Context, topic string, qos byte, retain bool, payload []byte) error
+
+	Delivery DeliveryConfig
+}
+
+// NewMQTTSink returns a sink publishing to cfg.Topic.
+func NewMQTTSink(name string, cfg MQTTConfig) (*Delivery, error) {
+	if cfg.Topic == "" || cfg.Publish == nil {
+		return nil, errors.New("mqtt sink: topic and publish function are required")
+	}
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("mqtt sink: invalid QoS %d", cfg.QoS)
+	}
+	return NewDelivery(name, &mqttTransport{cfg: cfg}, cfg.Delivery)
+}
+
+type mqttTransport struct {
+	cfg MQTTConfig
+	buf bytes.Buffer
+}
+
+func (t *mqttTransport) Send(ctx context.Context, batch [][]byte) error {
+	if t.cfg.Batched {
+		t.buf.Reset()
+		writeNDJSON(&t.buf, batch)
+		return t.publish(ctx, t.buf.Bytes())
+	}
+	for _, entry := range batch {
+		if err := t.publish(ctx, bytes.TrimRight(entry, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *mqttTransport) publish(ctx context.Context, payload []byte) error {
+	if err := t.cfg.Publish(ctx, t.cfg.Topic, t.cfg.QoS, t.cfg.Retain, payload); err != nil {
+		return fmt.Errorf("publish to %s: %w", t.cfg.Topic, err)
+	}
+	return nil
+}
+
+func (t *mqttTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,23 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Namespace nests fields under key, e.g. Namespace("db", zap.String("table",
+// "users"), zap.Int("rows", 3)) encodes as "db":{"table":"users","rows":3}.
+// Unlike zap.Namespace it only affects the given fields, so it can be
+// combined freely with other fields in the same call.
+func Namespace(key string, fields ...zap.Field) zap.Field {
+	return zap.Object(key, fieldGroup(fields))
+}
+
+type fieldGroup []zap.Field
+
+func (g fieldGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for i := range g {
+		g[i].AddTo(enc)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// NATSConfig configures a sink that publishes every entry as a message on
+// a NATS subject. The sink takes the publishing functions instead of a
+// connection so the logger does not depend on a NATS client; with nats.go:
+//
+//	NATSConfig{Subject: "logs.api", Publish: nc.Publish, Flush: nc.FlushWithContext}
+//
+// and for a JetStream stream, with the acknowledgements of each batch
+// awaited and the first failure returned, so nacked or timed out messages
+// are resent:
+//
+//	var pending []nats.PubAckFuture
+//	...
+//	Publish: func(subj string, data []byte) error {
+//		f, err := js.PublishAsync(subj, data)
+//		if err == nil {
+//			pending = append(pending, f)
+//		}
+//		return err
+//	},
+//	Flush: func(ctx context.Context) error {
+//		defer func() { pending = pending[:0] }()
+//		for _, f := range pending {
+//			select {
+//			case <-f.Ok():
+//			case err := <-f.Err():
+//				return err
+//			case <-ctx.Done():
+//				return ctx.Err()
+//			}
+//		}
+//		return nil
+//	},
+//
+// Publish and Flush are only called from the goroutine delivering the
+// sink's batches, so pending needs no lock.
+type NATSConfig struct {
+	Subject string
+
+	// Publish sends one message. It must not retain data.
+	Publish func(subject string, data []byte) error
+
+	// Flush, when set, is called after each batch and waits until the
+	// server has received, or for JetStream acknowledged, its messages.
+	// A failure resends the whole batch.
+	Flush func(ctx context.Context) error
+
+	Delivery DeliveryConfig
+}
+
+// NewNATSSink returns a sink publishing to cfg.Subject.
+func NewNATSSink(name string, cfg NATSConfig) (*Delivery, error) {
+	if cfg.Subject == "" || cfg.Publish == nil {
+		return nil, errors.New("nats sink: subject and publish function are required")
+	}
+	return NewDelivery(name, &natsTransport{cfg: cfg}, cfg.Delivery)
+}
+
+type natsTransport struct {
+	cfg NATSConfig
+}
+
+func (t *natsTransport) Send(ctx context.Context, batch [][]byte) error {
+	for _, entry := range batch {
+		if err := t.cfg.Publish(t.cfg.Subject, entry); err != nil {
+			return fmt.Errorf("publish to %s: %w", t.cfg.Subject, err)
+		}
+	}
+	if t.cfg.Flush != nil {
+		return t.cfg.Flush(ctx)
+	}
+	return nil
+}
+
+func (t *natsTransport) Close() error {
+	return nil
+}
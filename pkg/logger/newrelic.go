@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// New Relic log ingestion endpoints.
+const (
+	NewRelicLogsURL   = "https://log-api.newrelic.com/log/v1"
+	NewRelicLogsURLEU = "https://log-api.eu.newrelic.com/log/v1"
+)
+
+// NewRelicConfig configures a sink for the New Relic Logs API.
+type NewRelicConfig struct {
+	LicenseKey string
+	URL        string // default NewRelicLogsURL
+
+	// EntityGUID, EntityName and Hostname link the logs to the service's
+	// entity for logs in context. With the Go agent they come from
+	// app.GetLinkingMetadata().
+	EntityGUID string
+	EntityName string
+	Hostname   string
+
+	Client   *http.Client
+	Delivery DeliveryConfig
+}
+
+// NewNewRelicSink returns a sink posting batches to the New Relic Logs API.
+// The trace_id and span_id fields set by Ctx and the HTTP middleware are
+// sent as trace.id and span.id, so logs appear next to their traces.
+func NewNewRelicSink(name string, cfg NewRelicConfig) (*Delivery, error) {
+	if cfg.LicenseKey == "" {
+		return nil, errors.New("newrelic sink: no license key")
+	}
+	if cfg.URL == "" {
+		cfg.URL = NewRelicLogsURL
+	}
+	common := make(map[string]string)
+	for k, v := range map[string]string{
+		"entity.guid": cfg.EntityGUID,
+		"entity.name": cfg.EntityName,
+		"hostname":    cfg.Hostname,
+	} {
+		if v != "" {
+			common[k] = v
+		}
+	}
+	t := &httpTransport{
+		url:         cfg.URL,
+		header:      make(http.Header),
+		compressor:  &gzipCompressor,
+		client:      cfg.Client,
+		contentType: "application/json",
+		encode: func(w io.Writer, batch [][]byte, keys entryKeys) error {
+			return writeNewRelicLogs(w, batch, keys, common)
+		},
+	}
+	t.header.Set("X-License-Key", cfg.LicenseKey)
+	return NewDelivery(name, t, cfg.Delivery)
+}
+
+// newRelicAttributes renames fields to New Relic's linking attributes.
+var newRelicAttributes = map[string]string{
+	"trace_id": "trace.id",
+	"span_id":  "span.id",
+}
+
+type newRelicPayload struct {
+	Common struct {
+		Attributes map[string]string `json:"attributes,omitempty"`
+	} `json:"common"`
+	Logs []newRelicLog `json:"logs"`
+}
+
+type newRelicLog struct {
+	Timestamp  int64                      `json:"timestamp"` // milliseconds
+	Message    string                     `json:"message"`
+	Attributes map[string]json.RawMessage `json:"attributes"`
+}
+
+func writeNewRelicLogs(w io.Writer, batch [][]byte, keys entryKeys, common map[string]string) error {
+	var p newRelicPayload
+	p.Common.Attributes = common
+	p.Logs = make([]newRelicLog, 0, len(batch))
+	for _, entry := range batch {
+		e, err := decodeEntry(entry, keys)
+		if err != nil {
+			continue
+		}
+		attrs := e.Fields
+		for from, to := range newRelicAttributes {
+			if v, ok := attrs[from]; ok {
+				attrs[to] = v
+				delete(attrs, from)
+			}
+		}
+		if e.Level != "" {
+			attrs["level"], _ = json.Marshal(e.Level)
+		}
+		p.Logs = append(p.Logs, newRelicLog{
+			Timestamp:  e.Time.UnixMilli(),
+			Message:    e.Message,
+			Attributes: attrs,
+		})
+	}
+	return json.NewEncoder(w).Encode([]newRelicPayload{p})
+}
@@ -0,0 +1,195 @@
+package logger
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Option configures a logger built by New.
+type Option func(*options)
+
+type options struct {
+	config Config
+	settings
+}
+
+// settings are the parts of a logger configured only through options.
+type settings struct {
+	rotation    rotation
+	permissions FilePermissions
+	checksums   bool
+	compression string
+	fileSharing string // Config.FileSharing
+	console     bool
+	clock       zapcore.Clock
+	caller      bool
+
+	// unbufferedConsole writes console entries straight through.
+	unbufferedConsole bool
+
+	// sampleFirst and sampleThereafter configure sampling when
+	// sampleFirst is set.
+	sampleFirst, sampleThereafter int
+	samplingExemptions            []SamplingExemption
+	samplingBoost                 time.Duration
+}
+
+func defaultSettings() settings {
+	return settings{rotation: defaultRotation, console: true}
+}
+
+// New returns a logger configured by opts. Without options it prints to
+// the console only; WithFiles adds the info and error log files.
+func New(opts ...Option) (*Logger, error) {
+	o := options{config: Config{DisableFiles: true}, settings: defaultSettings()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.config.Validate(); err != nil {
+		return nil, err
+	}
+	if o.compression != "" {
+		if _, err := compressorNamed(o.compression); err != nil {
+			return nil, err
+		}
+	}
+	return newLogger(&o.config, o.settings), nil
+}
+
+// WithConfig starts from config, for settings without an option of their
+// own. It replaces what earlier options set in the Config, so pass it
+// first. DisableFiles is taken from config.
+func WithConfig(config Config) Option {
+	return func(o *options) {
+		o.config = config
+	}
+}
+
+// WithFiles writes entries up to warn level to infoPath and the rest to
+// errorPath.
+func WithFiles(infoPath, errorPath string) Option {
+	return func(o *options) {
+		o.config.InfoLogPath = infoPath
+		o.config.ErrorLogPath = errorPath
+		o.config.DisableFiles = false
+	}
+}
+
+// WithRotation rotates the log files once they reach maxMegabytes, keeping
+// maxBackups old files for at most maxAgeDays. Zero keeps backups of any
+// number or age. The default is 500MB, 3 backups and 28 days.
+func WithRotation(maxMegabytes, maxBackups, maxAgeDays int) Option {
+	return func(o *options) {
+		o.rotation = rotation{megabytes: maxMegabytes, backups: maxBackups, days: maxAgeDays}
+	}
+}
+
+// WithFilePermissions sets the mode and owner of the log files, their
+// backups and the directories created for them.
+func WithFilePermissions(perm FilePermissions) Option {
+	return func(o *options) {
+		o.permissions = perm
+	}
+}
+
+// WithChecksums keeps a "<log file>.sha256" manifest next to each log file
+// with the SHA-256 of its rotated backups, in the format "sha256sum -c"
+// verifies, so archived logs can be checked for tampering.
+func WithChecksums(enabled bool) Option {
+	return func(o *options) {
+		o.checksums = enabled
+	}
+}
+
+// WithCompression compresses rotated backups of the log files with the
+// compressor called name: "gzip", "zstd" in binaries built with the zstd
+// tag, or one registered with RegisterCompressor. The backups limits of
+// WithRotation still apply to them.
+func WithCompression(name string) Option {
+	return func(o *options) {
+		o.compression = name
+	}
+}
+
+// WithFsync sets when the info, error and audit log files are flushed to
+// disk.
+func WithFsync(policy FsyncPolicy) Option {
+	return func(o *options) {
+		o.config.InfoLogFsync = policy
+		o.config.ErrorLogFsync = policy
+		o.config.AuditLogFsync = policy
+	}
+}
+
+// WithConsole turns the stdout and stderr output on or off, on by default.
+func WithConsole(enabled bool) Option {
+	return func(o *options) {
+		o.console = enabled
+	}
+}
+
+// WithSink adds a sink receiving every entry.
+func WithSink(sink Sink) Option {
+	return func(o *options) {
+		o.config.Sinks = append(o.config.Sinks[:len(o.config.Sinks):len(o.config.Sinks)], sink)
+	}
+}
+
+// WithLevel sets the lowest level logged, see Config.Level.
+func WithLevel(level string) Option {
+	return func(o *options) {
+		o.config.Level = level
+	}
+}
+
+// WithFields adds fields to every entry.
+func WithFields(fields ...zap.Field) Option {
+	return func(o *options) {
+		o.config.Fields = append(o.config.Fields[:len(o.config.Fields):len(o.config.Fields)], fields...)
+	}
+}
+
+// WithClock sets the clock that timestamps entries, e.g. a fixed clock in
+// tests.
+func WithClock(clock zapcore.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithCaller records the file and line that logged each entry.
+func WithCaller(enabled bool) Option {
+	return func(o *options) {
+		o.caller = enabled
+	}
+}
+
+// WithSampling logs the first entries with the same level and message
+// each second, then only every thereafter-th of them, to bound the cost
+// of hot log statements.
+func WithSampling(first, thereafter int) Option {
+	return func(o *options) {
+		o.sampleFirst, o.sampleThereafter = first, thereafter
+	}
+}
+
+// WithErrorSamplingBoost stops sampling the entries of a named logger or
+// of a trace, told apart by the trace_id field, for window after it logs
+// an error, so what follows a failure is logged in full while sampling
+// keeps the steady state cheap.
+func WithErrorSamplingBoost(window time.Duration) Option {
+	return func(o *options) {
+		o.samplingBoost = window
+	}
+}
+
+// WithSamplingExemption logs every entry carrying the field key with one
+// of values, or with any value when none are given, despite sampling,
+// e.g. for full-fidelity logs of a single customer or debug session.
+func WithSamplingExemption(key string, values ...string) Option {
+	return func(o *options) {
+		o.samplingExemptions = append(o.samplingExemptions, SamplingExemption{Key: key, Values: values})
+	}
+}
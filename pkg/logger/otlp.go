@@ -0,0 +1,261 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OTLPConfig configures an exporter of entries as OpenTelemetry log records.
+type OTLPConfig struct {
+	// Endpoint is the OTLP/HTTP logs URL, default
+	// http://localhost:4318/v1/logs. Requests use the JSON encoding.
+	Endpoint string
+	Headers  map[string]string
+	Gzip     bool
+	Client   *http.Client
+
+	// Compression names the compressor of requests instead of Gzip, e.g.
+	// "zstd" in binaries built with the zstd tag.
+	Compression string
+
+	// Export, when set, replaces the HTTP request, e.g. to send over
+	// OTLP/gRPC: req is an ExportLogsServiceRequest in the OTLP JSON
+	// encoding, which protojson.Unmarshal turns into the collector's
+	// request type.
+	Export func(ctx context.Context, req []byte) error
+
+	// Resource attributes describe the process, e.g. service.name.
+	Resource map[string]string
+
+	Delivery DeliveryConfig
+}
+
+// NewOTLPSink returns a sink exporting entries to an OpenTelemetry
+// Collector or any OTLP endpoint. The message becomes the record body and
+// the level its severity; trace_id and span_id fields link the record to
+// its span, the error and stacktrace fields become exception.message and
+// exception.stacktrace, and all other fields become attributes.
+func NewOTLPSink(name string, cfg OTLPConfig) (*Delivery, error) {
+	encode := func(w io.Writer, batch [][]byte, keys entryKeys) error {
+		return writeOTLPLogs(w, batch, keys, cfg.Resource)
+	}
+	if cfg.Export != nil {
+		return NewDelivery(name, &otlpExportTransport{export: cfg.Export, encode: encode}, cfg.Delivery)
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "http://localhost:4318/v1/logs"
+	}
+	compressor, err := sinkCompressor(cfg.Compression, cfg.Gzip)
+	if err != nil {
+		return nil, fmt.Errorf("otlp sink: %w", err)
+	}
+	t := &httpTransport{
+		url:         cfg.Endpoint,
+		header:      make(http.Header),
+		compressor:  compressor,
+		client:      cfg.Client,
+		contentType: "application/json",
+		encode:      encode,
+	}
+	for k, v := range cfg.Headers {
+		t.header.Set(k, v)
+	}
+	return NewDelivery(name, t, cfg.Delivery)
+}
+
+type otlpExportTransport struct {
+	export func(ctx context.Context, req []byte) error
+	encode func(w io.Writer, batch [][]byte, keys entryKeys) error
+}
+
+func (t *otlpExportTransport) Send(ctx context.Context, batch [][]byte) error {
+	var req bytes.Buffer
+	if err := t.encode(&req, batch, entryKeysFrom(ctx)); err != nil {
+		return Permanent(err)
+	}
+	return t.export(ctx, req.Bytes())
+}
+
+func (t *otlpExportTransport) Close() error {
+	return nil
+}
+
+// OTLP JSON encoding of ExportLogsServiceRequest.
+type (
+	otlpRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano         string         `json:"timeUnixNano"`
+		ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+		SeverityNumber       int            `json:"severityNumber"`
+		SeverityText         string         `json:"severityText"`
+		Body                 otlpAnyValue   `json:"body"`
+		Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+		TraceID              string         `json:"traceId,omitempty"`
+		SpanID               string         `json:"spanId,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string        `json:"stringValue,omitempty"`
+		BoolValue   *bool          `json:"boolValue,omitempty"`
+		IntValue    *string        `json:"intValue,omitempty"`
+		DoubleValue *float64       `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpArray     `json:"arrayValue,omitempty"`
+		KvlistValue *otlpKeyValues `json:"kvlistValue,omitempty"`
+	}
+	otlpArray struct {
+		Values []otlpAnyValue `json:"values"`
+	}
+	otlpKeyValues struct {
+		Values []otlpKeyValue `json:"values"`
+	}
+)
+
+// otlpAttributeNames renames fields to OpenTelemetry semantic conventions.
+var otlpAttributeNames = map[string]string{
+	"error":      "exception.message",
+	"stacktrace": "exception.stacktrace",
+}
+
+func writeOTLPLogs(w io.Writer, batch [][]byte, keys entryKeys, resource map[string]string) error {
+	records := make([]otlpLogRecord, 0, len(batch))
+	for _, entry := range batch {
+		e, err := decodeEntry(entry, keys)
+		if err != nil {
+			continue
+		}
+		ts := strconv.FormatInt(e.Time.UnixNano(), 10)
+		rec := otlpLogRecord{
+			TimeUnixNano:         ts,
+			ObservedTimeUnixNano: ts,
+			SeverityNumber:       otlpSeverity(e.Level),
+			SeverityText:         strings.ToUpper(e.Level),
+			Body:                 otlpString(e.Message),
+		}
+		json.Unmarshal(e.Fields["trace_id"], &rec.TraceID)
+		json.Unmarshal(e.Fields["span_id"], &rec.SpanID)
+		delete(e.Fields, "trace_id")
+		delete(e.Fields, "span_id")
+		for _, key := range sortedKeys(e.Fields) {
+			name := key
+			if renamed, ok := otlpAttributeNames[key]; ok {
+				name = renamed
+			}
+			if v, ok := otlpValue(e.Fields[key]); ok {
+				rec.Attributes = append(rec.Attributes, otlpKeyValue{Key: name, Value: v})
+			}
+		}
+		records = append(records, rec)
+	}
+
+	res := otlpResource{Attributes: []otlpKeyValue{}}
+	names := make([]string, 0, len(resource))
+	for k := range resource {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		res.Attributes = append(res.Attributes, otlpKeyValue{Key: k, Value: otlpString(resource[k])})
+	}
+	req := otlpRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: res,
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: "github.com/intellectia/go-log"},
+			LogRecords: records,
+		}},
+	}}}
+	return json.NewEncoder(w).Encode(req)
+}
+
+// otlpSeverity maps level names to OpenTelemetry severity numbers.
+func otlpSeverity(level string) int {
+	sev, _ := severityOfName(level)
+	return sev.OTLP
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpValue converts a JSON value to an AnyValue; null has no equivalent.
+func otlpValue(raw json.RawMessage) (otlpAnyValue, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if dec.Decode(&v) != nil {
+		return otlpAnyValue{}, false
+	}
+	return otlpConvert(v)
+}
+
+func otlpConvert(v interface{}) (otlpAnyValue, bool) {
+	switch v := v.(type) {
+	case string:
+		return otlpString(v), true
+	case bool:
+		return otlpAnyValue{BoolValue: &v}, true
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			s := v.String()
+			return otlpAnyValue{IntValue: &s}, true
+		}
+		f, _ := v.Float64()
+		return otlpAnyValue{DoubleValue: &f}, true
+	case []interface{}:
+		arr := &otlpArray{Values: []otlpAnyValue{}}
+		for _, elem := range v {
+			if av, ok := otlpConvert(elem); ok {
+				arr.Values = append(arr.Values, av)
+			}
+		}
+		return otlpAnyValue{ArrayValue: arr}, true
+	case map[string]interface{}:
+		kv := &otlpKeyValues{Values: []otlpKeyValue{}}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if av, ok := otlpConvert(v[k]); ok {
+				kv.Values = append(kv.Values, otlpKeyValue{Key: k, Value: av})
+			}
+		}
+		return otlpAnyValue{KvlistValue: kv}, true
+	}
+	return otlpAnyValue{}, false
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,252 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/natefinch/lumberjack"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log paths with these prefixes name a unix socket instead of a file, e.g.
+// "unix:///run/vector.sock".
+const (
+	unixStreamPrefix = "unix://"
+	unixgramPrefix   = "unixgram://"
+)
+
+// reconnectDelay is the minimum time between attempts to reopen a socket
+// or named pipe. Entries written in between are dropped.
+const reconnectDelay = time.Second
+
+var errOutputDown = errors.New("output not connected")
+
+// newLogWriter returns the writer for a log path: a unix socket for the
+// unix:// and unixgram:// prefixes, the pipe itself for an existing named
+// pipe, and otherwise a file created and rotated according to the
+// permissions, rotation, compression and checksum settings: by lumberjack,
+// or as a shared file with file sharing.
+func newLogWriter(path string, settings settings) logWriter {
+	switch {
+	case strings.HasPrefix(path, unixStreamPrefix):
+		return &reconnectWriter{dial: dialer("unix", strings.TrimPrefix(path, unixStreamPrefix))}
+	case strings.HasPrefix(path, unixgramPrefix):
+		return &reconnectWriter{dial: dialer("unixgram", strings.TrimPrefix(path, unixgramPrefix)), datagram: true}
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		return &reconnectWriter{dial: openPipe(path)}
+	}
+	path = logFilePath(path)
+	rot, perm := settings.rotation, settings.permissions
+	if settings.fileSharing != "" {
+		f := newSharedFile(path, settings.fileSharing, rot, perm)
+		settings.equipMill(f.mill)
+		f.mill.start()
+		return f
+	}
+	prepareLogFile(path, perm)
+	f := rotatingFile{
+		Logger: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    rot.megabytes,
+			MaxBackups: rot.backups,
+			MaxAge:     rot.days,
+		},
+		perm: perm,
+	}
+	if settings.compression != "" || settings.checksums {
+		f.mill = newBackupMill(path, rot, perm)
+		settings.equipMill(f.mill)
+		f.mill.start()
+	}
+	return f
+}
+
+// equipMill gives m the compressor and checksum manifest of the settings.
+func (s settings) equipMill(m *backupMill) {
+	if s.compression != "" {
+		// New checked the name.
+		m.compressor, _ = compressorNamed(s.compression)
+	}
+	if s.checksums {
+		m.manifest = newChecksumManifest(m.path, m.perm, m.compressedExt())
+	}
+}
+
+// rotation is when log files are rotated: once they reach megabytes,
+// keeping backups old files for at most days.
+type rotation struct {
+	megabytes, backups, days int
+}
+
+var defaultRotation = rotation{megabytes: 500, backups: 3, days: 28}
+
+// logWriter is a log file or socket that Logger.Close releases.
+type logWriter interface {
+	zapcore.WriteSyncer
+	io.Closer
+}
+
+// rotatingFile is a lumberjack file; its writes go straight to the file,
+// so there is nothing to sync.
+type rotatingFile struct {
+	*lumberjack.Logger
+	perm FilePermissions
+	mill *backupMill // nil without compression or checksums
+}
+
+func (f rotatingFile) Write(p []byte) (int, error) {
+	var n int
+	// On Windows, rotating fails while another process has the file open
+	// without sharing deletion; lumberjack rotates before writing.
+	err := retryShared(func() (err error) {
+		n, err = f.Logger.Write(p)
+		return err
+	})
+	if f.mill != nil && err == nil {
+		f.mill.wrote(n)
+	}
+	return n, err
+}
+
+// reopen closes the file; lumberjack opens the file at its path again on
+// the next write, creating it with perm if it was moved away.
+func (f rotatingFile) reopen() error {
+	err := f.Logger.Close()
+	prepareLogFile(f.Filename, f.perm)
+	if f.mill != nil {
+		f.mill.reopened()
+	}
+	return err
+}
+
+func (rotatingFile) Sync() error {
+	return nil
+}
+
+// fsync flushes the current file to disk. lumberjack keeps its file to
+// itself, so the file is opened again; fsync applies to the file rather
+// than the descriptor.
+func (f rotatingFile) fsync() error {
+	file, err := os.OpenFile(f.Filename, os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return multierr.Combine(file.Sync(), file.Close())
+}
+
+// fsyncFunc returns the fsync of w, nil unless it is a file.
+func fsyncFunc(w logWriter) func() error {
+	switch f := w.(type) {
+	case rotatingFile:
+		if f.Filename != "" {
+			return f.fsync
+		}
+	case *sharedFile:
+		return f.fsync
+	}
+	return nil
+}
+
+func dialer(network, addr string) func() (io.WriteCloser, error) {
+	return func() (io.WriteCloser, error) {
+		return net.DialTimeout(network, addr, reconnectDelay)
+	}
+}
+
+// openPipe opens a named pipe without blocking, failing while no reader
+// has it open.
+func openPipe(path string) func() (io.WriteCloser, error) {
+	return func() (io.WriteCloser, error) {
+		return os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	}
+}
+
+// reconnectWriter writes to a socket or pipe, reopening it when a write
+// fails because the reader went away or restarted. Each entry is written
+// with a single Write so datagram sockets receive one entry per message.
+type reconnectWriter struct {
+	mu       sync.Mutex
+	dial     func() (io.WriteCloser, error)
+	datagram bool // a unixgram socket
+	conn     io.WriteCloser
+	lastDial time.Time
+}
+
+func (w *reconnectWriter) datagrams() bool {
+	return w.datagram
+}
+
+func (w *reconnectWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+		n, err := w.conn.Write(p)
+		if err == nil {
+			return n, nil
+		}
+		w.conn.Close()
+		w.conn = nil
+		if attempt > 0 || n > 0 {
+			return n, err
+		}
+	}
+	return 0, errOutputDown
+}
+
+func (w *reconnectWriter) connect() error {
+	if w.conn != nil {
+		return nil
+	}
+	if time.Since(w.lastDial) < reconnectDelay {
+		return errOutputDown
+	}
+	w.lastDial = time.Now()
+	conn, err := w.dial()
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// reopen drops the connection; the next write dials again.
+func (w *reconnectWriter) reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	w.lastDial = time.Time{}
+	return err
+}
+
+func (w *reconnectWriter) Sync() error {
+	return nil
+}
+
+func (w *reconnectWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
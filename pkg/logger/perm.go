@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FilePermissions sets the mode and owner of log files and of the
+// directories created for them. lumberjack gives each new file the mode
+// and owner of the file it rotates out, so they carry over to the backups.
+// The zero value keeps lumberjack's defaults of 0644 files in 0744
+// directories owned by the process.
+type FilePermissions struct {
+	Mode    os.FileMode
+	DirMode os.FileMode
+
+	// UID and GID, when not zero, own the files and created directories.
+	UID, GID int
+}
+
+// prepare creates the log file at path and its directories with p.
+func (p FilePermissions) prepare(path string) error {
+	if p == (FilePermissions{}) {
+		return nil
+	}
+	dirMode := p.DirMode
+	if dirMode == 0 {
+		dirMode = 0744
+	}
+	var created []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		created = append(created, dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return err
+	}
+	for _, dir := range created {
+		// Chmod because MkdirAll is subject to the umask.
+		if err := os.Chmod(dir, dirMode); err != nil {
+			return err
+		}
+		if err := p.chown(dir); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, p.fileMode())
+	if err != nil {
+		return err
+	}
+	f.Close()
+	if p.Mode != 0 {
+		if err := os.Chmod(path, p.Mode); err != nil {
+			return err
+		}
+	}
+	return p.chown(path)
+}
+
+// fileMode is the mode of new log files.
+func (p FilePermissions) fileMode() os.FileMode {
+	if p.Mode == 0 {
+		return 0644
+	}
+	return p.Mode
+}
+
+func (p FilePermissions) chown(path string) error {
+	if p.UID == 0 && p.GID == 0 {
+		return nil
+	}
+	uid, gid := p.UID, p.GID
+	if uid == 0 {
+		uid = -1
+	}
+	if gid == 0 {
+		gid = -1
+	}
+	return os.Chown(path, uid, gid)
+}
+
+// prepareLogFile applies perm to a log file, reporting failures on stderr
+// since the logger itself cannot write yet.
+func prepareLogFile(path string, perm FilePermissions) {
+	if err := perm.prepare(path); err != nil {
+		fmt.Fprintf(os.Stderr, "logger: %s: %v\n", path, err)
+	}
+}
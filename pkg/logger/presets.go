@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"bytes"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewDevelopment returns a logger for local development: human-readable
+// debug output with callers on the console, entry validation and no
+// files. opts are applied after the preset.
+func NewDevelopment(opts ...Option) (*Logger, error) {
+	return New(append([]Option{
+		WithConfig(Config{
+			Mode:                     ModeDevelopment,
+			DisableFiles:             true,
+			DisableResourceDetection: true,
+		}),
+		WithLevel("debug"),
+		WithCaller(true),
+	}, opts...)...)
+}
+
+// NewProduction returns a logger for services: JSON on the console at info
+// level with callers, resource and build fields, and sampling of repeated
+// entries. opts are applied after the preset, e.g. WithFiles.
+func NewProduction(opts ...Option) (*Logger, error) {
+	return New(append([]Option{
+		WithConfig(Config{
+			Mode:         ModeProduction,
+			DisableFiles: true,
+			ConsoleJSON:  true,
+			BuildInfo:    true,
+		}),
+		WithLevel("info"),
+		WithCaller(true),
+		WithSampling(100, 100),
+	}, opts...)...)
+}
+
+// NewServerless returns a logger for functions as a service such as AWS
+// Lambda or Cloud Functions: JSON written straight to stdout at info
+// level, with no files, no buffering or flush timer and no platform
+// detection, whose fields the platform records itself. Run each
+// invocation through Logger.Invoke so its entries are tagged and flushed
+// before the function is frozen.
+func NewServerless(opts ...Option) (*Logger, error) {
+	return New(append([]Option{
+		WithConfig(Config{
+			Mode:                     ModeProduction,
+			DisableFiles:             true,
+			ConsoleJSON:              true,
+			StderrLevel:              "off",
+			DisableResourceDetection: true,
+		}),
+		WithLevel("info"),
+		func(o *options) { o.unbufferedConsole = true },
+	}, opts...)...)
+}
+
+// TestingT is the part of testing.TB that NewTesting uses.
+type TestingT interface {
+	Logf(format string, args ...interface{})
+	Helper()
+}
+
+// NewTesting returns a logger printing every entry through t.Logf, so the
+// output is shown with the test that produced it.
+func NewTesting(t TestingT) *Logger {
+	encoderConfig := zap.NewDevelopmentEncoderConfig()
+	enc := newFoldingEncoder(zapcore.NewConsoleEncoder(encoderConfig))
+	level := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	core := newOutputCore(enc, testingWriter{t}, level)
+	return &Logger{zap: zap.New(callerCore{core}), level: level}
+}
+
+type testingWriter struct {
+	t TestingT
+}
+
+func (w testingWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Logf("%s", bytes.TrimSuffix(p, []byte("\n")))
+	return len(p), nil
+}
+
+func (w testingWriter) Sync() error {
+	return nil
+}
@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Policies for the printf-style methods in Config.Printf.
+const (
+	PrintfAllow  = "allow"  // format and log as usual
+	PrintfFlag   = "flag"   // log with printf set, so the entries can be found
+	PrintfReject = "reject" // log a warning with the format instead
+)
+
+// printfFields returns the fields a printf-style entry with format carries
+// under the printf policy, or false when the entry is rejected.
+func (l *Logger) printfFields(format string) ([]zap.Field, bool) {
+	switch strings.ToLower(l.printf) {
+	case PrintfFlag:
+		return []zap.Field{zap.Bool("printf", true)}, true
+	case PrintfReject:
+		l.zap.Warn("printf-style logging rejected", zap.String("format", format))
+		return nil, false
+	}
+	return nil, true
+}
@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// progressBarInterval is how often a progress bar is redrawn at most.
+const progressBarInterval = 100 * time.Millisecond
+
+// hideFromConsole is a field marking entries that the console skips
+// because it shows them as a progress bar; encoders skip it.
+var hideFromConsole = zap.Field{Key: "hide_from_console_marker", Type: zapcore.SkipType}
+
+func hiddenFromConsole(fields []zapcore.Field) bool {
+	for _, f := range fields {
+		if f.Key == hideFromConsole.Key && f.Type == zapcore.SkipType {
+			return true
+		}
+	}
+	return false
+}
+
+// ProgressTracker reports the progress of a long-running job of known
+// size, such as an import, as "processed N/M (x%) eta T" entries at most
+// once per interval. Where the logger draws status lines on a terminal,
+// in development mode or for NewCLILogger, the console shows a live
+// progress bar instead and the entries only go to files and sinks.
+type ProgressTracker struct {
+	l        *Logger
+	name     string
+	total    int64
+	interval time.Duration
+	start    time.Time
+	done     atomic.Int64
+
+	mu       sync.Mutex
+	logged   time.Time
+	drawn    time.Time
+	finished bool
+}
+
+// TrackProgress starts tracking name, a job of total items, logging its
+// progress every interval.
+func (l *Logger) TrackProgress(name string, total int64, interval time.Duration) *ProgressTracker {
+	now := time.Now()
+	return &ProgressTracker{l: l, name: name, total: total, interval: interval, start: now, logged: now}
+}
+
+// Add records n more processed items.
+func (p *ProgressTracker) Add(n int64) {
+	p.report(p.done.Add(n))
+}
+
+// Set records the number of processed items.
+func (p *ProgressTracker) Set(done int64) {
+	p.done.Store(done)
+	p.report(done)
+}
+
+// Finish logs that the job ended, with the items processed and the time
+// it took, and removes the progress bar.
+func (p *ProgressTracker) Finish() {
+	p.mu.Lock()
+	if p.finished {
+		p.mu.Unlock()
+		return
+	}
+	p.finished = true
+	p.mu.Unlock()
+	done := p.done.Load()
+	elapsed := time.Since(p.start)
+	p.l.Info(fmt.Sprintf("%s: processed %d/%d in %s", p.name, done, p.total, elapsed.Round(time.Millisecond)),
+		zap.String("progress", p.name),
+		zap.Int64("done", done),
+		zap.Int64("total", p.total),
+		zap.Duration("elapsed", elapsed),
+	)
+}
+
+func (p *ProgressTracker) report(done int64) {
+	now := time.Now()
+	bar := p.l.progress != nil && p.l.progress.tty
+	p.mu.Lock()
+	if p.finished {
+		p.mu.Unlock()
+		return
+	}
+	logEntry := now.Sub(p.logged) >= p.interval
+	if logEntry {
+		p.logged = now
+	}
+	draw := bar && now.Sub(p.drawn) >= progressBarInterval
+	if draw {
+		p.drawn = now
+	}
+	p.mu.Unlock()
+
+	elapsed := now.Sub(p.start)
+	percent, eta := p.estimate(done, elapsed)
+	if draw {
+		p.l.progress.line.show([]byte(p.bar(done, percent, eta)))
+	}
+	if !logEntry {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("progress", p.name),
+		zap.Int64("done", done),
+		zap.Int64("total", p.total),
+		zap.Float64("percent", percent),
+		zap.Duration("elapsed", elapsed),
+		zap.Duration("eta", eta),
+	}
+	if bar {
+		fields = append(fields, hideFromConsole)
+	}
+	p.l.Info(fmt.Sprintf("%s: processed %d/%d (%.1f%%) eta %s", p.name, done, p.total, percent, eta), fields...)
+}
+
+// estimate returns the share done and the time left at the average rate
+// so far.
+func (p *ProgressTracker) estimate(done int64, elapsed time.Duration) (percent float64, eta time.Duration) {
+	if p.total <= 0 || done <= 0 {
+		return 0, 0
+	}
+	percent = float64(done) / float64(p.total) * 100
+	if done < p.total {
+		eta = time.Duration(float64(elapsed) * float64(p.total-done) / float64(done)).Round(time.Second)
+	}
+	return percent, eta
+}
+
+// bar renders the progress bar, e.g.
+// "import [=========>          ]  45% 450/1000 eta 12s".
+func (p *ProgressTracker) bar(done int64, percent float64, eta time.Duration) string {
+	const width = 30
+	filled := int(percent / 100 * width)
+	if filled > width {
+		filled = width
+	}
+	var b strings.Builder
+	b.WriteString(p.name)
+	b.WriteString(" [")
+	b.WriteString(strings.Repeat("=", filled))
+	if filled < width {
+		b.WriteString(">")
+		b.WriteString(strings.Repeat(" ", width-filled-1))
+	}
+	fmt.Fprintf(&b, "] %3.0f%% %d/%d eta %s", percent, done, p.total, eta)
+	return b.String()
+}
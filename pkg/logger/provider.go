@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldProvider supplies fields whose values change over time, such as
+// memory usage, the number of active requests or a feature flag snapshot.
+// Fields is called for every entry written, so providers should be cheap
+// or wrapped with RefreshEvery.
+type FieldProvider interface {
+	Fields() []zap.Field
+}
+
+// FieldProviderFunc adapts a function to a FieldProvider.
+type FieldProviderFunc func() []zap.Field
+
+func (f FieldProviderFunc) Fields() []zap.Field {
+	return f()
+}
+
+// RefreshEvery returns a provider that calls p at most once per interval
+// and reuses its fields in between.
+func RefreshEvery(interval time.Duration, p FieldProvider) FieldProvider {
+	return &cachedProvider{p: p, interval: interval}
+}
+
+type cachedProvider struct {
+	p        FieldProvider
+	interval time.Duration
+
+	mu      sync.Mutex
+	fields  []zap.Field
+	expires time.Time
+}
+
+func (c *cachedProvider) Fields() []zap.Field {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); !now.Before(c.expires) {
+		c.fields = c.p.Fields()
+		c.expires = now.Add(c.interval)
+	}
+	return c.fields
+}
+
+// providerCore adds the fields of its providers to every entry it writes.
+type providerCore struct {
+	zapcore.Core
+	providers []FieldProvider
+}
+
+func newProviderCore(core zapcore.Core, providers []FieldProvider) zapcore.Core {
+	return &providerCore{Core: core, providers: providers}
+}
+
+func (c *providerCore) With(fields []zapcore.Field) zapcore.Core {
+	return &providerCore{Core: c.Core.With(fields), providers: c.providers}
+}
+
+func (c *providerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *providerCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(c.withProvided(fields)...)
+	}
+	return nil
+}
+
+func (c *providerCore) writeBatch(entries []batchEntry) error {
+	provided := make([]batchEntry, len(entries))
+	for i, e := range entries {
+		provided[i] = batchEntry{ent: e.ent, fields: c.withProvided(e.fields)}
+	}
+	return writeBatch(c.Core, provided)
+}
+
+func (c *providerCore) withProvided(fields []zapcore.Field) []zapcore.Field {
+	fields = fields[:len(fields):len(fields)]
+	for _, p := range c.providers {
+		fields = append(fields, p.Fields()...)
+	}
+	return fields
+}
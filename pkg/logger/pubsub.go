@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// PubSubMessage is one entry as a Google Cloud Pub/Sub message. The entry's
+// level and its Cloud Logging severity are set as the "level" and
+// "severity" attributes so subscriptions can filter on them.
+type PubSubMessage struct {
+	Data        []byte
+	OrderingKey string
+	Attributes  map[string]string
+}
+
+// PubSubConfig configures a sink publishing every entry to a Pub/Sub topic.
+// Publish starts publishing msg and returns a function that waits for the
+// result; with cloud.google.com/go/pubsub:
+//
+//	Publish: func(ctx context.Context, m logger.PubSubMessage) func(context.Context) error {
+//		res := topic.Publish(ctx, &pubsub.Message{Data: m.Data, OrderingKey: m.OrderingKey, Attributes: m.Attributes})
+//		return func(ctx context.Context) error {
+//			_, err := res.Get(ctx)
+//			return err
+//		}
+//	}
+//
+// The topic must have message ordering enabled for ordering keys to apply.
+type PubSubConfig struct {
+	Publish func(ctx context.Context, msg PubSubMessage) func(context.Context) error
+
+	// OrderingKeyField names a string field, e.g. "trace_id", whose value
+	// becomes the ordering key. Entries without it are published unordered.
+	OrderingKeyField string
+
+	// Flow control: publishing waits for earlier results once this many
+	// messages or bytes are outstanding. Defaults to 1000 and 10MB.
+	MaxOutstandingMessages int
+	MaxOutstandingBytes    int
+
+	Delivery DeliveryConfig
+}
+
+// NewPubSubSink returns a sink publishing to a Pub/Sub topic.
+func NewPubSubSink(name string, cfg PubSubConfig) (*Delivery, error) {
+	if cfg.Publish == nil {
+		return nil, errors.New("pubsub sink: no publish function")
+	}
+	if cfg.MaxOutstandingMessages <= 0 {
+		cfg.MaxOutstandingMessages = 1000
+	}
+	if cfg.MaxOutstandingBytes <= 0 {
+		cfg.MaxOutstandingBytes = 10 << 20
+	}
+	return NewDelivery(name, &pubSubTransport{cfg: cfg}, cfg.Delivery)
+}
+
+type pubSubTransport struct {
+	cfg PubSubConfig
+}
+
+type pubSubResult struct {
+	wait func(context.Context) error
+	size int
+}
+
+// Send publishes the batch under flow control and waits for every result,
+// so a failed message resends the whole batch.
+func (t *pubSubTransport) Send(ctx context.Context, batch [][]byte) error {
+	var (
+		pending []pubSubResult
+		bytes   int
+		err     error
+	)
+	waitOldest := func() {
+		r := pending[0]
+		pending = pending[1:]
+		bytes -= r.size
+		if werr := r.wait(ctx); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	keys := entryKeysFrom(ctx)
+	for _, entry := range batch {
+		for len(pending) > 0 && (len(pending) >= t.cfg.MaxOutstandingMessages ||
+			bytes+len(entry) > t.cfg.MaxOutstandingBytes) {
+			waitOldest()
+		}
+		if err != nil {
+			break
+		}
+		wait := t.cfg.Publish(ctx, t.message(entry, keys))
+		pending = append(pending, pubSubResult{wait: wait, size: len(entry)})
+		bytes += len(entry)
+	}
+	for len(pending) > 0 {
+		waitOldest()
+	}
+	return err
+}
+
+func (t *pubSubTransport) message(entry []byte, keys entryKeys) PubSubMessage {
+	msg := PubSubMessage{Data: entry}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(entry, &fields) != nil {
+		return msg
+	}
+	var level string
+	if json.Unmarshal(fields[keys.Level], &level) == nil && level != "" {
+		msg.Attributes = map[string]string{"level": level}
+		if sev, ok := severityOfName(level); ok {
+			msg.Attributes["severity"] = sev.GCP
+		}
+	}
+	if t.cfg.OrderingKeyField != "" {
+		json.Unmarshal(fields[t.cfg.OrderingKeyField], &msg.OrderingKey)
+	}
+	return msg
+}
+
+func (t *pubSubTransport) Close() error {
+	return nil
+}
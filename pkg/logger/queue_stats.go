@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// QueueStats are the internals of an asynchronous output, to export as
+// metrics and alert before its queue overflows.
+type QueueStats struct {
+	Output   string // e.g. "sink loki"
+	Depth    int    // entries waiting
+	Capacity int    // entries the queue holds before it drops or spills, 0 for a WAL
+
+	// OldestAge is how long the oldest waiting entry has waited; for a WAL
+	// it is the time since the queue was last empty or a batch delivered.
+	OldestAge time.Duration
+
+	// FlushLatency counts the durations of the attempts to send a batch.
+	FlushLatency LatencyHistogram
+}
+
+func (s QueueStats) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("output", s.Output)
+	enc.AddInt("depth", s.Depth)
+	if s.Capacity > 0 {
+		enc.AddInt("capacity", s.Capacity)
+	}
+	enc.AddDuration("oldest_age", s.OldestAge)
+	enc.AddUint64("flushes", s.FlushLatency.Count)
+	enc.AddDuration("flush_p50", s.FlushLatency.Quantile(0.5))
+	enc.AddDuration("flush_p99", s.FlushLatency.Quantile(0.99))
+	return nil
+}
+
+// queueReporter is implemented by asynchronous sinks, such as Delivery.
+type queueReporter interface {
+	QueueStats() QueueStats
+}
+
+// QueueStats returns the internals of the asynchronous sinks of the
+// logger. Like Usage, it is a snapshot to poll and export as metrics, e.g.
+// queue depth and oldest entry age as gauges and flush latency as a
+// histogram.
+func (l *Logger) QueueStats() []QueueStats {
+	var stats []QueueStats
+	for _, o := range l.outputs {
+		if r, ok := o.sink.(queueReporter); ok {
+			s := r.QueueStats()
+			s.Output = o.name
+			stats = append(stats, s)
+		}
+	}
+	return stats
+}
+
+// latencyBounds are the upper bounds of the buckets of a LatencyHistogram,
+// doubling from 1ms to about 33s.
+var latencyBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 16)
+	for i := range bounds {
+		bounds[i] = time.Millisecond << i
+	}
+	return bounds
+}()
+
+// LatencyHistogram counts durations in buckets. Counts[i] is the number of
+// durations up to Bounds[i] and above the bound before; the last count,
+// Counts[len(Bounds)], is of those above every bound.
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Quantile returns the upper bound of the bucket holding the q quantile,
+// from 0 to 1, and the largest bound when it is past them all.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.Count))
+	var seen uint64
+	for i, n := range h.Counts {
+		seen += n
+		if seen > rank && i < len(h.Bounds) {
+			return h.Bounds[i]
+		}
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// latencyRecorder fills a LatencyHistogram without locking.
+type latencyRecorder struct {
+	counts [17]uint64 // one more than latencyBounds
+	count  uint64
+	sum    int64
+}
+
+func (r *latencyRecorder) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&r.counts[i], 1)
+	atomic.AddUint64(&r.count, 1)
+	atomic.AddInt64(&r.sum, int64(d))
+}
+
+func (r *latencyRecorder) snapshot() LatencyHistogram {
+	h := LatencyHistogram{
+		Bounds: append([]time.Duration(nil), latencyBounds...),
+		Counts: make([]uint64, len(r.counts)),
+		Count:  atomic.LoadUint64(&r.count),
+		Sum:    time.Duration(atomic.LoadInt64(&r.sum)),
+	}
+	for i := range r.counts {
+		h.Counts[i] = atomic.LoadUint64(&r.counts[i])
+	}
+	return h
+}
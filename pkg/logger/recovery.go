@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// recoveryBodyBytes is how much of the request body a panic report
+// includes.
+const recoveryBodyBytes = 2048
+
+// RecoveryMiddleware recovers panics in next using the global logger.
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logInstance.Load().RecoveryMiddleware(next).ServeHTTP(w, r)
+	})
+}
+
+// RecoveryMiddleware recovers panics in next, logs them with the stack
+// and a snapshot of the request, and answers 500 Internal Server Error
+// unless next had started its response. The snapshot has the method,
+// path, query, headers with credentials redacted and the first 2KB of the
+// body. http.ErrAbortHandler is passed on. With echo it can be installed
+// through echo.WrapMiddleware.
+func (l *Logger) RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &bodySnapshot{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(v)
+			}
+			l.Ctx(r.Context()).zap.Error("panic recovered",
+				zap.String("panic", fmt.Sprint(v)),
+				zap.String("stacktrace", captureStack()),
+				requestSnapshot(r, body),
+			)
+			// Past the header, the response can only be cut short.
+			if !rec.wroteHeader {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// bodySnapshot keeps the first recoveryBodyBytes read from a body.
+type bodySnapshot struct {
+	io.ReadCloser
+	buf bytes.Buffer
+}
+
+func (b *bodySnapshot) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := recoveryBodyBytes - b.buf.Len(); room > 0 {
+		if n < room {
+			room = n
+		}
+		b.buf.Write(p[:room])
+	}
+	return n, err
+}
+
+// snapshot returns the start of the body, reading what the handler had
+// not read yet up to the limit.
+func (b *bodySnapshot) snapshot() []byte {
+	if b.ReadCloser != nil && b.buf.Len() < recoveryBodyBytes {
+		io.CopyN(io.Discard, b, int64(recoveryBodyBytes-b.buf.Len()))
+	}
+	return b.buf.Bytes()
+}
+
+func requestSnapshot(r *http.Request, body *bodySnapshot) zap.Field {
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	headers := make([]zap.Field, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(r.Header[name], ", ")
+		if isSensitiveHeader(name) {
+			value = "[REDACTED]"
+		}
+		headers = append(headers, zap.String(name, value))
+	}
+	return Namespace("request",
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("query", r.URL.RawQuery),
+		Namespace("headers", headers...),
+		zap.ByteString("body", body.snapshot()),
+	)
+}
+
+// isSensitiveHeader reports whether a header may carry credentials.
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"authorization", "cookie", "token", "secret", "password", "api-key", "apikey"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// reopener is implemented by log writers that can release and reacquire
+// their file or connection.
+type reopener interface {
+	reopen() error
+}
+
+// Reopen closes the log files and sockets, which are opened again at their
+// paths on the next write. External tools such as logrotate call it, or
+// send the signal of ReopenOnSignal, after moving the files away; they
+// should then rotate instead of lumberjack, e.g. with WithRotation set far
+// above the sizes logrotate keeps the files at.
+func (l *Logger) Reopen() error {
+	var errs []error
+	for _, o := range l.outputs {
+		if o.reopen == nil {
+			continue
+		}
+		if err := o.reopen(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: reopen: %w", o.name, err))
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// Reopen reopens the log files of the global logger.
+func Reopen() error {
+	return logInstance.Load().Reopen()
+}
+
+// ReopenOnSignal calls Reopen whenever the process receives one of sigs,
+// SIGUSR1 by default, as logrotate's postrotate scripts expect. It returns
+// a function that stops handling the signals.
+func (l *Logger) ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = reopenSignals
+	}
+	if len(sigs) == 0 {
+		return func() {}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ch:
+				if err := l.Reopen(); err != nil {
+					l.Warn("reopening log files failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// reopenFunc returns the reopen of w, nil if it has none.
+func reopenFunc(w logWriter) func() error {
+	if r, ok := w.(reopener); ok {
+		return r.reopen
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+package logger
+
+import "os"
+
+// Windows has no SIGUSR1; ReopenOnSignal needs the signals passed in.
+var reopenSignals []os.Signal
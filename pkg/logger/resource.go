@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ResourceDetector finds fields describing where the process runs, named
+// after the OpenTelemetry resource conventions. Detectors return no fields
+// when they do not recognize the environment.
+type ResourceDetector interface {
+	Detect(ctx context.Context) ([]zap.Field, error)
+}
+
+// ResourceDetectorFunc adapts a function to a ResourceDetector.
+type ResourceDetectorFunc func(ctx context.Context) ([]zap.Field, error)
+
+func (f ResourceDetectorFunc) Detect(ctx context.Context) ([]zap.Field, error) {
+	return f(ctx)
+}
+
+// resourceDetectTimeout bounds the time all detectors spend on metadata
+// endpoints while the logger is built.
+const resourceDetectTimeout = 2 * time.Second
+
+// DefaultResourceDetectors returns the detectors used when
+// Config.ResourceDetectors is nil. Each only queries a metadata endpoint
+// once the environment points to its platform.
+func DefaultResourceDetectors() []ResourceDetector {
+	return []ResourceDetector{
+		ResourceDetectorFunc(DetectKubernetes),
+		ResourceDetectorFunc(DetectECS),
+		ResourceDetectorFunc(DetectGCE),
+	}
+}
+
+// DetectResource runs detectors in order and returns the fields they found.
+// Failing detectors are skipped.
+func DetectResource(ctx context.Context, detectors ...ResourceDetector) []zap.Field {
+	ctx, cancel := context.WithTimeout(ctx, resourceDetectTimeout)
+	defer cancel()
+	var fields []zap.Field
+	for _, d := range detectors {
+		found, err := d.Detect(ctx)
+		if err != nil {
+			continue
+		}
+		fields = append(fields, found...)
+	}
+	return fields
+}
+
+// DetectECS reports the task, cluster and availability zone from the ECS
+// task metadata endpoint.
+func DetectECS(ctx context.Context) ([]zap.Field, error) {
+	endpoint := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+	if endpoint == "" {
+		return nil, nil
+	}
+	body, err := getMetadata(ctx, endpoint+"/task", nil)
+	if err != nil {
+		return nil, err
+	}
+	var task struct {
+		Cluster          string
+		TaskARN          string
+		Family           string
+		Revision         string
+		AvailabilityZone string
+	}
+	if err := json.Unmarshal(body, &task); err != nil {
+		return nil, err
+	}
+	fields := []zap.Field{
+		zap.String("cloud.provider", "aws"),
+		zap.String("cloud.platform", "aws_ecs"),
+		zap.String("aws.ecs.task.arn", task.TaskARN),
+		zap.String("aws.ecs.task.family", task.Family),
+		zap.String("aws.ecs.task.revision", task.Revision),
+		zap.String("aws.ecs.cluster.arn", task.Cluster),
+	}
+	// arn:aws:ecs:<region>:<account>:task/...
+	if parts := strings.SplitN(task.TaskARN, ":", 5); len(parts) == 5 {
+		fields = append(fields, zap.String("cloud.region", parts[3]))
+	}
+	if task.AvailabilityZone != "" {
+		fields = append(fields, zap.String("cloud.availability_zone", task.AvailabilityZone))
+	}
+	return fields, nil
+}
+
+const gceProductName = "/sys/class/dmi/id/product_name"
+
+// DetectGCE reports the project, instance, region and zone from the Compute
+// Engine metadata server.
+func DetectGCE(ctx context.Context) ([]zap.Field, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		product, err := os.ReadFile(gceProductName)
+		if err != nil || !strings.Contains(string(product), "Google") {
+			return nil, nil
+		}
+		host = "metadata.google.internal"
+	}
+	get := func(path string) (string, error) {
+		b, err := getMetadata(ctx, "http://"+host+"/computeMetadata/v1/"+path,
+			map[string]string{"Metadata-Flavor": "Google"})
+		return strings.TrimSpace(string(b)), err
+	}
+	project, err := get("project/project-id")
+	if err != nil {
+		return nil, err
+	}
+	id, err := get("instance/id")
+	if err != nil {
+		return nil, err
+	}
+	// projects/<number>/zones/<zone>
+	zone, err := get("instance/zone")
+	if err != nil {
+		return nil, err
+	}
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	fields := []zap.Field{
+		zap.String("cloud.provider", "gcp"),
+		zap.String("cloud.platform", "gcp_compute_engine"),
+		zap.String("cloud.account.id", project),
+		zap.String("host.id", id),
+		zap.String("cloud.availability_zone", zone),
+	}
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		fields = append(fields, zap.String("cloud.region", zone[:i]))
+	}
+	return fields, nil
+}
+
+func getMetadata(ctx context.Context, url string, header map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
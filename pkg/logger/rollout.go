@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"hash/fnv"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// VerboseRollout lowers the level on a deterministic sample of hosts, e.g.
+// debug on 5% of a fleet, so detailed logs are available without every
+// instance producing them. A host is in the sample when a hash of its
+// name and Seed falls within Percent; it stays in as long as Percent does
+// not shrink, and other hosts join as it grows.
+type VerboseRollout struct {
+	// Percent of hosts, from 0 to 100.
+	Percent float64
+
+	// Level on the hosts in the sample, "debug" by default. It only
+	// applies where it is lower than Config.Level.
+	Level string
+
+	// Seed picks a different sample, e.g. the service name so services
+	// sharing hosts are not all verbose on the same ones.
+	Seed string
+}
+
+// level returns the level for this host given the base level.
+func (r VerboseRollout) level(base zapcore.Level) zapcore.Level {
+	if r.Percent <= 0 {
+		return base
+	}
+	host, err := os.Hostname()
+	if err != nil || !inRollout(host, r.Seed, r.Percent) {
+		return base
+	}
+	lvl := zapcore.DebugLevel
+	if r.Level != "" {
+		if parsed, err := ParseLevel(r.Level); err == nil {
+			lvl = parsed
+		}
+	}
+	if lvl < base {
+		return lvl
+	}
+	return base
+}
+
+// inRollout reports whether host falls within percent of hosts.
+func inRollout(host, seed string, percent float64) bool {
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	h.Write([]byte{0})
+	h.Write([]byte(host))
+	return float64(h.Sum64()%10000) < percent*100
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Run is one run of a scheduled job, such as a cron task. It is a logger
+// whose entries carry the job name and a run_id unique to the run, so all
+// the lines of a run can be found together.
+type Run struct {
+	*Logger
+	ID    string
+	Job   string
+	start time.Time
+	once  sync.Once
+}
+
+// ForRun starts a run of job using the global logger.
+func ForRun(job string) *Run {
+	return logInstance.Load().ForRun(job)
+}
+
+// ForRun starts a run of job with a new run ID and logs "run started".
+// The caller must call End:
+//
+//	run := log.ForRun("nightly-report")
+//	err := buildReport(run.Logger)
+//	run.End(err)
+func (l *Logger) ForRun(job string) *Run {
+	r := &Run{ID: randomHex(8), Job: job, start: time.Now()}
+	r.Logger = l.With(zap.String("job", job), zap.String("run_id", r.ID))
+	r.Info("run started")
+	return r
+}
+
+// End logs the outcome of the run, "success" or "failure" with err, and
+// its duration. Only the first call logs.
+func (r *Run) End(err error, fields ...zap.Field) {
+	r.once.Do(func() {
+		fields = append(fields[:len(fields):len(fields)], zap.Duration("duration", time.Since(r.start)))
+		if err != nil {
+			r.Error("run failed", err, append(fields, zap.String("outcome", "failure"))...)
+			return
+		}
+		r.Info("run finished", append(fields, zap.String("outcome", "success"))...)
+	})
+}
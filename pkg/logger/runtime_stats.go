@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultRuntimeStatsInterval is used for intervals that are not positive.
+const defaultRuntimeStatsInterval = time.Minute
+
+// StartRuntimeStats logs goroutine, heap, GC and file descriptor figures
+// at level every interval, a minute if it is not positive, as lightweight
+// telemetry where no metrics are collected. It returns a function that
+// stops the heartbeat.
+func (l *Logger) StartRuntimeStats(interval time.Duration, level zapcore.Level) (stop func()) {
+	if interval <= 0 {
+		interval = defaultRuntimeStatsInterval
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var last runtime.MemStats
+		runtime.ReadMemStats(&last)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var ms runtime.MemStats
+				runtime.ReadMemStats(&ms)
+				if ce := l.zap.Check(level, "runtime stats"); ce != nil {
+					ce.Write(runtimeStatsFields(&ms, &last)...)
+				}
+				last = ms
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// runtimeStatsFields describes ms, with GC figures covering the cycles
+// since prev.
+func runtimeStatsFields(ms, prev *runtime.MemStats) []zap.Field {
+	fields := []zap.Field{
+		zap.Int("goroutines", runtime.NumGoroutine()),
+		Bytes("heap_alloc", int64(ms.HeapAlloc)),
+		Bytes("heap_sys", int64(ms.HeapSys)),
+		zap.Uint64("heap_objects", ms.HeapObjects),
+		zap.Uint32("gc_count", ms.NumGC),
+		zap.Uint32("gc_cycles", ms.NumGC-prev.NumGC),
+		zap.Duration("gc_pause", time.Duration(ms.PauseTotalNs-prev.PauseTotalNs)),
+	}
+	if ms.NumGC > 0 {
+		// PauseNs is a circular buffer; the latest pause is at (NumGC+255)%256.
+		fields = append(fields, zap.Duration("gc_pause_last", time.Duration(ms.PauseNs[(ms.NumGC+255)%256])))
+	}
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		// Less the descriptor ReadDir opened to list them
+		fields = append(fields, zap.Int("open_fds", len(fds)-1))
+	}
+	return fields
+}
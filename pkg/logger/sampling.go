@@ -0,0 +1,244 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SamplingExemption exempts entries from sampling when they carry the
+// field Key with one of Values, or with any value when Values is empty,
+// e.g. {Key: "user_id", Values: []string{"42"}} or
+// {Key: "debug_session", Values: []string{"true"}}. Fields added with
+// With count as well as those of the entry.
+type SamplingExemption struct {
+	Key    string
+	Values []string
+}
+
+func (e SamplingExemption) matches(f zapcore.Field) bool {
+	if f.Key != e.Key {
+		return false
+	}
+	if len(e.Values) == 0 {
+		return true
+	}
+	v := fieldString(f)
+	for _, want := range e.Values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldString returns the value of f as text, e.g. "true" or "42".
+func fieldString(f zapcore.Field) string {
+	if f.Type == zapcore.StringType {
+		return f.String
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return fmt.Sprint(enc.Fields[f.Key])
+}
+
+// samplingCore logs the first entries with the same level and message in
+// each tick, then every thereafter-th, like zap's sampler. Entries matching
+// an exemption are always logged; since the fields of an entry are only
+// known when it is written, the decision is made in Write. The rate can be
+// changed at runtime and sampling is off while it is nil. With a boost,
+// entries of a named logger or trace that logged an error are not sampled
+// for that long afterwards, to keep the context of failures.
+type samplingCore struct {
+	zapcore.Core
+	tick       time.Duration
+	rate       *atomic.Pointer[sampleRate]
+	exemptions []SamplingExemption
+	exempt     bool // fields added through With match
+	counts     *sampleCounts
+	boost      time.Duration
+	boosted    *boostedKeys
+	trace      string // trace_id added through With
+}
+
+type sampleRate struct {
+	first, thereafter uint64
+}
+
+func newSamplingCore(core zapcore.Core, tick time.Duration, first, thereafter int, exemptions []SamplingExemption, boost time.Duration) *samplingCore {
+	c := &samplingCore{
+		Core:       core,
+		tick:       tick,
+		rate:       new(atomic.Pointer[sampleRate]),
+		exemptions: exemptions,
+		counts:     &sampleCounts{counts: make(map[sampleKey]uint64)},
+		boost:      boost,
+		boosted:    &boostedKeys{until: make(map[string]time.Time)},
+	}
+	c.setRate(first, thereafter)
+	return c
+}
+
+// setRate changes the rate; first <= 0 turns sampling off.
+func (c *samplingCore) setRate(first, thereafter int) {
+	if first <= 0 {
+		c.rate.Store(nil)
+		return
+	}
+	if thereafter < 0 {
+		thereafter = 0
+	}
+	c.rate.Store(&sampleRate{first: uint64(first), thereafter: uint64(thereafter)})
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	clone.exempt = c.exempt || c.isExempt(fields)
+	clone.trace = traceOf(c.trace, fields)
+	return &clone
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.rate.Load() == nil {
+		return c.Core.Check(ent, ce)
+	}
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *samplingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var keys []string
+	if c.boost > 0 {
+		keys = boostKeys(ent, traceOf(c.trace, fields))
+		if ent.Level >= zapcore.ErrorLevel {
+			c.boosted.extend(keys, ent.Time, c.boost)
+		}
+	}
+	if !c.exempt && !c.isExempt(fields) && !c.boosted.any(keys, ent.Time) && !c.sample(ent) {
+		return nil
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+func (c *samplingCore) isExempt(fields []zapcore.Field) bool {
+	for _, e := range c.exemptions {
+		for _, f := range fields {
+			if e.matches(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *samplingCore) sample(ent zapcore.Entry) bool {
+	rate := c.rate.Load()
+	if rate == nil {
+		return true
+	}
+	n := c.counts.inc(ent, c.tick)
+	if n <= rate.first {
+		return true
+	}
+	return rate.thereafter > 0 && (n-rate.first)%rate.thereafter == 0
+}
+
+type sampleKey struct {
+	level   zapcore.Level
+	message string
+}
+
+// sampleCounts counts the entries of the current tick by level and
+// message, starting over with each tick.
+type sampleCounts struct {
+	mu     sync.Mutex
+	tick   int64
+	counts map[sampleKey]uint64
+}
+
+func (s *sampleCounts) inc(ent zapcore.Entry, tick time.Duration) uint64 {
+	t := ent.Time.UnixNano() / int64(tick)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t != s.tick {
+		s.tick = t
+		s.counts = make(map[sampleKey]uint64)
+	}
+	key := sampleKey{ent.Level, ent.Message}
+	s.counts[key]++
+	return s.counts[key]
+}
+
+// traceOf returns the trace_id among fields, or trace.
+func traceOf(trace string, fields []zapcore.Field) string {
+	for _, f := range fields {
+		if f.Key == "trace_id" && f.Type == zapcore.StringType {
+			trace = f.String
+		}
+	}
+	return trace
+}
+
+// boostKeys returns the keys an entry is boosted by: its logger name and
+// trace. The unnamed root logger is not one, or one error would stop
+// sampling everywhere.
+func boostKeys(ent zapcore.Entry, trace string) []string {
+	var keys []string
+	if ent.LoggerName != "" {
+		keys = append(keys, "logger "+ent.LoggerName)
+	}
+	if trace != "" {
+		keys = append(keys, "trace "+trace)
+	}
+	return keys
+}
+
+// boostedKeys are the loggers and traces exempt from sampling until a
+// time, after they logged an error.
+type boostedKeys struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// extend boosts keys for window from now, forgetting expired keys once
+// there are many.
+func (b *boostedKeys) extend(keys []string, now time.Time, window time.Duration) {
+	if len(keys) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.until) >= 1024 {
+		for k, t := range b.until {
+			if !now.Before(t) {
+				delete(b.until, k)
+			}
+		}
+	}
+	for _, k := range keys {
+		b.until[k] = now.Add(window)
+	}
+}
+
+func (b *boostedKeys) any(keys []string, now time.Time) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, k := range keys {
+		if t, ok := b.until[k]; ok && now.Before(t) {
+			return true
+		}
+	}
+	return false
+}
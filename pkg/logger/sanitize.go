@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Sanitize modes for Config.Sanitize.
+const (
+	SanitizeStrip  = "strip"  // drop control characters and invalid UTF-8
+	SanitizeEscape = "escape" // replace them with \x1b style escapes
+)
+
+// sanitizingEncoder removes or escapes control characters and invalid
+// UTF-8 in the message and in string, byte string and error fields, so
+// log content cannot inject terminal escapes or forge console lines.
+// Newlines and tabs are kept in fields; the encoders escape or fold them.
+// Values nested in objects and arrays are left to the wrapped encoder.
+type sanitizingEncoder struct {
+	zapcore.Encoder
+	escape bool
+}
+
+func newSanitizingEncoder(enc zapcore.Encoder, mode string) zapcore.Encoder {
+	switch strings.ToLower(mode) {
+	case SanitizeStrip:
+		return sanitizingEncoder{Encoder: enc}
+	case SanitizeEscape:
+		return sanitizingEncoder{Encoder: enc, escape: true}
+	}
+	return enc
+}
+
+func (e sanitizingEncoder) Clone() zapcore.Encoder {
+	return sanitizingEncoder{Encoder: e.Encoder.Clone(), escape: e.escape}
+}
+
+func (e sanitizingEncoder) AddString(key, value string) {
+	e.Encoder.AddString(key, e.clean(value, "\n\t"))
+}
+
+func (e sanitizingEncoder) AddByteString(key string, value []byte) {
+	e.Encoder.AddString(key, e.clean(string(value), "\n\t"))
+}
+
+func (e sanitizingEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	ent.Message = e.clean(ent.Message, "\t")
+	var cleaned []zapcore.Field
+	for i, f := range fields {
+		var (
+			replacement zapcore.Field
+			dirty       bool
+		)
+		switch f.Type {
+		case zapcore.StringType:
+			if s := e.clean(f.String, "\n\t"); s != f.String {
+				replacement, dirty = zap.String(f.Key, s), true
+			}
+		case zapcore.ByteStringType:
+			b, _ := f.Interface.([]byte)
+			if s := e.clean(string(b), "\n\t"); s != string(b) {
+				replacement, dirty = zap.String(f.Key, s), true
+			}
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				if s := e.clean(err.Error(), "\n\t"); s != err.Error() {
+					replacement, dirty = zap.String(f.Key, s), true
+				}
+			}
+		}
+		if !dirty {
+			continue
+		}
+		if cleaned == nil {
+			cleaned = append([]zapcore.Field(nil), fields...)
+		}
+		cleaned[i] = replacement
+	}
+	if cleaned != nil {
+		fields = cleaned
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+// clean returns s without control characters other than those in keep and
+// without invalid UTF-8, or with them escaped.
+func (e sanitizingEncoder) clean(s, keep string) string {
+	if !needsSanitizing(s, keep) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			if e.escape {
+				fmt.Fprintf(&b, `\x%02x`, s[i])
+			}
+		case isControl(r) && !strings.ContainsRune(keep, r):
+			if e.escape {
+				if r < 0x100 {
+					fmt.Fprintf(&b, `\x%02x`, r)
+				} else {
+					fmt.Fprintf(&b, `\u%04x`, r)
+				}
+			}
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+func needsSanitizing(s, keep string) bool {
+	return !utf8.ValidString(s) || strings.IndexFunc(s, func(r rune) bool {
+		return isControl(r) && !strings.ContainsRune(keep, r)
+	}) >= 0
+}
+
+// isControl reports whether r is a C0 or C1 control character or DEL.
+func isControl(r rune) bool {
+	return r < 0x20 || r >= 0x7f && r < 0xa0
+}
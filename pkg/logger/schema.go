@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SchemaVersionKey is the field holding Config.SchemaVersion. Entries
+// without it are of version 0.
+const SchemaVersionKey = "schema_version"
+
+// SchemaMigration renames fields from schema version From to From+1,
+// mapping old keys to new ones, e.g. {"user": "user.id"}.
+type SchemaMigration struct {
+	From    int
+	Renames map[string]string
+}
+
+var schemaMigrations = struct {
+	mu     sync.RWMutex
+	byFrom map[int]SchemaMigration
+}{byFrom: make(map[int]SchemaMigration)}
+
+// RegisterSchemaMigration records the field renames of a schema version,
+// which Config.SchemaCompatVersion undoes for old parsers and MigrateEntry
+// applies for new ones. Register migrations before building loggers.
+func RegisterSchemaMigration(m SchemaMigration) {
+	schemaMigrations.mu.Lock()
+	defer schemaMigrations.mu.Unlock()
+	schemaMigrations.byFrom[m.From] = m
+}
+
+func schemaRenames(from int) map[string]string {
+	schemaMigrations.mu.RLock()
+	defer schemaMigrations.mu.RUnlock()
+	return schemaMigrations.byFrom[from].Renames
+}
+
+// MigrateEntry renames the fields of a JSON entry, decoded into a map, to
+// their names in schema version to, and sets its schema_version. Entries
+// of a later version are rejected.
+func MigrateEntry(entry map[string]json.RawMessage, to int) error {
+	version := 0
+	if raw, ok := entry[SchemaVersionKey]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return fmt.Errorf("%s: %w", SchemaVersionKey, err)
+		}
+	}
+	if version > to {
+		return fmt.Errorf("entry of schema version %d is newer than %d", version, to)
+	}
+	for ; version < to; version++ {
+		for old, renamed := range schemaRenames(version) {
+			if v, ok := entry[old]; ok {
+				delete(entry, old)
+				entry[renamed] = v
+			}
+		}
+	}
+	entry[SchemaVersionKey] = json.RawMessage(fmt.Sprint(to))
+	return nil
+}
+
+// schemaAliases maps the keys of fields renamed between schema versions
+// from and to, by their name in to, to their name in from.
+func schemaAliases(from, to int) map[string]string {
+	names := make(map[string]string) // name in from -> name in the version reached
+	for version := from; version < to; version++ {
+		renames := schemaRenames(version)
+		reached := make(map[string]bool, len(names))
+		for orig, cur := range names {
+			reached[cur] = true
+			if renamed, ok := renames[cur]; ok {
+				names[orig] = renamed
+			}
+		}
+		for old, renamed := range renames {
+			if !reached[old] {
+				names[old] = renamed
+			}
+		}
+	}
+	aliases := make(map[string]string, len(names))
+	for orig, cur := range names {
+		if orig != cur {
+			aliases[cur] = orig
+		}
+	}
+	return aliases
+}
+
+// schemaCompatCore also writes renamed fields under their old names.
+type schemaCompatCore struct {
+	zapcore.Core
+	aliases map[string]string // current key -> old key
+}
+
+func (c schemaCompatCore) With(fields []zapcore.Field) zapcore.Core {
+	return schemaCompatCore{c.Core.With(c.withAliases(fields)), c.aliases}
+}
+
+func (c schemaCompatCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c schemaCompatCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(c.withAliases(fields)...)
+	}
+	return nil
+}
+
+func (c schemaCompatCore) withAliases(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for _, f := range fields {
+		if old, ok := c.aliases[f.Key]; ok {
+			if out == nil {
+				out = append(out, fields...)
+			}
+			f.Key = old
+			out = append(out, f)
+		}
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
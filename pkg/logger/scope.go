@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ScopeKey is the field naming the scope of an entry, with the names of
+// enclosing scopes before it, e.g. "import-batch/parse".
+const ScopeKey = "scope"
+
+type scopeKey struct{}
+
+// Scope is a named region of work such as one batch of a job, a
+// lightweight alternative to a tracing span. Its start and end are logged,
+// the end with the duration, and loggers from Ctx on its context tag
+// entries with the scope and the fields it was begun with, including those
+// of enclosing scopes.
+type Scope struct {
+	ctx    context.Context
+	log    *Logger
+	path   string
+	fields []zap.Field
+	start  time.Time
+	once   sync.Once
+}
+
+// Begin starts a scope using the global logger.
+func Begin(ctx context.Context, name string, fields ...zap.Field) *Scope {
+	return logInstance.Load().Begin(ctx, name, fields...)
+}
+
+// Begin starts a scope called name within the scope of ctx, if any, and
+// logs "scope started". The caller must call End.
+func (l *Logger) Begin(ctx context.Context, name string, fields ...zap.Field) *Scope {
+	s := &Scope{path: name, start: time.Now()}
+	if parent, ok := scopeFromContext(ctx); ok {
+		s.path = parent.path + "/" + name
+		s.fields = append(s.fields, parent.fields...)
+	}
+	s.fields = append(s.fields, fields...)
+	s.ctx = context.WithValue(ctx, scopeKey{}, s)
+	s.log = l.Ctx(s.ctx)
+	s.log.Info("scope started")
+	return s
+}
+
+func scopeFromContext(ctx context.Context) (*Scope, bool) {
+	s, ok := ctx.Value(scopeKey{}).(*Scope)
+	return s, ok
+}
+
+// scopeFields returns the fields Ctx adds for the scope of ctx.
+func scopeFields(ctx context.Context) []zap.Field {
+	s, ok := scopeFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return append([]zap.Field{zap.String(ScopeKey, s.path)}, s.fields...)
+}
+
+// Context returns the context carrying the scope, for nested scopes and
+// Ctx loggers.
+func (s *Scope) Context() context.Context {
+	return s.ctx
+}
+
+// Logger returns the logger for entries within the scope.
+func (s *Scope) Logger() *Logger {
+	return s.log
+}
+
+// End logs "scope finished" with the duration, or "scope failed" like
+// Error when err is not nil. Only the first call logs.
+func (s *Scope) End(err error, fields ...zap.Field) {
+	s.once.Do(func() {
+		fields = append(fields[:len(fields):len(fields)], zap.Duration("duration", time.Since(s.start)))
+		if err != nil {
+			s.log.Error("scope failed", err, fields...)
+			return
+		}
+		s.log.Info("scope finished", fields...)
+	})
+}
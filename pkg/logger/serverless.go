@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// invocationFlushTimeout bounds the flush at the end of an invocation. It
+// does not use the invocation's context, whose deadline may have passed.
+const invocationFlushTimeout = 2 * time.Second
+
+// invoked is set once the process has run its first invocation; later ones
+// are warm starts.
+var invoked atomic.Bool
+
+// Invoke runs one serverless invocation with a logger whose entries carry
+// invocationID, e.g. the Lambda request ID, and whether the invocation
+// was a cold start, then flushes every output before returning fn's
+// error. With AWS Lambda:
+//
+//	func handler(ctx context.Context, ev Event) error {
+//		lc, _ := lambdacontext.FromContext(ctx)
+//		return log.Invoke(ctx, lc.AwsRequestID, func(ctx context.Context, log *logger.Logger) error {
+//			...
+//		})
+//	}
+//
+// A failed flush is reported on stderr rather than failing the invocation.
+func (l *Logger) Invoke(ctx context.Context, invocationID string, fn func(context.Context, *Logger) error) error {
+	il := l.With(
+		zap.String("faas.invocation_id", invocationID),
+		zap.Bool("faas.coldstart", !invoked.Swap(true)),
+	)
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), invocationFlushTimeout)
+		defer cancel()
+		if err := l.Sync(flushCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: flush after invocation %s: %v\n", invocationID, err)
+		}
+	}()
+	return fn(ctx, il)
+}
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TraceLevel is below debug for very verbose output. It is only logged when
+// Level or a level override is "trace".
+const TraceLevel = zapcore.DebugLevel - 1
+
+// Severity is how a level is reported to backends with their own severity
+// scales.
+type Severity struct {
+	Syslog int    // syslog priority, 0 (emerg) to 7 (debug), e.g. for journald
+	OTLP   int    // OpenTelemetry SeverityNumber, 1 (trace) to 24 (fatal4)
+	GCP    string // Cloud Logging LogSeverity, e.g. "WARNING"
+}
+
+var levels = struct {
+	mu         sync.RWMutex
+	byName     map[string]zapcore.Level
+	names      map[zapcore.Level]string
+	severities map[zapcore.Level]Severity
+}{
+	byName: map[string]zapcore.Level{"trace": TraceLevel},
+	names:  map[zapcore.Level]string{TraceLevel: "trace"},
+	severities: map[zapcore.Level]Severity{
+		TraceLevel:          {Syslog: 7, OTLP: 1, GCP: "DEBUG"},
+		zapcore.DebugLevel:  {Syslog: 7, OTLP: 5, GCP: "DEBUG"},
+		zapcore.InfoLevel:   {Syslog: 6, OTLP: 9, GCP: "INFO"},
+		zapcore.WarnLevel:   {Syslog: 4, OTLP: 13, GCP: "WARNING"},
+		zapcore.ErrorLevel:  {Syslog: 3, OTLP: 17, GCP: "ERROR"},
+		zapcore.DPanicLevel: {Syslog: 2, OTLP: 21, GCP: "CRITICAL"},
+		zapcore.PanicLevel:  {Syslog: 2, OTLP: 21, GCP: "ALERT"},
+		zapcore.FatalLevel:  {Syslog: 2, OTLP: 21, GCP: "EMERGENCY"},
+	},
+}
+
+// RegisterLevel names a custom level and sets the severities sinks report
+// it with. ParseLevel accepts the name and encoders write it in place of
+// zap's "Level(n)". Registering one of zap's levels under its own name only
+// changes its severities.
+func RegisterLevel(name string, lvl zapcore.Level, sev Severity) {
+	name = strings.ToLower(name)
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	if name != lvl.String() {
+		levels.byName[name] = lvl
+		levels.names[lvl] = name
+	}
+	levels.severities[lvl] = sev
+}
+
+// SeverityOf returns the severities of lvl. Levels without their own are
+// reported like the closest registered level below them, or the lowest.
+func SeverityOf(lvl zapcore.Level) Severity {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+	if sev, ok := levels.severities[lvl]; ok {
+		return sev
+	}
+	below, above := false, false
+	var lo, hi zapcore.Level
+	for l := range levels.severities {
+		if l < lvl && (!below || l > lo) {
+			lo, below = l, true
+		}
+		if l > lvl && (!above || l < hi) {
+			hi, above = l, true
+		}
+	}
+	if below {
+		return levels.severities[lo]
+	}
+	return levels.severities[hi]
+}
+
+// severityOfName returns the severities of an encoded level name.
+func severityOfName(name string) (Severity, bool) {
+	lvl, err := ParseLevel(name)
+	if err != nil {
+		return Severity{}, false
+	}
+	return SeverityOf(lvl), true
+}
+
+func registeredLevel(name string) (zapcore.Level, bool) {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+	lvl, ok := levels.byName[name]
+	return lvl, ok
+}
+
+// levelName returns the registered name of lvl, or zap's.
+func levelName(lvl zapcore.Level) string {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+	if name, ok := levels.names[lvl]; ok {
+		return name
+	}
+	return lvl.String()
+}
+
+// levelEncoder writes registered level names, and the rest like enc.
+func levelEncoder(enc zapcore.LevelEncoder, upper bool) zapcore.LevelEncoder {
+	return func(lvl zapcore.Level, pae zapcore.PrimitiveArrayEncoder) {
+		levels.mu.RLock()
+		name, ok := levels.names[lvl]
+		levels.mu.RUnlock()
+		if !ok {
+			enc(lvl, pae)
+			return
+		}
+		if upper {
+			name = strings.ToUpper(name)
+		}
+		pae.AppendString(name)
+	}
+}
+
+// allLevels enables every level, including custom ones below debug; the
+// levelCore filters entries before they reach outputs.
+var allLevels = zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })
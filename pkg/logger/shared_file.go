@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// Config.FileSharing modes, for log files written by several processes at
+// once, such as the workers of a prefork server.
+const (
+	// FileSharingAppend writes each entry with a single append, which
+	// local file systems do not interleave with the appends of other
+	// processes. Rotation is coordinated through a lock file, and the
+	// processes follow the file to its new incarnation within a second.
+	FileSharingAppend = "append"
+
+	// FileSharingLock also holds the lock file around every write, for
+	// file systems where appends may interleave, such as NFS, at the cost
+	// of a system call pair per entry.
+	FileSharingLock = "flock"
+)
+
+var errNoFileLocks = errors.New("file locks are not supported on this platform")
+
+// sharedFileCheckInterval is how often a process appending to a shared
+// file checks whether another one rotated it.
+const sharedFileCheckInterval = time.Second
+
+// sharedFile is a log file that several processes append to, in place of
+// lumberjack, which assumes it is alone. Rotation happens under an
+// exclusive lock on path+".lock": the process finding the file full renames
+// it to a backup named as lumberjack would, and the others notice the file
+// at path changed and reopen it.
+type sharedFile struct {
+	path     string
+	maxBytes int64
+	perm     FilePermissions
+	lockEach bool
+	mill     *backupMill // prunes, compresses and lists the backups
+
+	mu        sync.Mutex
+	file      *os.File
+	lock      *os.File
+	lastCheck time.Time
+}
+
+func newSharedFile(path, mode string, rot rotation, perm FilePermissions) *sharedFile {
+	mill := newBackupMill(path, rot, perm)
+	mill.pruneBackups = true
+	return &sharedFile{
+		path:     path,
+		maxBytes: mill.maxBytes,
+		perm:     perm,
+		lockEach: strings.EqualFold(mode, FileSharingLock),
+		mill:     mill,
+	}
+}
+
+func (f *sharedFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.openLock(); err != nil {
+		return 0, err
+	}
+	if f.lockEach {
+		if err := lockFile(f.lock); err != nil {
+			return 0, err
+		}
+		defer unlockFile(f.lock)
+		// Under the lock, the file at path is the one to write to.
+		f.lastCheck = time.Time{}
+	}
+	if err := f.follow(); err != nil {
+		return 0, err
+	}
+	n, err := f.file.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if info, err := f.file.Stat(); err == nil && info.Size() >= f.maxBytes {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: %s: rotating: %v\n", f.path, err)
+		}
+	}
+	return n, nil
+}
+
+// openLock opens the lock file, once.
+func (f *sharedFile) openLock() error {
+	if f.lock != nil {
+		return nil
+	}
+	prepareLogFile(f.path, f.perm)
+	if err := os.MkdirAll(filepath.Dir(f.path), 0744); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(f.path+".lock", os.O_RDWR|os.O_CREATE, f.perm.fileMode())
+	if err != nil {
+		return err
+	}
+	f.lock = lock
+	return nil
+}
+
+// follow opens the file at path unless the open file still is the one
+// there, checking at most every sharedFileCheckInterval.
+func (f *sharedFile) follow() error {
+	if f.file != nil {
+		if time.Since(f.lastCheck) < sharedFileCheckInterval {
+			return nil
+		}
+		f.lastCheck = time.Now()
+		current, err := f.file.Stat()
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(f.path); err == nil && os.SameFile(info, current) {
+			return nil
+		}
+		f.file.Close()
+		f.file = nil
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, f.perm.fileMode())
+	if err != nil {
+		return err
+	}
+	f.file = file
+	f.lastCheck = time.Now()
+	return nil
+}
+
+// rotate moves the full file at path to a backup, unless another process
+// did already, and opens a new one.
+func (f *sharedFile) rotate() error {
+	if !f.lockEach {
+		if err := lockFile(f.lock); err != nil {
+			return err
+		}
+		defer unlockFile(f.lock)
+	}
+	current, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(f.path)
+	if err == nil && os.SameFile(info, current) && info.Size() >= f.maxBytes {
+		if err := os.Rename(f.path, sharedBackupName(f.path, time.Now())); err != nil {
+			return err
+		}
+		f.mill.start()
+	}
+	f.file.Close()
+	f.file = nil
+	f.lastCheck = time.Time{}
+	return f.follow()
+}
+
+// sharedBackupName names a backup as lumberjack does, so the backups
+// of shared and unshared files are handled alike.
+func sharedBackupName(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), t.UTC().Format(backupTimeFormat), ext)
+}
+
+// reopen closes the file, which is opened at its path again on the next
+// write.
+func (f *sharedFile) reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *sharedFile) Sync() error {
+	return nil
+}
+
+func (f *sharedFile) fsync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+func (f *sharedFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	if f.file != nil {
+		errs = append(errs, f.file.Close())
+		f.file = nil
+	}
+	if f.lock != nil {
+		errs = append(errs, f.lock.Close())
+		f.lock = nil
+	}
+	return multierr.Combine(errs...)
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// Sink is an additional destination for log entries, such as a remote
+// collector. Every Write receives exactly one JSON-encoded entry terminated
+// by a newline; the slice must not be retained after Write returns.
+type Sink interface {
+	Name() string
+	Write(p []byte) (int, error)
+	Sync() error
+	Close() error
+}
+
+// coreSink is implemented by sinks that encode entries themselves instead
+// of receiving them as JSON.
+type coreSink interface {
+	Sink
+	newCore(encoderConfig zapcore.EncoderConfig) zapcore.Core
+}
+
+func newSinkCore(sink Sink, encoderConfig zapcore.EncoderConfig, format outputFormat) zapcore.Core {
+	if ks, ok := sink.(keyedSink); ok {
+		ks.setEntryKeys(newEntryKeys(encoderConfig, format))
+	}
+	if cs, ok := sink.(coreSink); ok {
+		return cs.newCore(encoderConfig)
+	}
+	return zapcore.NewCore(
+		format.wrap(newJSONEncoder(encoderConfig)),
+		sink,
+		allLevels,
+	)
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// SQLiteConfig configures a sink storing entries in a local SQLite
+// database. The table is created if needed:
+//
+//	CREATE TABLE logs (
+//		id      INTEGER PRIMARY KEY AUTOINCREMENT,
+//		ts      INTEGER NOT NULL, -- unix nanoseconds
+//		level   TEXT NOT NULL,
+//		message TEXT NOT NULL,
+//		fields  TEXT NOT NULL     -- JSON object of the remaining fields
+//	)
+//
+// with indexes on ts and on (level, ts). Fields can be queried with
+// SQLite's JSON functions, e.g. json_extract(fields, '$.user_id').
+type SQLiteConfig struct {
+	// DB is opened by the caller with the SQLite driver of their choice,
+	// e.g. sql.Open("sqlite3", "/var/lib/app/logs.db").
+	DB *sql.DB
+
+	Table string // default "logs"
+
+	// Rows older than MaxAge, and the oldest rows beyond MaxRows, are
+	// pruned at most once per PruneInterval (default 1m). Zero disables
+	// either limit.
+	MaxAge        time.Duration
+	MaxRows       int64
+	PruneInterval time.Duration
+
+	Delivery DeliveryConfig
+}
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// NewSQLiteSink creates the table and returns a sink inserting each batch
+// in one transaction.
+func NewSQLiteSink(name string, cfg SQLiteConfig) (*Delivery, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("sqlite sink: no database")
+	}
+	if cfg.Table == "" {
+		cfg.Table = "logs"
+	}
+	if !sqlIdentifier.MatchString(cfg.Table) {
+		return nil, fmt.Errorf("sqlite sink: invalid table name %q", cfg.Table)
+	}
+	if cfg.PruneInterval <= 0 {
+		cfg.PruneInterval = time.Minute
+	}
+	schema := fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id      INTEGER PRIMARY KEY AUTOINCREMENT,
+	ts      INTEGER NOT NULL,
+	level   TEXT NOT NULL,
+	message TEXT NOT NULL,
+	fields  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS %[1]s_ts ON %[1]s (ts);
+CREATE INDEX IF NOT EXISTS %[1]s_level_ts ON %[1]s (level, ts);`, cfg.Table)
+	if _, err := cfg.DB.Exec(schema); err != nil {
+		return nil, fmt.Errorf("sqlite sink: %w", err)
+	}
+	return NewDelivery(name, &sqliteTransport{cfg: cfg}, cfg.Delivery)
+}
+
+type sqliteTransport struct {
+	cfg       SQLiteConfig
+	lastPrune time.Time
+}
+
+func (t *sqliteTransport) Send(ctx context.Context, batch [][]byte) error {
+	tx, err := t.cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (ts, level, message, fields) VALUES (?, ?, ?, ?)", t.cfg.Table))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, entry := range batch {
+		e, err := decodeEntry(entry, entryKeysFrom(ctx))
+		if err != nil {
+			continue
+		}
+		if _, err := stmt.ExecContext(ctx, e.Time.UnixNano(), e.Level, e.Message, string(e.fieldsJSON())); err != nil {
+			return err
+		}
+	}
+	pruned := false
+	if time.Since(t.lastPrune) >= t.cfg.PruneInterval {
+		if err := t.prune(ctx, tx); err != nil {
+			return err
+		}
+		pruned = true
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if pruned {
+		t.lastPrune = time.Now()
+	}
+	return nil
+}
+
+// prune applies the retention limits within the batch's transaction.
+func (t *sqliteTransport) prune(ctx context.Context, tx *sql.Tx) error {
+	if t.cfg.MaxAge > 0 {
+		cutoff := time.Now().Add(-t.cfg.MaxAge).UnixNano()
+		if _, err := tx.ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE ts < ?", t.cfg.Table), cutoff); err != nil {
+			return fmt.Errorf("prune: %w", err)
+		}
+	}
+	if t.cfg.MaxRows > 0 {
+		if _, err := tx.ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %[1]s WHERE id <= (SELECT MAX(id) FROM %[1]s) - ?", t.cfg.Table),
+			t.cfg.MaxRows); err != nil {
+			return fmt.Errorf("prune: %w", err)
+		}
+	}
+	return nil
+}
+
+func (t *sqliteTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,256 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultTapeEntries is how many entries a Tape holds when
+// TailRetention.MaxEntries is not set.
+const defaultTapeEntries = 1000
+
+// TailRetention keeps the debug and info entries of a request only when it
+// fails: HTTPMiddleware buffers those logged through Ctx loggers and
+// writes them when the request logs an error, responds with a 5xx status
+// or takes longer than Latency, and discards them otherwise. Debug entries
+// are kept whatever the level, and kept entries skip sampling.
+type TailRetention struct {
+	Enabled bool
+
+	// Latency also keeps the entries of requests slower than this.
+	Latency time.Duration
+
+	// MaxEntries buffered per request, 1000 by default. Beyond it the
+	// oldest are dropped.
+	MaxEntries int
+}
+
+func (t TailRetention) validate() error {
+	if t.Latency < 0 {
+		return errors.New("negative latency")
+	}
+	if t.MaxEntries < 0 {
+		return errors.New("negative max entries")
+	}
+	return nil
+}
+
+type tapeKey struct{}
+
+// Tape buffers the debug and info entries logged through Ctx loggers of a
+// context until the outcome of the work it stands for is known. Errors
+// are never buffered: an error writes the tape out first, and later
+// entries are written straight away.
+type Tape struct {
+	mu      sync.Mutex
+	entries []tapedEntry
+	max     int
+	dropped int
+	state   tapeState
+}
+
+type tapeState int
+
+const (
+	tapeRecording tapeState = iota
+	tapeFlushed             // kept: write entries unfiltered
+	tapeDiscarded           // ended: write entries like any other
+)
+
+type tapedEntry struct {
+	ent    zapcore.Entry
+	fields []zapcore.Field
+	out    zapcore.Core
+}
+
+// StartTape returns a context whose Ctx loggers record on the returned
+// tape, holding up to maxEntries entries, or 1000 when it is 0. The caller
+// must call Flush or Discard when the work is done.
+func StartTape(ctx context.Context, maxEntries int) (context.Context, *Tape) {
+	if maxEntries <= 0 {
+		maxEntries = defaultTapeEntries
+	}
+	t := &Tape{max: maxEntries}
+	return context.WithValue(ctx, tapeKey{}, t), t
+}
+
+func tapeFromContext(ctx context.Context) (*Tape, bool) {
+	t, ok := ctx.Value(tapeKey{}).(*Tape)
+	return t, ok
+}
+
+// Flush writes the recorded entries and every later one, at any level.
+func (t *Tape) Flush() {
+	t.mu.Lock()
+	if t.state != tapeRecording {
+		t.mu.Unlock()
+		return
+	}
+	entries, dropped := t.entries, t.dropped
+	t.entries, t.state = nil, tapeFlushed
+	t.mu.Unlock()
+
+	if dropped > 0 && len(entries) > 0 {
+		first := entries[0]
+		ent := zapcore.Entry{
+			Level:      zapcore.WarnLevel,
+			Time:       first.ent.Time,
+			LoggerName: first.ent.LoggerName,
+			Message:    "earlier request entries dropped",
+		}
+		if ce := first.out.Check(ent, nil); ce != nil {
+			ce.Write(zap.Int("dropped", dropped))
+		}
+	}
+	for _, e := range entries {
+		if ce := e.out.Check(e.ent, nil); ce != nil {
+			ce.Write(e.fields...)
+		}
+	}
+}
+
+// Discard drops the recorded entries; later ones are logged as usual.
+func (t *Tape) Discard() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state == tapeRecording {
+		t.entries, t.state = nil, tapeDiscarded
+	}
+}
+
+// record buffers an entry while the tape is recording; fields must not be
+// shared with the caller.
+func (t *Tape) record(ent zapcore.Entry, fields []zapcore.Field, out zapcore.Core) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state != tapeRecording {
+		return false
+	}
+	if len(t.entries) == t.max {
+		copy(t.entries, t.entries[1:])
+		t.entries = t.entries[:len(t.entries)-1]
+		t.dropped++
+	}
+	t.entries = append(t.entries, tapedEntry{ent: ent, fields: fields, out: out})
+	return true
+}
+
+func (t *Tape) flushed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state == tapeFlushed
+}
+
+// tapeMarker is a field that Ctx adds for a context with a tape, which
+// tapeCore's With looks for; encoders skip it.
+func tapeMarker(t *Tape) zap.Field {
+	return zap.Field{Key: "tape_marker", Type: zapcore.SkipType, Interface: t}
+}
+
+func tapeOf(fields []zapcore.Field) *Tape {
+	for _, f := range fields {
+		if f.Key == "tape_marker" && f.Type == zapcore.SkipType {
+			if t, ok := f.Interface.(*Tape); ok {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// tapeCore records entries of loggers with a tape, which are decided in
+// Write. The rest go through the level filters; kept entries are written
+// to out, the same outputs before level filters and sampling, with the
+// fields added through With. Entries are decorated as they are logged,
+// so their caller and provided fields are those of the logging call
+// rather than of the flush, and out does not decorate them again.
+type tapeCore struct {
+	zapcore.Core
+	out      zapcore.Core
+	decorate func(zapcore.Core) zapcore.Core
+	context  []zapcore.Field
+	tape     *Tape
+}
+
+func newTapeCore(core, out zapcore.Core, decorate func(zapcore.Core) zapcore.Core) *tapeCore {
+	return &tapeCore{Core: core, out: out, decorate: decorate}
+}
+
+func (c *tapeCore) Enabled(lvl zapcore.Level) bool {
+	return c.tape != nil && lvl >= zapcore.DebugLevel || c.Core.Enabled(lvl)
+}
+
+func (c *tapeCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	clone.context = append(c.context[:len(c.context):len(c.context)], fields...)
+	if t := tapeOf(fields); t != nil {
+		clone.tape = t
+	}
+	return &clone
+}
+
+func (c *tapeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.tape != nil && ent.Level >= zapcore.DebugLevel {
+		return ce.AddCore(ent, c)
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func (c *tapeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level >= zapcore.ErrorLevel {
+		c.tape.Flush()
+	} else if ent.Level < zapcore.WarnLevel {
+		rec := &tapeRecorder{tape: c.tape, out: c.out, context: c.context}
+		if ce := c.decorate(rec).Check(ent, nil); ce != nil {
+			ce.Write(fields...)
+		}
+		if rec.recorded {
+			return nil
+		}
+	}
+	core := c.Core
+	if c.tape.flushed() {
+		core, fields = c.decorate(c.out), c.withContext(fields)
+	}
+	if ce := core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// withContext returns the fields added through With followed by fields,
+// for out, which encodes them the same as if they had been added to it.
+func (c *tapeCore) withContext(fields []zapcore.Field) []zapcore.Field {
+	return append(c.context[:len(c.context):len(c.context)], fields...)
+}
+
+// tapeRecorder is the core under the decoration of a tapeCore entry,
+// recording the decorated entry on the tape if it is still recording.
+type tapeRecorder struct {
+	tape     *Tape
+	out      zapcore.Core
+	context  []zapcore.Field
+	recorded bool
+}
+
+func (r *tapeRecorder) Enabled(zapcore.Level) bool { return true }
+
+func (r *tapeRecorder) With([]zapcore.Field) zapcore.Core { return r }
+
+func (r *tapeRecorder) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, r)
+}
+
+func (r *tapeRecorder) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	fields = append(r.context[:len(r.context):len(r.context)], fields...)
+	r.recorded = r.tape.record(ent, fields, r.out)
+	return nil
+}
+
+func (r *tapeRecorder) Sync() error { return nil }
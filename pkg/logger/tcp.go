@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// TCPConfig configures a sink that forwards newline-delimited JSON over TCP,
+// e.g. to Logstash, Vector or a custom collector.
+type TCPConfig struct {
+	Addr string // host:port
+
+	// TLS enables TLS when set. Client certificates in it are presented for
+	// mutual TLS; see LoadClientTLS.
+	TLS *tls.Config
+
+	DialTimeout time.Duration // default 5s
+	KeepAlive   time.Duration // TCP keepalive period, default 30s
+
+	// Delivery tunes queueing and retries. Once its queue is full, entries
+	// are spilled or dropped instead of blocking the caller.
+	Delivery DeliveryConfig
+}
+
+// NewTCPSink returns a sink that forwards entries to cfg.Addr, reconnecting
+// after connection failures.
+func NewTCPSink(name string, cfg TCPConfig) (*Delivery, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("tcp sink: no address")
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	if cfg.KeepAlive <= 0 {
+		cfg.KeepAlive = 30 * time.Second
+	}
+	return NewDelivery(name, &tcpTransport{cfg: cfg}, cfg.Delivery)
+}
+
+// LoadClientTLS builds a TLS configuration for mutual TLS from PEM files.
+// caFile may be empty to trust the system roots.
+func LoadClientTLS(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+type tcpTransport struct {
+	cfg  TCPConfig
+	conn net.Conn
+}
+
+// Send writes the batch in one go. Any failure drops the connection, so
+// the retry starts on a fresh one; entries are delivered at least once.
+func (t *tcpTransport) Send(ctx context.Context, batch [][]byte) error {
+	if t.conn == nil {
+		conn, err := t.dial(ctx)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		t.conn.SetWriteDeadline(deadline)
+	}
+	bufs := make(net.Buffers, 0, len(batch))
+	for _, entry := range batch {
+		bufs = append(bufs, entry)
+		if len(entry) > 0 && entry[len(entry)-1] != '\n' {
+			bufs = append(bufs, []byte{'\n'})
+		}
+	}
+	if _, err := bufs.WriteTo(t.conn); err != nil {
+		t.conn.Close()
+		t.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (t *tcpTransport) dial(ctx context.Context) (net.Conn, error) {
+	d := &net.Dialer{Timeout: t.cfg.DialTimeout, KeepAlive: t.cfg.KeepAlive}
+	if t.cfg.TLS == nil {
+		return d.DialContext(ctx, "tcp", t.cfg.Addr)
+	}
+	td := &tls.Dialer{NetDialer: d, Config: t.cfg.TLS}
+	return td.DialContext(ctx, "tcp", t.cfg.Addr)
+}
+
+func (t *tcpTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MessageTemplateKey is the field keeping the template of a message with
+// named placeholders, so entries can be grouped by it whatever the values.
+const MessageTemplateKey = "message_template"
+
+// Fields are named values for a message template:
+//
+//	log.Info("user {user_id} purchased {sku}", logger.Fields{"user_id": 42, "sku": "B-7"}.List()...)
+//
+// logs "user 42 purchased B-7" with message_template, user_id and sku
+// fields. Placeholders are filled from the fields of the entry however
+// they were built, so zap.Int("user_id", 42) works as well.
+type Fields map[string]interface{}
+
+// List returns the fields sorted by key.
+func (f Fields) List() []zap.Field {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]zap.Field, len(keys))
+	for i, k := range keys {
+		fields[i] = zap.Any(k, f[k])
+	}
+	return fields
+}
+
+// templateCore renders messages with {name} placeholders naming fields of
+// the entry, and adds the template as MessageTemplateKey. Messages without
+// such a placeholder are left as they are.
+type templateCore struct {
+	zapcore.Core
+}
+
+func (c templateCore) With(fields []zapcore.Field) zapcore.Core {
+	return templateCore{c.Core.With(fields)}
+}
+
+func (c templateCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if strings.IndexByte(ent.Message, '{') < 0 {
+		return c.Core.Check(ent, ce)
+	}
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c templateCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if msg, ok := renderTemplate(ent.Message, fields); ok {
+		fields = append(fields[:len(fields):len(fields)], zap.String(MessageTemplateKey, ent.Message))
+		ent.Message = msg
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// renderTemplate fills the placeholders of template naming fields, and
+// reports whether there were any.
+func renderTemplate(template string, fields []zapcore.Field) (string, bool) {
+	var (
+		b        strings.Builder
+		rendered bool
+	)
+	rest := template
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			break
+		}
+		name := rest[open+1 : open+end]
+		f, ok := fieldNamed(fields, name)
+		if !ok {
+			b.WriteString(rest[:open+1])
+			rest = rest[open+1:]
+			continue
+		}
+		b.WriteString(rest[:open])
+		b.WriteString(fieldString(f))
+		rest = rest[open+end+1:]
+		rendered = true
+	}
+	if !rendered {
+		return template, false
+	}
+	b.WriteString(rest)
+	return b.String(), true
+}
+
+func fieldNamed(fields []zapcore.Field, name string) (zapcore.Field, bool) {
+	if name == "" {
+		return zapcore.Field{}, false
+	}
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key == name && fields[i].Type != zapcore.SkipType {
+			return fields[i], true
+		}
+	}
+	return zapcore.Field{}, false
+}
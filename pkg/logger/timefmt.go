@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Timestamp formats for Config.TimeFormat and its per-output overrides. Any
+// other value is used as a time.Format layout.
+const (
+	TimeFormatRFC3339      = "rfc3339"
+	TimeFormatRFC3339Nano  = "rfc3339nano" // default
+	TimeFormatEpochSeconds = "epoch"       // float seconds since the epoch
+	TimeFormatEpochMillis  = "epoch_millis"
+)
+
+var beijingLocation = loadBeijingLocation()
+
+func loadBeijingLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.FixedZone("CST", 8*60*60)
+	}
+	return loc
+}
+
+// loadTimeZone resolves a Config time zone: an IANA name such as "UTC"
+// or "Europe/Berlin", or "Local" for the machine's zone. Empty is Beijing
+// time.
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return beijingLocation, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// timeEncoder returns the encoder for a timestamp format. Formatted times
+// are in zone, Beijing time when it is empty or unknown.
+func timeEncoder(format, zone string) zapcore.TimeEncoder {
+	loc, err := loadTimeZone(zone)
+	if err != nil {
+		loc = beijingLocation
+	}
+	switch strings.ToLower(format) {
+	case "", TimeFormatRFC3339Nano:
+		if loc == beijingLocation {
+			return beijingTimeEncoder
+		}
+		format = time.RFC3339Nano
+	case TimeFormatRFC3339:
+		format = time.RFC3339
+	case TimeFormatEpochSeconds:
+		return zapcore.EpochTimeEncoder
+	case TimeFormatEpochMillis:
+		return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendInt64(t.UnixMilli())
+		}
+	}
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		appendTimeLayout(enc, t.In(loc), format)
+	}
+}
+
+// appendTimeLayout appends t formatted with layout, without an
+// intermediate string when enc supports it like zap's JSON encoder.
+func appendTimeLayout(enc zapcore.PrimitiveArrayEncoder, t time.Time, layout string) {
+	if enc, ok := enc.(interface{ AppendTimeLayout(time.Time, string) }); ok {
+		enc.AppendTimeLayout(t, layout)
+		return
+	}
+	enc.AppendString(t.Format(layout))
+}
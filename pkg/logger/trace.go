@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// TraceparentHeader is the W3C Trace Context propagation header.
+const TraceparentHeader = "traceparent"
+
+// TraceContext identifies the trace and span an operation belongs to.
+type TraceContext struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Flags        byte
+}
+
+type traceContextKey struct{}
+
+// NewTraceContext starts a new sampled trace with a random trace and span ID.
+func NewTraceContext() TraceContext {
+	return TraceContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: 0x01}
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(value string) (TraceContext, error) {
+	value = strings.TrimSpace(value)
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return TraceContext{}, fmt.Errorf("traceparent %q: expected 4 fields", value)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return TraceContext{}, fmt.Errorf("traceparent %q: invalid version", value)
+	}
+	// Version 00 has exactly four fields; later versions may append more.
+	if version == "00" && len(parts) != 4 {
+		return TraceContext{}, fmt.Errorf("traceparent %q: unexpected trailing data", value)
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) || strings.Trim(traceID, "0") == "" {
+		return TraceContext{}, fmt.Errorf("traceparent %q: invalid trace-id", value)
+	}
+	if len(spanID) != 16 || !isLowerHex(spanID) || strings.Trim(spanID, "0") == "" {
+		return TraceContext{}, fmt.Errorf("traceparent %q: invalid parent-id", value)
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return TraceContext{}, fmt.Errorf("traceparent %q: invalid trace-flags", value)
+	}
+	b, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Flags: b[0]}, nil
+}
+
+// Child returns a new span in the same trace, parented to tc.
+func (tc TraceContext) Child() TraceContext {
+	return TraceContext{TraceID: tc.TraceID, SpanID: randomHex(8), ParentSpanID: tc.SpanID, Flags: tc.Flags}
+}
+
+// Traceparent formats tc as a W3C traceparent header value.
+func (tc TraceContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+// Fields returns the log fields identifying tc.
+func (tc TraceContext) Fields() []zap.Field {
+	fields := []zap.Field{zap.String("trace_id", tc.TraceID), zap.String("span_id", tc.SpanID)}
+	if tc.ParentSpanID != "" {
+		fields = append(fields, zap.String("parent_span_id", tc.ParentSpanID))
+	}
+	return fields
+}
+
+// ContextWithTrace returns a copy of ctx carrying tc.
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceFromContext returns the trace context stored in ctx, if any.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// Ctx returns a logger that tags entries with the trace, job and scope
+// carried by ctx, and logs debug entries when ctx has a debug session.
+func Ctx(ctx context.Context) *Logger {
+	return logInstance.Load().Ctx(ctx)
+}
+
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	var fields []zap.Field
+	if tc, ok := TraceFromContext(ctx); ok {
+		fields = append(tc.Fields(), traceFieldsFromContext(ctx)...)
+	}
+	if DebugActive(ctx) {
+		fields = append(fields, debugSession, zap.Bool(DebugSessionKey, true))
+	}
+	if t, ok := tapeFromContext(ctx); ok {
+		fields = append(fields, tapeMarker(t))
+	}
+	fields = append(fields, jobFields(ctx)...)
+	fields = append(fields, scopeFields(ctx)...)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(fmt.Sprintf("logger: reading random bytes: %v", err))
+		}
+		// All-zero IDs are invalid in W3C Trace Context.
+		for _, c := range b {
+			if c != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
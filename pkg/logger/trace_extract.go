@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// TraceExtractor reads the trace an incoming request belongs to from
+// headers of a tracing system other than W3C Trace Context. Besides the
+// trace it may return fields of its own, e.g. the trace ID in the
+// system's native format, which are added wherever the trace is logged.
+type TraceExtractor interface {
+	Extract(h http.Header) (tc TraceContext, fields []zap.Field, ok bool)
+}
+
+// TraceExtractorFunc adapts a function to a TraceExtractor.
+type TraceExtractorFunc func(h http.Header) (TraceContext, []zap.Field, bool)
+
+func (f TraceExtractorFunc) Extract(h http.Header) (TraceContext, []zap.Field, bool) {
+	return f(h)
+}
+
+var traceExtractors struct {
+	mu   sync.RWMutex
+	list []TraceExtractor
+}
+
+// RegisterTraceExtractor adds e to the extractors HTTPMiddleware consults,
+// in registration order, when a request has no valid traceparent header.
+func RegisterTraceExtractor(e TraceExtractor) {
+	traceExtractors.mu.Lock()
+	defer traceExtractors.mu.Unlock()
+	traceExtractors.list = append(traceExtractors.list, e)
+}
+
+// extractTrace returns the trace of a request, from its traceparent header
+// or else the first registered extractor that finds one.
+func extractTrace(h http.Header) (TraceContext, []zap.Field, bool) {
+	if tc, err := ParseTraceparent(h.Get(TraceparentHeader)); err == nil {
+		return tc, nil, true
+	}
+	traceExtractors.mu.RLock()
+	defer traceExtractors.mu.RUnlock()
+	for _, e := range traceExtractors.list {
+		if tc, fields, ok := e.Extract(h); ok {
+			return tc, fields, true
+		}
+	}
+	return TraceContext{}, nil, false
+}
+
+type traceFieldsKey struct{}
+
+func contextWithTraceFields(ctx context.Context, fields []zap.Field) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, traceFieldsKey{}, fields)
+}
+
+func traceFieldsFromContext(ctx context.Context) []zap.Field {
+	fields, _ := ctx.Value(traceFieldsKey{}).([]zap.Field)
+	return fields
+}
+
+// XRayHeader is the AWS X-Ray tracing header.
+const XRayHeader = "X-Amzn-Trace-Id"
+
+// XRayExtractor reads AWS X-Ray headers such as
+// "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1".
+// The trace ID is converted to the W3C form and the original is added as
+// xray_trace_id.
+var XRayExtractor = TraceExtractorFunc(func(h http.Header) (TraceContext, []zap.Field, bool) {
+	var root, parent, sampled string
+	for _, part := range strings.Split(h.Get(XRayHeader), ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "Root":
+			root = value
+		case "Parent":
+			parent = value
+		case "Sampled":
+			sampled = value
+		}
+	}
+	// Root is "1-" followed by 8 hex digits of epoch time, "-" and 24 more.
+	// Load balancers send it without Parent.
+	parts := strings.Split(root, "-")
+	if len(parts) != 3 || parts[0] != "1" || len(parts[1]) != 8 || len(parts[2]) != 24 {
+		return TraceContext{}, nil, false
+	}
+	tc := TraceContext{TraceID: strings.ToLower(parts[1] + parts[2]), SpanID: strings.ToLower(parent)}
+	if !isLowerHex(tc.TraceID) || tc.SpanID != "" && (len(tc.SpanID) != 16 || !isLowerHex(tc.SpanID)) {
+		return TraceContext{}, nil, false
+	}
+	if sampled == "1" {
+		tc.Flags = 0x01
+	}
+	return tc, []zap.Field{zap.String("xray_trace_id", root)}, true
+})
+
+// B3Extractor reads Zipkin B3 propagation, either the single b3 header or
+// the X-B3-TraceId, X-B3-SpanId and X-B3-Sampled headers. 64-bit trace IDs
+// are padded to 128 bits.
+var B3Extractor = TraceExtractorFunc(func(h http.Header) (TraceContext, []zap.Field, bool) {
+	traceID, spanID, sampled := h.Get("X-B3-TraceId"), h.Get("X-B3-SpanId"), h.Get("X-B3-Sampled")
+	if single := h.Get("b3"); single != "" {
+		parts := strings.Split(single, "-")
+		if len(parts) < 2 {
+			return TraceContext{}, nil, false
+		}
+		traceID, spanID = parts[0], parts[1]
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+	}
+	traceID, spanID = strings.ToLower(traceID), strings.ToLower(spanID)
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) || len(spanID) != 16 || !isLowerHex(spanID) {
+		return TraceContext{}, nil, false
+	}
+	tc := TraceContext{TraceID: traceID, SpanID: spanID}
+	if sampled == "1" || sampled == "d" {
+		tc.Flags = 0x01
+	}
+	return tc, nil, true
+})
@@ -0,0 +1,167 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Usage is the volume one output wrote for one level and logger name,
+// to attribute log spend to the parts of a program.
+type Usage struct {
+	Output  string // e.g. "info log", "console" or "sink loki"
+	Level   zapcore.Level
+	Logger  string // name of the logger, empty for the root
+	Entries uint64
+	Bytes   uint64
+}
+
+func (u Usage) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("output", u.Output)
+	enc.AddString("level", levelName(u.Level))
+	if u.Logger != "" {
+		enc.AddString("logger", u.Logger)
+	}
+	enc.AddUint64("entries", u.Entries)
+	enc.AddUint64("bytes", u.Bytes)
+	return nil
+}
+
+type usageKey struct {
+	output string
+	level  zapcore.Level
+	logger string
+}
+
+// usageMeter counts the encoded entries of every output.
+type usageMeter struct {
+	mu     sync.Mutex
+	counts map[usageKey]*Usage
+}
+
+func newUsageMeter() *usageMeter {
+	return &usageMeter{counts: make(map[usageKey]*Usage)}
+}
+
+func (m *usageMeter) add(output string, ent zapcore.Entry, bytes int) {
+	key := usageKey{output, ent.Level, ent.LoggerName}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.counts[key]
+	if !ok {
+		u = &Usage{Output: output, Level: ent.Level, Logger: ent.LoggerName}
+		m.counts[key] = u
+	}
+	u.Entries++
+	u.Bytes += uint64(bytes)
+}
+
+// snapshot returns the counts sorted by output, level and logger.
+func (m *usageMeter) snapshot() []Usage {
+	m.mu.Lock()
+	usage := make([]Usage, 0, len(m.counts))
+	for _, u := range m.counts {
+		usage = append(usage, *u)
+	}
+	m.mu.Unlock()
+	sort.Slice(usage, func(i, j int) bool {
+		a, b := usage[i], usage[j]
+		if a.Output != b.Output {
+			return a.Output < b.Output
+		}
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		return a.Logger < b.Logger
+	})
+	return usage
+}
+
+// meteringEncoder counts the entries enc encodes for an output.
+type meteringEncoder struct {
+	zapcore.Encoder
+	meter  *usageMeter
+	output string
+}
+
+func (e meteringEncoder) Clone() zapcore.Encoder {
+	return meteringEncoder{Encoder: e.Encoder.Clone(), meter: e.meter, output: e.output}
+}
+
+func (e meteringEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if err == nil {
+		e.meter.add(e.output, ent, buf.Len())
+	}
+	return buf, err
+}
+
+// Usage returns the entries and bytes written since the logger was built,
+// by output, level and logger name, e.g. to export as metrics. Sinks
+// that encode entries themselves, such as journald, are not counted.
+func (l *Logger) Usage() []Usage {
+	if l.usage == nil {
+		return nil
+	}
+	return l.usage.snapshot()
+}
+
+// defaultUsageInterval is used for summary intervals that are not
+// positive.
+const defaultUsageInterval = time.Minute
+
+// StartUsageSummary logs the entries and bytes written in each interval, a
+// minute if it is not positive, at level, by output, level and logger
+// name. It returns a function that stops the summaries.
+func (l *Logger) StartUsageSummary(interval time.Duration, level zapcore.Level) (stop func()) {
+	if interval <= 0 {
+		interval = defaultUsageInterval
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := make(map[usageKey]Usage)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var (
+					delta []Usage
+					bytes uint64
+				)
+				for _, u := range l.Usage() {
+					key := usageKey{u.Output, u.Level, u.Logger}
+					prev := last[key]
+					last[key] = u
+					if u.Entries == prev.Entries {
+						continue
+					}
+					u.Entries -= prev.Entries
+					u.Bytes -= prev.Bytes
+					bytes += u.Bytes
+					delta = append(delta, u)
+				}
+				if ce := l.zap.Check(level, "log usage"); ce != nil {
+					ce.Write(
+						zap.Duration("interval", interval),
+						Bytes("bytes", int64(bytes)),
+						zap.Array("usage", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+							for _, u := range delta {
+								enc.AppendObject(u)
+							}
+							return nil
+						})),
+					)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultMaxEntryBytes is the entry size budget used in development mode
+// when Config.MaxEntryBytes is not set.
+const defaultMaxEntryBytes = 32 * 1024
+
+// validationCore is teed next to the real outputs in development mode. It
+// never writes the entries it sees; it only inspects them and reports
+// problems through out, so warnings are not validated again.
+type validationCore struct {
+	out      zapcore.Core
+	enc      zapcore.Encoder
+	maxBytes int
+	reserved map[string]bool
+	context  []zapcore.Field
+}
+
+func newValidationCore(out zapcore.Core, encoderConfig zapcore.EncoderConfig, maxBytes int) *validationCore {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxEntryBytes
+	}
+	reserved := make(map[string]bool)
+	for _, key := range []string{
+		encoderConfig.MessageKey,
+		encoderConfig.LevelKey,
+		encoderConfig.TimeKey,
+		encoderConfig.NameKey,
+		encoderConfig.CallerKey,
+		encoderConfig.FunctionKey,
+	} {
+		if key != "" {
+			reserved[key] = true
+		}
+	}
+	return &validationCore{
+		out:      out,
+		enc:      zapcore.NewJSONEncoder(encoderConfig),
+		maxBytes: maxBytes,
+		reserved: reserved,
+	}
+}
+
+func (c *validationCore) Enabled(lvl zapcore.Level) bool {
+	return c.out.Enabled(lvl)
+}
+
+func (c *validationCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &validationCore{
+		out:      c.out.With(fields),
+		enc:      c.enc.Clone(),
+		maxBytes: c.maxBytes,
+		reserved: c.reserved,
+		context:  append(c.context[:len(c.context):len(c.context)], fields...),
+	}
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return clone
+}
+
+func (c *validationCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *validationCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	problems := c.validate(ent, fields)
+	if len(problems) == 0 {
+		return nil
+	}
+	warn := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Now(),
+		LoggerName: ent.LoggerName,
+		Message:    "log entry failed validation",
+	}
+	if ce := c.out.Check(warn, nil); ce != nil {
+		ce.Write(zap.String("entry_message", ent.Message), zap.Strings("problems", problems))
+	}
+	return nil
+}
+
+func (c *validationCore) Sync() error {
+	return nil
+}
+
+func (c *validationCore) validate(ent zapcore.Entry, fields []zapcore.Field) []string {
+	var problems []string
+
+	if buf, err := c.enc.EncodeEntry(ent, fields); err == nil {
+		if buf.Len() > c.maxBytes {
+			problems = append(problems, fmt.Sprintf("entry exceeds size budget: %d > %d bytes", buf.Len(), c.maxBytes))
+		}
+		buf.Free()
+	}
+
+	all := append(c.context[:len(c.context):len(c.context)], fields...)
+	if code, ok := unregisteredCode(all); ok {
+		problems = append(problems, "unregistered event code: "+code)
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range fieldKeys(all) {
+		// Keys nested under a namespace cannot collide with top-level keys.
+		if !strings.Contains(key, ".") && c.reserved[key] {
+			problems = append(problems, "reserved field name: "+key)
+		}
+		if seen[key] {
+			problems = append(problems, "duplicate field name: "+key)
+		}
+		seen[key] = true
+	}
+	return problems
+}
+
+// fieldKeys returns the keys of fields as they appear in the encoded entry,
+// with keys opened after a zap.Namespace prefixed by that namespace.
+func fieldKeys(fields []zapcore.Field) []string {
+	keys := make([]string, 0, len(fields))
+	prefix := ""
+	for _, f := range fields {
+		if f.Type == zapcore.SkipType {
+			continue
+		}
+		keys = append(keys, prefix+f.Key)
+		if f.Type == zapcore.NamespaceType {
+			prefix += f.Key + "."
+		}
+	}
+	return keys
+}
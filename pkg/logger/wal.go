@@ -0,0 +1,423 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// A WAL segment is a sequence of records, each an 8-byte header (payload
+// length and CRC-32C of the payload, little endian) followed by the
+// payload. The cursor file records how far the head has been delivered.
+const (
+	walHeaderSize = 8
+	walSuffix     = ".wal"
+	walCursorName = "cursor"
+)
+
+var walCRC = crc32.MakeTable(crc32.Castagnoli)
+
+var errWALCorrupt = errors.New("wal: corrupt record")
+
+type walSegment struct {
+	id    uint64
+	size  int64
+	count int
+}
+
+// walPos is a position in the log: byte offset and number of records
+// before it within segment id.
+type walPos struct {
+	id  uint64
+	off int64
+	n   int
+}
+
+// wal is a deliveryQueue backed by segment files in a directory.
+type wal struct {
+	mu           sync.Mutex
+	dir          string
+	segmentBytes int64
+	maxBytes     int64
+
+	segments []*walSegment // oldest first; the last one is appended to
+	active   *os.File
+	total    int64
+
+	head    walPos // first undelivered record
+	pending walPos // position after the last peek
+	queued  int
+	dropped uint64
+
+	reader   *os.File
+	readerID uint64
+}
+
+// openWAL opens or creates the log in dir. Existing segments are verified
+// and a torn or corrupt tail, left by a crash mid-write, is truncated.
+func openWAL(dir string, segmentBytes, maxBytes int64) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &wal{dir: dir, segmentBytes: segmentBytes, maxBytes: maxBytes}
+
+	ids, err := w.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	head, err := w.readCursor()
+	if err != nil {
+		return nil, err
+	}
+	// Segment ids keep increasing across restarts even once every segment
+	// has been delivered and removed.
+	next := head.id
+	for _, id := range ids {
+		if id < head.id {
+			// Fully delivered before the cursor was last written.
+			os.Remove(w.segmentPath(id))
+			continue
+		}
+		seg, consumed, err := w.recoverSegment(id, head)
+		if err != nil {
+			return nil, err
+		}
+		if id == head.id {
+			head = consumed
+		}
+		w.segments = append(w.segments, seg)
+		w.total += seg.size
+		w.queued += seg.count
+	}
+	if len(w.segments) == 0 || w.segments[0].id != head.id {
+		head = walPos{}
+		if len(w.segments) > 0 {
+			head.id = w.segments[0].id
+		}
+	}
+	w.head = head
+	w.queued -= head.n
+
+	if n := len(w.segments); n > 0 && w.segments[n-1].size < segmentBytes {
+		seg := w.segments[n-1]
+		f, err := os.OpenFile(w.segmentPath(seg.id), os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		w.active = f
+	} else {
+		if n > 0 {
+			next = w.segments[n-1].id + 1
+		}
+		if err := w.startSegment(next); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			w.head = walPos{id: next}
+		}
+	}
+	w.pending = w.head
+	return w, nil
+}
+
+func (w *wal) segmentPath(id uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%016x%s", id, walSuffix))
+}
+
+func (w *wal) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, walSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, walSuffix), 16, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// recoverSegment scans segment id, truncating it at the first bad record.
+// When id is the cursor's segment it also returns the cursor position with
+// the number of records before it filled in.
+func (w *wal) recoverSegment(id uint64, cursor walPos) (*walSegment, walPos, error) {
+	path := w.segmentPath(id)
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, walPos{}, err
+	}
+	defer f.Close()
+
+	seg := &walSegment{id: id}
+	consumed := walPos{id: id}
+	r := bufio.NewReader(f)
+	for {
+		if seg.id == cursor.id && seg.size <= cursor.off {
+			consumed.off, consumed.n = seg.size, seg.count
+		}
+		payload, err := readWALRecord(r, -1)
+		if err != nil {
+			break
+		}
+		seg.size += int64(walHeaderSize + len(payload))
+		seg.count++
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, walPos{}, err
+	}
+	if info.Size() != seg.size {
+		if err := f.Truncate(seg.size); err != nil {
+			return nil, walPos{}, err
+		}
+	}
+	return seg, consumed, nil
+}
+
+// readWALRecord reads one record from r. A limit of zero or more bounds
+// the payload length.
+func readWALRecord(r io.Reader, limit int64) ([]byte, error) {
+	var hdr [walHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(hdr[0:4])
+	sum := binary.LittleEndian.Uint32(hdr[4:8])
+	if limit >= 0 && int64(n) > limit {
+		return nil, errWALCorrupt
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(payload, walCRC) != sum {
+		return nil, errWALCorrupt
+	}
+	return payload, nil
+}
+
+func (w *wal) readCursor() (walPos, error) {
+	data, err := os.ReadFile(filepath.Join(w.dir, walCursorName))
+	if errors.Is(err, os.ErrNotExist) {
+		return walPos{}, nil
+	}
+	if err != nil {
+		return walPos{}, err
+	}
+	var pos walPos
+	if _, err := fmt.Sscanf(string(data), "%d %d", &pos.id, &pos.off); err != nil {
+		// An unreadable cursor only means entries are delivered again.
+		return walPos{}, nil
+	}
+	return pos, nil
+}
+
+func (w *wal) writeCursor() error {
+	path := filepath.Join(w.dir, walCursorName)
+	tmp := path + ".tmp"
+	data := fmt.Sprintf("%d %d\n", w.head.id, w.head.off)
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (w *wal) startSegment(id uint64) error {
+	f, err := os.OpenFile(w.segmentPath(id), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if w.active != nil {
+		w.active.Close()
+	}
+	w.active = f
+	w.segments = append(w.segments, &walSegment{id: id})
+	return nil
+}
+
+func (w *wal) push(entry []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active == nil {
+		return os.ErrClosed
+	}
+	seg := w.segments[len(w.segments)-1]
+	if seg.size > 0 && seg.size+walHeaderSize+int64(len(entry)) > w.segmentBytes {
+		if err := w.startSegment(seg.id + 1); err != nil {
+			return err
+		}
+		seg = w.segments[len(w.segments)-1]
+	}
+
+	rec := make([]byte, walHeaderSize+len(entry))
+	binary.LittleEndian.PutUint32(rec[0:4], uint32(len(entry)))
+	binary.LittleEndian.PutUint32(rec[4:8], crc32.Checksum(entry, walCRC))
+	copy(rec[walHeaderSize:], entry)
+	if _, err := w.active.Write(rec); err != nil {
+		// Drop whatever part of the record made it to disk.
+		w.active.Truncate(seg.size)
+		return err
+	}
+	seg.size += int64(len(rec))
+	seg.count++
+	w.total += int64(len(rec))
+	w.queued++
+	w.enforceRetention()
+	return nil
+}
+
+// enforceRetention discards the oldest segments, delivered or not, while
+// the log is larger than maxBytes. The active segment is always kept.
+func (w *wal) enforceRetention() {
+	for w.total > w.maxBytes && len(w.segments) > 1 {
+		seg := w.segments[0]
+		lost := seg.count
+		if w.head.id == seg.id {
+			lost -= w.head.n
+		}
+		w.dropped += uint64(lost)
+		w.queued -= lost
+		w.removeHead()
+		w.head = walPos{id: w.segments[0].id}
+	}
+}
+
+func (w *wal) removeHead() {
+	seg := w.segments[0]
+	if w.reader != nil && w.readerID == seg.id {
+		w.reader.Close()
+		w.reader = nil
+	}
+	os.Remove(w.segmentPath(seg.id))
+	w.total -= seg.size
+	w.segments = w.segments[1:]
+}
+
+func (w *wal) peek(max int) ([][]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pos := w.head
+	var batch [][]byte
+	for i, seg := range w.segments {
+		if i > 0 {
+			pos = walPos{id: seg.id}
+		}
+		if pos.off < seg.size {
+			entries, off, err := w.read(seg, pos.off, max-len(batch))
+			if err != nil {
+				return nil, fmt.Errorf("wal segment %d: %w", seg.id, err)
+			}
+			batch = append(batch, entries...)
+			pos.off = off
+			pos.n += len(entries)
+		}
+		if len(batch) >= max || pos.off < seg.size {
+			break
+		}
+	}
+	w.pending = pos
+	return batch, nil
+}
+
+// read returns up to max records of seg starting at off, and the offset
+// after the last one.
+func (w *wal) read(seg *walSegment, off int64, max int) ([][]byte, int64, error) {
+	if w.reader == nil || w.readerID != seg.id {
+		if w.reader != nil {
+			w.reader.Close()
+		}
+		f, err := os.Open(w.segmentPath(seg.id))
+		if err != nil {
+			w.reader = nil
+			return nil, off, err
+		}
+		w.reader, w.readerID = f, seg.id
+	}
+	r := bufio.NewReader(io.NewSectionReader(w.reader, off, seg.size-off))
+	var entries [][]byte
+	for len(entries) < max && off < seg.size {
+		payload, err := readWALRecord(r, seg.size-off-walHeaderSize)
+		if err != nil {
+			return nil, off, err
+		}
+		entries = append(entries, payload)
+		off += int64(walHeaderSize + len(payload))
+	}
+	return entries, off, nil
+}
+
+// commit advances the head past the last peek and removes segments that
+// have been delivered completely.
+func (w *wal) commit() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pos := w.pending
+	if pos.id < w.head.id || pos.id == w.head.id && pos.off <= w.head.off {
+		// Nothing peeked, or retention discarded it in the meantime.
+		return nil
+	}
+	for len(w.segments) > 1 && w.segments[0].id < pos.id {
+		seg := w.segments[0]
+		w.queued -= seg.count - w.head.n
+		w.removeHead()
+		w.head = walPos{id: w.segments[0].id}
+	}
+	w.queued -= pos.n - w.head.n
+	w.head = pos
+	return w.writeCursor()
+}
+
+func (w *wal) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.queued
+}
+
+func (w *wal) lost() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+func (w *wal) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active == nil {
+		return nil
+	}
+	return w.active.Sync()
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active == nil {
+		return nil
+	}
+	err := multierr.Combine(w.active.Sync(), w.active.Close(), w.writeCursor())
+	w.active = nil
+	if w.reader != nil {
+		w.reader.Close()
+		w.reader = nil
+	}
+	return err
+}
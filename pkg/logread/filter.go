@@ -0,0 +1,98 @@
+package logread
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Filter selects entries for Reader.Where.
+type Filter func(*Entry) bool
+
+// MinLevel keeps entries of lvl and above.
+func MinLevel(lvl zapcore.Level) Filter {
+	return func(e *Entry) bool {
+		return e.Level != zapcore.InvalidLevel && e.Level >= lvl
+	}
+}
+
+// Between keeps entries logged at or after from and before to. A zero
+// time leaves that end open.
+func Between(from, to time.Time) Filter {
+	return func(e *Entry) bool {
+		return (from.IsZero() || !e.Time.Before(from)) && (to.IsZero() || e.Time.Before(to))
+	}
+}
+
+// MessageContains keeps entries whose message contains s.
+func MessageContains(s string) Filter {
+	return func(e *Entry) bool {
+		return strings.Contains(e.Message, s)
+	}
+}
+
+// HasField keeps entries with the field key.
+func HasField(key string) Filter {
+	return func(e *Entry) bool {
+		return e.Has(key)
+	}
+}
+
+// FieldEquals keeps entries whose field key holds v once both are in
+// JSON, so FieldEquals("status", 200) matches zap.Int("status", 200).
+func FieldEquals(key string, v interface{}) Filter {
+	want, err := jsonValue(v)
+	return func(e *Entry) bool {
+		raw, ok := e.Fields[key]
+		if !ok || err != nil {
+			return false
+		}
+		var got interface{}
+		return json.Unmarshal(raw, &got) == nil && reflect.DeepEqual(got, want)
+	}
+}
+
+// jsonValue returns v as json.Unmarshal would decode its encoding.
+func jsonValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	err = json.Unmarshal(b, &decoded)
+	return decoded, err
+}
+
+// Not keeps the entries f drops.
+func Not(f Filter) Filter {
+	return func(e *Entry) bool {
+		return !f(e)
+	}
+}
+
+// AllOf keeps the entries all of filters keep.
+func AllOf(filters ...Filter) Filter {
+	return func(e *Entry) bool {
+		for _, f := range filters {
+			if !f(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf keeps the entries any of filters keeps.
+func AnyOf(filters ...Filter) Filter {
+	return func(e *Entry) bool {
+		for _, f := range filters {
+			if f(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,446 @@
+package logread
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/intellectia/go-log/pkg/logger"
+)
+
+// Query parses a filter expression, the one the command-line tools take,
+// into a Filter:
+//
+//	level>=warn AND user_id=42
+//	msg~"timed? out" OR (status>=500 AND NOT path="/health")
+//	time>15m AND http.method=POST
+//
+// A comparison is a key, an operator and a value. The operators are =, !=,
+// <, <=, >, >=, ~ (matches a regular expression) and !~; a key alone keeps
+// the entries that have it. Comparisons combine with AND, OR, NOT and
+// parentheses, also written &&, || and !, AND binding tighter than OR.
+//
+// The keys level, msg (or message), logger, caller and time (or ts) are
+// the standard fields; levels compare in severity order, and times with
+// RFC 3339 timestamps, dates, or durations meaning that long ago. Other
+// keys are fields, with dots reaching into objects when no field has the
+// dotted name. Values are numbers, true, false, null, double-quoted
+// strings, or bare words taken as strings. Numbers compare numerically,
+// and strings holding durations or RFC 3339 times compare as such when the
+// value does too. Comparing a missing field is false, except with != and
+// !~.
+func Query(expr string) (Filter, error) {
+	p := &queryParser{src: expr}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 1 {
+		return func(*Entry) bool { return true }, nil
+	}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOp
+	tokenOpen
+	tokenClose
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind tokenKind
+	text string // unquoted for strings
+	pos  int
+}
+
+type queryParser struct {
+	src    string
+	tokens []token
+	next   int
+}
+
+func (p *queryParser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("query %q: at %d: %s", p.src, t.pos+1, fmt.Sprintf(format, args...))
+}
+
+// lex splits the expression into tokens, ending with a tokenEnd.
+func (p *queryParser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			p.tokens = append(p.tokens, token{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			p.tokens = append(p.tokens, token{tokenClose, ")", i})
+			i++
+		case strings.HasPrefix(s[i:], "&&"):
+			p.tokens = append(p.tokens, token{tokenAnd, "&&", i})
+			i += 2
+		case strings.HasPrefix(s[i:], "||"):
+			p.tokens = append(p.tokens, token{tokenOr, "||", i})
+			i += 2
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return p.errorf(token{pos: i}, "unterminated string")
+			}
+			text, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return p.errorf(token{pos: i}, "bad string %s", s[i:end+1])
+			}
+			p.tokens = append(p.tokens, token{tokenString, text, i})
+			i = end + 1
+		case strings.ContainsRune("=!<>~", rune(c)):
+			op := s[i : i+1]
+			if i+1 < len(s) && (s[i+1] == '=' || c == '!' && s[i+1] == '~') {
+				op = s[i : i+2]
+			}
+			kind := tokenOp
+			if op == "!" {
+				kind = tokenNot
+			}
+			p.tokens = append(p.tokens, token{kind, strings.Replace(op, "==", "=", 1), i})
+			i += len(op)
+		default:
+			end := i
+			for end < len(s) && !isQuerySpecial(s[end]) {
+				end++
+			}
+			word := s[i:end]
+			kind := tokenWord
+			switch strings.ToUpper(word) {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+			p.tokens = append(p.tokens, token{kind, word, i})
+			i = end
+		}
+	}
+	p.tokens = append(p.tokens, token{tokenEnd, "end", len(s)})
+	return nil
+}
+
+func isQuerySpecial(c byte) bool {
+	return unicode.IsSpace(rune(c)) || strings.IndexByte(`()"=!<>~&|`, c) >= 0
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *queryParser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return t
+}
+
+func (p *queryParser) or() (Filter, error) {
+	f, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{f}
+	for p.peek().kind == tokenOr {
+		p.take()
+		f, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return f, nil
+	}
+	return AnyOf(filters...), nil
+}
+
+func (p *queryParser) and() (Filter, error) {
+	f, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{f}
+	for p.peek().kind == tokenAnd {
+		p.take()
+		f, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return f, nil
+	}
+	return AllOf(filters...), nil
+}
+
+func (p *queryParser) not() (Filter, error) {
+	switch t := p.take(); t.kind {
+	case tokenNot:
+		f, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return Not(f), nil
+	case tokenOpen:
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.take(); t.kind != tokenClose {
+			return nil, p.errorf(t, "expected ) instead of %q", t.text)
+		}
+		return f, nil
+	case tokenWord, tokenString:
+		return p.comparison(t)
+	default:
+		return nil, p.errorf(t, "expected a key instead of %q", t.text)
+	}
+}
+
+func (p *queryParser) comparison(key token) (Filter, error) {
+	if p.peek().kind != tokenOp {
+		return HasField(key.text), nil
+	}
+	op := p.take()
+	v := p.take()
+	if v.kind != tokenWord && v.kind != tokenString {
+		return nil, p.errorf(v, "expected a value after %s instead of %q", op.text, v.text)
+	}
+	if op.text == "~" || op.text == "!~" {
+		re, err := regexp.Compile(v.text)
+		if err != nil {
+			return nil, p.errorf(v, "%v", err)
+		}
+		f := func(e *Entry) bool {
+			got, ok := lookup(e, key.text)
+			return ok && re.MatchString(textOf(got))
+		}
+		if op.text == "!~" {
+			return Not(f), nil
+		}
+		return f, nil
+	}
+
+	var compare func(*Entry) (int, bool)
+	switch strings.ToLower(key.text) {
+	case "level":
+		want, err := logger.ParseLevel(v.text)
+		if err != nil {
+			return nil, p.errorf(v, "%v", err)
+		}
+		compare = func(e *Entry) (int, bool) {
+			if e.Level == zapcore.InvalidLevel {
+				return 0, false
+			}
+			return int(e.Level) - int(want), true
+		}
+	case "time", "ts":
+		want, err := queryTime(v.text)
+		if err != nil {
+			return nil, p.errorf(v, "%v", err)
+		}
+		compare = func(e *Entry) (int, bool) {
+			if e.Time.IsZero() {
+				return 0, false
+			}
+			return compareTimes(e.Time, want), true
+		}
+	default:
+		want := queryValueOf(v)
+		compare = func(e *Entry) (int, bool) {
+			got, ok := lookup(e, key.text)
+			if !ok {
+				return 0, false
+			}
+			return compareValues(got, want, op.text == "=" || op.text == "!=")
+		}
+	}
+	test := map[string]func(int) bool{
+		"=":  func(c int) bool { return c == 0 },
+		"!=": func(c int) bool { return c != 0 },
+		"<":  func(c int) bool { return c < 0 },
+		"<=": func(c int) bool { return c <= 0 },
+		">":  func(c int) bool { return c > 0 },
+		">=": func(c int) bool { return c >= 0 },
+	}[op.text]
+	if test == nil {
+		return nil, p.errorf(op, "unknown operator %s", op.text)
+	}
+	if op.text == "!=" {
+		return func(e *Entry) bool {
+			c, ok := compare(e)
+			return !ok || c != 0
+		}, nil
+	}
+	return func(e *Entry) bool {
+		c, ok := compare(e)
+		return ok && test(c)
+	}, nil
+}
+
+// queryValue is a value as written in a query: the text, and what it
+// decodes to as JSON when it is a bare number, true, false or null.
+type queryValue struct {
+	text    string
+	decoded interface{}
+}
+
+func queryValueOf(t token) queryValue {
+	v := queryValue{text: t.text, decoded: t.text}
+	var decoded interface{}
+	if t.kind == tokenWord && json.Unmarshal([]byte(t.text), &decoded) == nil {
+		v.decoded = decoded
+	}
+	return v
+}
+
+// queryTime parses the time a query compares with.
+func queryTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(strings.TrimPrefix(s, "-")); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a time nor a duration", s)
+}
+
+// lookup returns the decoded value of key in e.
+func lookup(e *Entry, key string) (interface{}, bool) {
+	switch strings.ToLower(key) {
+	case "level":
+		if e.Level == zapcore.InvalidLevel {
+			return nil, false
+		}
+		return e.Level.String(), true
+	case "msg", "message":
+		return e.Message, true
+	case "logger":
+		return e.Logger, e.Logger != ""
+	case "caller":
+		return e.Caller, e.Caller != ""
+	case "time", "ts":
+		return e.Time.Format(time.RFC3339Nano), !e.Time.IsZero()
+	}
+	var v interface{}
+	if raw, ok := e.Fields[key]; ok {
+		return v, json.Unmarshal(raw, &v) == nil
+	}
+	head, rest, ok := strings.Cut(key, ".")
+	raw, found := e.Fields[head]
+	if !ok || !found || json.Unmarshal(raw, &v) != nil {
+		return nil, false
+	}
+	for _, part := range strings.Split(rest, ".") {
+		obj, isObj := v.(map[string]interface{})
+		if !isObj {
+			return nil, false
+		}
+		if v, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// textOf is the string matched by ~: the string itself, or the JSON.
+func textOf(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// compareValues orders a field value against a query value, reporting
+// whether they are comparable. Only equality is defined between values of
+// different types, and it never holds.
+func compareValues(got interface{}, want queryValue, equality bool) (int, bool) {
+	if n, ok := got.(float64); ok {
+		w, err := strconv.ParseFloat(want.text, 64)
+		if err != nil {
+			return 1, equality
+		}
+		return compareOrdered(n, w), true
+	}
+	if s, ok := got.(string); ok {
+		if gd, err := time.ParseDuration(s); err == nil {
+			if wd, err := time.ParseDuration(want.text); err == nil {
+				return compareOrdered(gd, wd), true
+			}
+		}
+		if gt, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			if wt, err := time.Parse(time.RFC3339Nano, want.text); err == nil {
+				return compareTimes(gt, wt), true
+			}
+		}
+		return strings.Compare(s, want.text), true
+	}
+	if equality {
+		if reflect.DeepEqual(got, want.decoded) {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func compareOrdered[T ~int64 | ~float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,327 @@
+// Package logread reads back the entries of the log files written by
+// github.com/intellectia/go-log, in the JSON or binary file format, so
+// tools and tests can consume what a program logged:
+//
+//	r, err := logread.Open("logs/info.log", "logs/error.log")
+//	if err != nil {
+//		return err
+//	}
+//	defer r.Close()
+//	r.Where(logread.MinLevel(zapcore.WarnLevel), logread.FieldEquals("user_id", 42))
+//	for {
+//		e, err := r.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		var status int
+//		e.Field("status", &status)
+//		...
+//	}
+//
+// Query parses the filter expressions the command-line tools take, such
+// as "level>=warn AND user_id=42", into a Filter for Where.
+package logread
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/intellectia/go-log/pkg/logger"
+)
+
+// Entry is a decoded log entry.
+type Entry struct {
+	Time    time.Time
+	Level   zapcore.Level // zapcore.InvalidLevel when not recognized
+	Message string
+	Logger  string
+	Caller  string
+
+	// Fields are the other fields of the entry, by key.
+	Fields map[string]json.RawMessage
+
+	// Raw is the whole entry as a JSON object.
+	Raw json.RawMessage
+}
+
+// Has reports whether the entry has the field key.
+func (e *Entry) Has(key string) bool {
+	_, ok := e.Fields[key]
+	return ok
+}
+
+// Field decodes the field key into v, as json.Unmarshal does.
+func (e *Entry) Field(key string, v interface{}) error {
+	raw, ok := e.Fields[key]
+	if !ok {
+		return fmt.Errorf("no field %q", key)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Decode decodes the whole entry into v, typically a struct with json
+// tags for the keys it needs.
+func (e *Entry) Decode(v interface{}) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// Keys are the names of the standard fields.
+type Keys struct {
+	Time, Level, Message, Logger, Caller string
+
+	// TimeFormat is the logger.Config TimeFormat the entries were written
+	// with, to read numeric timestamps and custom layouts.
+	TimeFormat string
+}
+
+// DefaultKeys are the keys of a logger with the default Config.
+var DefaultKeys = Keys{Time: "ts", Level: "level", Message: "msg", Logger: "logger", Caller: "caller"}
+
+// KeysOf returns the keys of entries written with config.
+func KeysOf(config *logger.Config) Keys {
+	k := DefaultKeys
+	if config.TimeKey != "" {
+		k.Time = config.TimeKey
+	}
+	if config.LevelKey != "" {
+		k.Level = config.LevelKey
+	}
+	if config.MessageKey != "" {
+		k.Message = config.MessageKey
+	}
+	if config.CallerKey != "" {
+		k.Caller = config.CallerKey
+	}
+	k.TimeFormat = config.TimeFormat
+	return k
+}
+
+// Reader iterates over the entries of one or more log files, in order,
+// keeping those that pass its filters.
+type Reader struct {
+	keys    Keys
+	filters []Filter
+
+	paths   []string // files still to open
+	current *logger.EntryReader
+	closer  io.Closer // of current, nil for a reader passed in
+
+	seen logger.SequenceTracker
+}
+
+// Open returns a reader of the files at paths, read one after the other.
+// Files ending in .gz are decompressed.
+func Open(paths ...string) (*Reader, error) {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	return &Reader{keys: DefaultKeys, paths: paths}, nil
+}
+
+// NewReader returns a reader of the entries in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{keys: DefaultKeys, current: logger.NewEntryReader(r)}
+}
+
+// WithKeys sets the names of the standard fields, DefaultKeys unless the
+// entries were written with other keys.
+func (r *Reader) WithKeys(keys Keys) *Reader {
+	r.keys = keys
+	return r
+}
+
+// Where adds filters; Next returns only the entries passing all of them.
+func (r *Reader) Where(filters ...Filter) *Reader {
+	r.filters = append(r.filters, filters...)
+	return r
+}
+
+// Next returns the next entry passing the filters, and io.EOF after the
+// last one. A damaged record or a line that is not a JSON object returns
+// an error; reading may go on with the entries after it.
+func (r *Reader) Next() (*Entry, error) {
+	for {
+		if r.current == nil {
+			if len(r.paths) == 0 {
+				return nil, io.EOF
+			}
+			if err := r.open(); err != nil {
+				return nil, err
+			}
+		}
+		raw, err := r.current.Next()
+		if errors.Is(err, io.EOF) {
+			r.closeCurrent()
+			if len(r.paths) == 0 {
+				return nil, io.EOF
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		e, err := Decode(raw, r.keys)
+		if err != nil {
+			return nil, err
+		}
+		var seq uint64
+		if e.Field(logger.SequenceKey, &seq) == nil {
+			r.seen.Observe(seq)
+		}
+		if r.keep(e) {
+			return e, nil
+		}
+	}
+}
+
+// All returns the remaining entries passing the filters.
+func (r *Reader) All() ([]*Entry, error) {
+	var entries []*Entry
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Loss reports the entries read so far, filtered or not, and those missing
+// by their sequence numbers (logger.Config.EntryIDs). Read together the
+// files that entries were split into by level, since each alone has gaps
+// for the levels of the others.
+func (r *Reader) Loss() logger.LossReport {
+	return r.seen.Report()
+}
+
+// Close closes the file being read.
+func (r *Reader) Close() error {
+	r.paths = nil
+	return r.closeCurrent()
+}
+
+func (r *Reader) open() error {
+	path := r.paths[0]
+	r.paths = r.paths[1:]
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	var in io.Reader = f
+	r.closer = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		in = zr
+	}
+	r.current = logger.NewEntryReader(in)
+	return nil
+}
+
+func (r *Reader) closeCurrent() error {
+	r.current = nil
+	if r.closer == nil {
+		return nil
+	}
+	err := r.closer.Close()
+	r.closer = nil
+	return err
+}
+
+func (r *Reader) keep(e *Entry) bool {
+	for _, f := range r.filters {
+		if !f(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode decodes an entry as the logger writes it, a JSON object whose
+// standard fields are named by keys.
+func Decode(raw []byte, keys Keys) (*Entry, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	e := &Entry{
+		Level:  zapcore.InvalidLevel,
+		Fields: fields,
+		Raw:    append(json.RawMessage(nil), raw...),
+	}
+	if v, ok := fields[keys.Time]; ok {
+		e.Time = parseTime(v, keys.TimeFormat)
+		delete(fields, keys.Time)
+	}
+	var level string
+	for _, std := range []struct {
+		key string
+		dst *string
+	}{
+		{keys.Level, &level},
+		{keys.Message, &e.Message},
+		{keys.Logger, &e.Logger},
+		{keys.Caller, &e.Caller},
+	} {
+		if v, ok := fields[std.key]; ok && json.Unmarshal(v, std.dst) == nil {
+			delete(fields, std.key)
+		}
+	}
+	if lvl, err := logger.ParseLevel(level); err == nil {
+		e.Level = lvl
+	}
+	return e, nil
+}
+
+// parseTime reads a timestamp written in format: a time layout, or epoch
+// seconds or milliseconds. Unknown or empty formats are taken as RFC 3339
+// or, for numbers, seconds unless they would be past the year 5000.
+func parseTime(raw json.RawMessage, format string) time.Time {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		layout := time.RFC3339Nano
+		switch strings.ToLower(format) {
+		case "", logger.TimeFormatRFC3339, logger.TimeFormatRFC3339Nano:
+		default:
+			layout = format
+		}
+		t, _ := time.Parse(layout, s)
+		return t
+	}
+	var num json.Number
+	if json.Unmarshal(raw, &num) != nil {
+		return time.Time{}
+	}
+	n, err := num.Float64()
+	if err != nil {
+		return time.Time{}
+	}
+	if strings.EqualFold(format, logger.TimeFormatEpochMillis) || format == "" && n > 1e11 {
+		if ms, err := num.Int64(); err == nil {
+			return time.UnixMilli(ms)
+		}
+		n /= 1000
+	}
+	whole, frac := math.Modf(n)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}